@@ -44,6 +44,7 @@ import (
 	"github.com/Jeffail/benthos/lib/pipeline"
 	"github.com/Jeffail/benthos/lib/processor"
 	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/ratelimit"
 	"github.com/Jeffail/benthos/lib/stream"
 	strmmgr "github.com/Jeffail/benthos/lib/stream/manager"
 	"github.com/Jeffail/benthos/lib/util/config"
@@ -118,6 +119,12 @@ func (c Config) Sanitised() (interface{}, error) {
 		return nil, err
 	}
 
+	var mgrConf interface{}
+	mgrConf, err = manager.SanitiseConfig(c.Manager)
+	if err != nil {
+		return nil, err
+	}
+
 	return struct {
 		HTTP                 interface{} `json:"http" yaml:"http"`
 		Input                interface{} `json:"input" yaml:"input"`
@@ -134,7 +141,7 @@ func (c Config) Sanitised() (interface{}, error) {
 		Buffer:               bufConf,
 		Pipeline:             pipeConf,
 		Output:               outConf,
-		Manager:              c.Manager,
+		Manager:              mgrConf,
 		Logger:               c.Logger,
 		Metrics:              metConf,
 		SystemCloseTimeoutMS: c.SystemCloseTimeoutMS,
@@ -191,6 +198,10 @@ var (
 		"list-caches", false,
 		"Print a list of available cache options, then exit",
 	)
+	printRateLimits = flag.Bool(
+		"list-rate-limits", false,
+		"Print a list of available rate limit options, then exit",
+	)
 	streamsMode = flag.Bool(
 		"streams", false,
 		"Run Benthos in streams mode, where streams can be created, updated"+
@@ -289,7 +300,7 @@ func bootstrap() Config {
 	}
 
 	// If we only want to print our inputs or outputs we should exit afterwards
-	if *printInputs || *printOutputs || *printBuffers || *printProcessors || *printConditions || *printCaches {
+	if *printInputs || *printOutputs || *printBuffers || *printProcessors || *printConditions || *printCaches || *printRateLimits {
 		if *printInputs {
 			fmt.Println(input.Descriptions())
 		}
@@ -308,6 +319,9 @@ func bootstrap() Config {
 		if *printCaches {
 			fmt.Println(cache.Descriptions())
 		}
+		if *printRateLimits {
+			fmt.Println(ratelimit.Descriptions())
+		}
 		os.Exit(0)
 	}
 
@@ -434,9 +448,15 @@ func main() {
 			os.Exit(1)
 		}()
 
+		started := time.Now()
 		if err := dataStream.Stop(tout); err != nil {
 			os.Exit(1)
 		}
+		manager.CloseAsync()
+		if err := manager.WaitForClose(tout - time.Since(started)); err != nil {
+			logger.Warnf("Failed to close resources: %v\n", err)
+			os.Exit(1)
+		}
 	}()
 
 	sigChan := make(chan os.Signal, 1)
@@ -0,0 +1,286 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package broker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+// ErrPartitionNoKey is returned when creating a Partition broker without a
+// partition key.
+var ErrPartitionNoKey = errors.New("partition broker requires a non-empty key")
+
+//------------------------------------------------------------------------------
+
+// Partition is a broker that implements types.Consumer and sends each message
+// to a single output chosen by hashing a key extracted from the message. All
+// messages that share a key are sent to the same output and therefore remain
+// in order.
+//
+// Outputs are ranked for each key using rendezvous hashing, and when fallback
+// is enabled a message that fails to send because its output is unavailable is
+// re-attempted with the next output in the ranking for its key. An output is
+// considered unavailable when it reports that it is not connected or closed,
+// or when it does not accept and acknowledge a message within the fallback
+// timeout.
+type Partition struct {
+	running int32
+
+	stats metrics.Type
+
+	key             []byte
+	keyInterp       bool
+	fallback        bool
+	fallbackTimeout time.Duration
+
+	transactions <-chan types.Transaction
+
+	outputTsChans []chan types.Transaction
+	outputs       []types.Output
+
+	closedChan chan struct{}
+	closeChan  chan struct{}
+}
+
+// NewPartition creates a new Partition type by providing consumers, a key that
+// may contain function interpolations, whether failed messages should fall
+// back to other outputs and how long to wait for an output before falling back.
+func NewPartition(
+	outputs []types.Output,
+	key string,
+	fallback bool,
+	fallbackTimeout time.Duration,
+	stats metrics.Type,
+) (*Partition, error) {
+	if len(key) == 0 {
+		return nil, ErrPartitionNoKey
+	}
+	keyBytes := []byte(key)
+	p := &Partition{
+		running:         1,
+		stats:           stats,
+		key:             keyBytes,
+		keyInterp:       text.ContainsFunctionVariables(keyBytes),
+		fallback:        fallback,
+		fallbackTimeout: fallbackTimeout,
+		transactions:    nil,
+		outputs:         outputs,
+		closedChan:      make(chan struct{}),
+		closeChan:       make(chan struct{}),
+	}
+	p.outputTsChans = make([]chan types.Transaction, len(p.outputs))
+	for i := range p.outputTsChans {
+		p.outputTsChans[i] = make(chan types.Transaction)
+		if err := p.outputs[i].StartReceiving(p.outputTsChans[i]); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+//------------------------------------------------------------------------------
+
+// StartReceiving assigns a new messages channel for the broker to read.
+func (p *Partition) StartReceiving(ts <-chan types.Transaction) error {
+	if p.transactions != nil {
+		return types.ErrAlreadyStarted
+	}
+	p.transactions = ts
+
+	go p.loop()
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// rendezvousScore returns the score of an output index for a key hash. The
+// output with the highest score for a key is its preferred target.
+func rendezvousScore(keyHash uint64, index int) uint64 {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], keyHash)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(index))
+
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// ranking returns the indexes of all outputs ordered by preference for a key.
+func (p *Partition) ranking(key []byte) []int {
+	h := fnv.New64a()
+	h.Write(key)
+	keyHash := h.Sum64()
+
+	indexes := make([]int, len(p.outputs))
+	scores := make([]uint64, len(p.outputs))
+	for i := range indexes {
+		indexes[i] = i
+		scores[i] = rendezvousScore(keyHash, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return scores[indexes[i]] > scores[indexes[j]]
+	})
+	return indexes
+}
+
+// target returns the preferred output index for a key.
+func (p *Partition) target(key []byte) int {
+	h := fnv.New64a()
+	h.Write(key)
+	keyHash := h.Sum64()
+
+	target, topScore := 0, uint64(0)
+	for i := range p.outputs {
+		if score := rendezvousScore(keyHash, i); i == 0 || score > topScore {
+			target, topScore = i, score
+		}
+	}
+	return target
+}
+
+// outputUnavailable returns true if an error returned by an output indicates
+// that it is not able to accept messages, as opposed to the message itself
+// having failed.
+func outputUnavailable(err error) bool {
+	return err == types.ErrNotConnected || err == types.ErrTypeClosed
+}
+
+// loop is an internal loop that brokers incoming messages to many outputs.
+func (p *Partition) loop() {
+	defer func() {
+		for _, c := range p.outputTsChans {
+			close(c)
+		}
+		close(p.closedChan)
+	}()
+
+	var (
+		mMsgsRcvd  = p.stats.GetCounter("broker.partition.messages.received")
+		mFallbacks = p.stats.GetCounter("broker.partition.fallback")
+		mErrs      = []metrics.StatCounter{}
+	)
+	for i := range p.outputs {
+		mErrs = append(mErrs, p.stats.GetCounter(fmt.Sprintf("broker.partition.%v.failed", i)))
+	}
+
+	open := false
+	for atomic.LoadInt32(&p.running) == 1 {
+		var ts types.Transaction
+		select {
+		case ts, open = <-p.transactions:
+			if !open {
+				return
+			}
+		case <-p.closeChan:
+			return
+		}
+		mMsgsRcvd.Incr(1)
+
+		key := p.key
+		if p.keyInterp {
+			key = text.ReplaceFunctionVariablesFor(ts.Payload, p.key)
+		}
+
+		if !p.fallback {
+			select {
+			case p.outputTsChans[p.target(key)] <- ts:
+			case <-p.closeChan:
+			}
+			continue
+		}
+
+		var res types.Response
+	triesLoop:
+		for attempt, i := range p.ranking(key) {
+			if attempt > 0 {
+				mFallbacks.Incr(1)
+			}
+
+			// Outputs such as those built on output.Writer block rather than
+			// respond while disconnected, so each attempt is bounded by the
+			// fallback timeout. The response channel is buffered so that an
+			// output that eventually responds after we have moved on is not
+			// blocked.
+			timeout := time.After(p.fallbackTimeout)
+			resChan := make(chan types.Response, 1)
+			select {
+			case p.outputTsChans[i] <- types.NewTransaction(ts.Payload, resChan):
+			case <-timeout:
+				mErrs[i].Incr(1)
+				res = types.NewSimpleResponse(types.ErrTimeout)
+				continue triesLoop
+			case <-p.closeChan:
+				return
+			}
+			select {
+			case res = <-resChan:
+				if res.Error() == nil {
+					break triesLoop
+				}
+				mErrs[i].Incr(1)
+				if !outputUnavailable(res.Error()) {
+					break triesLoop
+				}
+			case <-timeout:
+				mErrs[i].Incr(1)
+				res = types.NewSimpleResponse(types.ErrTimeout)
+			case <-p.closeChan:
+				return
+			}
+		}
+		select {
+		case ts.ResponseChan <- res:
+		case <-p.closeChan:
+			return
+		}
+	}
+}
+
+// CloseAsync shuts down the Partition broker and stops processing requests.
+func (p *Partition) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&p.running, 1, 0) {
+		close(p.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Partition broker has closed down.
+func (p *Partition) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-p.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+// PartitionFanIn is a broker that implements types.Producer, takes an array of
+// inputs and routes them through a single message channel, where a message is
+// not released until all prior messages that share its key, which is extracted
+// from the message, have been acknowledged. Messages of a key are therefore
+// processed in order even when consumed by parallel pipelines.
+//
+// When a message arrives with a key that is already in flight the broker stops
+// reading further messages until that key has been acknowledged.
+type PartitionFanIn struct {
+	running int32
+
+	stats metrics.Type
+
+	key       []byte
+	keyInterp bool
+
+	fanIn        *FanIn
+	transactions chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewPartitionFanIn creates a new PartitionFanIn type by providing inputs and
+// a key that may contain function interpolations.
+func NewPartitionFanIn(
+	inputs []types.Producer,
+	key string,
+	stats metrics.Type,
+) (*PartitionFanIn, error) {
+	if len(key) == 0 {
+		return nil, ErrPartitionNoKey
+	}
+	fanIn, err := NewFanIn(inputs, stats)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes := []byte(key)
+	p := &PartitionFanIn{
+		running:      1,
+		stats:        stats,
+		key:          keyBytes,
+		keyInterp:    text.ContainsFunctionVariables(keyBytes),
+		fanIn:        fanIn,
+		transactions: make(chan types.Transaction),
+		closeChan:    make(chan struct{}),
+		closedChan:   make(chan struct{}),
+	}
+	go p.loop()
+	return p, nil
+}
+
+//------------------------------------------------------------------------------
+
+// TransactionChan returns the channel used for consuming transactions from this
+// broker.
+func (p *PartitionFanIn) TransactionChan() <-chan types.Transaction {
+	return p.transactions
+}
+
+//------------------------------------------------------------------------------
+
+// loop is an internal loop that releases messages of the child inputs once
+// their keys are no longer in flight.
+func (p *PartitionFanIn) loop() {
+	defer func() {
+		close(p.transactions)
+
+		// Drain the child inputs so that they are able to close.
+		for range p.fanIn.TransactionChan() {
+		}
+		close(p.closedChan)
+	}()
+
+	var (
+		mMsgsRcvd = p.stats.GetCounter("broker.partition_fan_in.messages.received")
+		mBlocked  = p.stats.GetCounter("broker.partition_fan_in.blocked")
+	)
+
+	inFlight := map[string]struct{}{}
+	releasedChan := make(chan string)
+
+	// forward passes a response from the pipeline back to the input that
+	// produced the message and then releases the key of the message.
+	forward := func(key string, resChan <-chan types.Response, ts types.Transaction) {
+		var res types.Response
+		select {
+		case res = <-resChan:
+		case <-p.closeChan:
+			return
+		}
+		select {
+		case ts.ResponseChan <- res:
+		case <-p.closeChan:
+			return
+		}
+		select {
+		case releasedChan <- key:
+		case <-p.closedChan:
+		}
+	}
+
+	for {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-p.fanIn.TransactionChan():
+			if !open {
+				return
+			}
+		case <-p.closeChan:
+			return
+		}
+		mMsgsRcvd.Incr(1)
+
+		keyBytes := p.key
+		if p.keyInterp {
+			keyBytes = text.ReplaceFunctionVariablesFor(ts.Payload, p.key)
+		}
+		key := string(keyBytes)
+
+		// Wait for any message of the same key to be acknowledged.
+		if _, exists := inFlight[key]; exists {
+			mBlocked.Incr(1)
+		}
+		for {
+			if _, exists := inFlight[key]; !exists {
+				break
+			}
+			select {
+			case released := <-releasedChan:
+				delete(inFlight, released)
+			case <-p.closeChan:
+				return
+			}
+		}
+
+		inFlight[key] = struct{}{}
+		resChan := make(chan types.Response)
+		newTs := types.NewTransaction(ts.Payload, resChan)
+	sendLoop:
+		for {
+			select {
+			case p.transactions <- newTs:
+				break sendLoop
+			case released := <-releasedChan:
+				delete(inFlight, released)
+			case <-p.closeChan:
+				return
+			}
+		}
+		go forward(key, resChan, ts)
+	}
+}
+
+// CloseAsync shuts down the PartitionFanIn broker and stops processing
+// requests.
+func (p *PartitionFanIn) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&p.running, 1, 0) {
+		close(p.closeChan)
+	}
+	p.fanIn.CloseAsync()
+}
+
+// WaitForClose blocks until the PartitionFanIn broker has closed down.
+func (p *PartitionFanIn) WaitForClose(timeout time.Duration) error {
+	stopBy := time.Now().Add(timeout)
+	select {
+	case <-p.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return p.fanIn.WaitForClose(time.Until(stopBy))
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestPartitionFanInInterfaces(t *testing.T) {
+	f := &PartitionFanIn{}
+	if types.Producer(f) == nil {
+		t.Errorf("PartitionFanIn: nil types.Producer")
+	}
+	if types.Closable(f) == nil {
+		t.Errorf("PartitionFanIn: nil types.Closable")
+	}
+}
+
+func TestPartitionFanInNoKey(t *testing.T) {
+	if _, err := NewPartitionFanIn([]types.Producer{}, "", metrics.DudType{}); err != ErrPartitionNoKey {
+		t.Errorf("Wrong error returned: %v != %v", err, ErrPartitionNoKey)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestPartitionFanInOrdering(t *testing.T) {
+	mockInputs := []*MockInputType{
+		{TChan: make(chan types.Transaction)},
+		{TChan: make(chan types.Transaction)},
+	}
+	inputs := []types.Producer{mockInputs[0], mockInputs[1]}
+
+	p, err := NewPartitionFanIn(inputs, "${!content}", metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(index int, content string) chan types.Response {
+		resChan := make(chan types.Response, 1)
+		select {
+		case mockInputs[index].TChan <- types.NewTransaction(
+			types.NewMessage([][]byte{[]byte(content)}), resChan,
+		):
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for broker send: %v", content)
+		}
+		return resChan
+	}
+	receive := func(content string) types.Transaction {
+		var ts types.Transaction
+		select {
+		case ts = <-p.TransactionChan():
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for broker propagate: %v", content)
+		}
+		if act := string(ts.Payload.Get(0)); act != content {
+			t.Errorf("Wrong content returned %s != %s", act, content)
+		}
+		return ts
+	}
+
+	firstRes := send(0, "foo")
+	firstTs := receive("foo")
+
+	// A different key is released while foo is in flight.
+	secondRes := send(1, "bar")
+	secondTs := receive("bar")
+
+	// Another message of key foo is held until the first is acknowledged.
+	thirdRes := send(1, "foo")
+	select {
+	case <-p.TransactionChan():
+		t.Fatal("Message released while its key was in flight")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	select {
+	case firstTs.ResponseChan <- types.NewSimpleResponse(nil):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+	select {
+	case res := <-firstRes:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response to input")
+	}
+
+	thirdTs := receive("foo")
+	for _, pair := range []struct {
+		ts      types.Transaction
+		resChan chan types.Response
+	}{
+		{secondTs, secondRes},
+		{thirdTs, thirdRes},
+	} {
+		select {
+		case pair.ts.ResponseChan <- types.NewSimpleResponse(nil):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+		select {
+		case res := <-pair.resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response to input")
+		}
+	}
+
+	p.CloseAsync()
+	if err = p.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPartitionFanInCloseWhileBlocked(t *testing.T) {
+	mockInputs := []*MockInputType{
+		{TChan: make(chan types.Transaction)},
+		{TChan: make(chan types.Transaction)},
+	}
+	inputs := []types.Producer{mockInputs[0], mockInputs[1]}
+
+	p, err := NewPartitionFanIn(inputs, "static", metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range mockInputs {
+		select {
+		case mockInputs[i].TChan <- types.NewTransaction(
+			types.NewMessage([][]byte{[]byte("foo")}), make(chan types.Response),
+		):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker send")
+		}
+	}
+
+	select {
+	case <-p.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker propagate")
+	}
+
+	p.CloseAsync()
+	if err = p.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
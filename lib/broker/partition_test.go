@@ -0,0 +1,298 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestPartitionInterfaces(t *testing.T) {
+	f := &Partition{}
+	if types.Consumer(f) == nil {
+		t.Errorf("Partition: nil types.Consumer")
+	}
+	if types.Closable(f) == nil {
+		t.Errorf("Partition: nil types.Closable")
+	}
+}
+
+func TestPartitionDoubleClose(t *testing.T) {
+	oTM, err := NewPartition([]types.Output{}, "foo", false, time.Second, metrics.DudType{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// This shouldn't cause a panic
+	oTM.CloseAsync()
+	oTM.CloseAsync()
+}
+
+func TestPartitionNoKey(t *testing.T) {
+	if _, err := NewPartition([]types.Output{}, "", false, time.Second, metrics.DudType{}); err != ErrPartitionNoKey {
+		t.Errorf("Wrong error returned: %v != %v", err, ErrPartitionNoKey)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestPartitionConsistentRouting(t *testing.T) {
+	outputs := []types.Output{}
+	mockOutputs := []*MockOutputType{
+		{}, {}, {}, {},
+	}
+	for _, o := range mockOutputs {
+		outputs = append(outputs, o)
+	}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewPartition(outputs, "${!json_field:id}", false, time.Second, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = oTM.StartReceiving(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	keyOutputs := map[string]int{}
+	outputsHit := map[int]struct{}{}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key%v", i%20)
+		content := [][]byte{[]byte(fmt.Sprintf(`{"id":"%v","n":%v}`, key, i))}
+		select {
+		case readChan <- types.NewTransaction(types.NewMessage(content), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker send")
+		}
+
+		var ts types.Transaction
+		index := -1
+		select {
+		case ts = <-mockOutputs[0].TChan:
+			index = 0
+		case ts = <-mockOutputs[1].TChan:
+			index = 1
+		case ts = <-mockOutputs[2].TChan:
+			index = 2
+		case ts = <-mockOutputs[3].TChan:
+			index = 3
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker propagate")
+		}
+		if string(ts.Payload.Get(0)) != string(content[0]) {
+			t.Errorf("Wrong content returned %s != %s", ts.Payload.Get(0), content[0])
+		}
+
+		if prev, exists := keyOutputs[key]; exists && prev != index {
+			t.Errorf("Key %v routed to output %v, previously %v", key, index, prev)
+		}
+		keyOutputs[key] = index
+		outputsHit[index] = struct{}{}
+
+		// Without fallback the original transaction is forwarded.
+		go func() {
+			select {
+			case ts.ResponseChan <- types.NewSimpleResponse(nil):
+			case <-time.After(time.Second):
+				t.Errorf("Timed out responding to broker")
+			}
+		}()
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	if len(outputsHit) < 2 {
+		t.Errorf("Expected keys to be spread across outputs, hit: %v", outputsHit)
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPartitionRankingStable(t *testing.T) {
+	outputs := []types.Output{}
+	for i := 0; i < 5; i++ {
+		outputs = append(outputs, &MockOutputType{})
+	}
+
+	small, err := NewPartition(outputs[:4], "foo", false, time.Second, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := NewPartition(outputs, "foo", false, time.Second, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding an output should only move keys to the new output.
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%v", i))
+		if exp, act := small.target(key), large.target(key); act != 4 && exp != act {
+			t.Errorf("Key %s moved from %v to %v", key, exp, act)
+		}
+		if exp, act := large.target(key), large.ranking(key)[0]; exp != act {
+			t.Errorf("Ranking does not match target: %v != %v", act, exp)
+		}
+	}
+
+	small.CloseAsync()
+	large.CloseAsync()
+}
+
+func TestPartitionFallback(t *testing.T) {
+	outputs := []types.Output{}
+	mockOutputs := []*MockOutputType{
+		{}, {}, {},
+	}
+	for _, o := range mockOutputs {
+		outputs = append(outputs, o)
+	}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewPartition(outputs, "${!content}", true, time.Second, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = oTM.StartReceiving(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	content := [][]byte{[]byte("hello world")}
+	ranking := oTM.ranking(content[0])
+
+	select {
+	case readChan <- types.NewTransaction(types.NewMessage(content), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+
+	for i, index := range ranking {
+		var ts types.Transaction
+		select {
+		case ts = <-mockOutputs[index].TChan:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for output %v", index)
+		}
+		if string(ts.Payload.Get(0)) != string(content[0]) {
+			t.Errorf("Wrong content returned %s != %s", ts.Payload.Get(0), content[0])
+		}
+		var resErr error
+		if i < len(ranking)-1 {
+			resErr = types.ErrNotConnected
+		}
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(resErr):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	// When all outputs are unavailable the last error is returned.
+	select {
+	case readChan <- types.NewTransaction(types.NewMessage(content), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+	for _, index := range ranking {
+		var ts types.Transaction
+		select {
+		case ts = <-mockOutputs[index].TChan:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for output %v", index)
+		}
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(types.ErrTypeClosed):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != types.ErrTypeClosed {
+			t.Errorf("Wrong error returned: %v != %v", res.Error(), types.ErrTypeClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	// Errors from an available output do not fall back.
+	select {
+	case readChan <- types.NewTransaction(types.NewMessage(content), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+	var ts types.Transaction
+	select {
+	case ts = <-mockOutputs[ranking[0]].TChan:
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for output %v", ranking[0])
+	}
+	select {
+	case ts.ResponseChan <- types.NewSimpleResponse(errors.New("nope")):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() == nil || res.Error().Error() != "nope" {
+			t.Errorf("Expected error from failed output: %v", res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
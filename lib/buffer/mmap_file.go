@@ -41,7 +41,11 @@ directory as it fills them.
 
 When files are fully read from they will be deleted. You can disable this
 feature if you wish to preserve the data indefinitely, but the directory will
-fill up as fast as data passes through.`,
+fill up as fast as data passes through.
+
+Messages are stored along with their creation time and the metadata of each
+part, and are checksummed in order to detect corruption. Files written by
+older versions of Benthos can still be read.`,
 	}
 }
 
@@ -294,7 +294,7 @@ func (f *MmapBuffer) NextMessage() (types.Message, error) {
 		return nil, types.ErrBlockCorrupted
 	}
 
-	return types.FromBytes(block[index : index+int(msgSize)])
+	return types.Decode(block[index : index+int(msgSize)])
 }
 
 // PushMessage pushes a new message, returns the backlog count.
@@ -306,7 +306,7 @@ func (f *MmapBuffer) PushMessage(msg types.Message) (int, error) {
 		f.cache.L.Unlock()
 	}()
 
-	blob := msg.Bytes()
+	blob := types.EncodeWithChecksum(msg)
 	index := f.writtenTo
 
 	if len(blob)+4 > f.config.FileSize {
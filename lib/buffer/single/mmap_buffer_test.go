@@ -115,13 +115,15 @@ func TestMmapBufferBacklogCounter(t *testing.T) {
 	defer block.Close()
 
 	if _, err := block.PushMessage(types.NewMessage(
-		[][]byte{[]byte("1234")}, // 4 bytes + 4 bytes
+		[][]byte{[]byte("1234")}, // 4 bytes + 4 bytes + 4 bytes
 	)); err != nil {
 		t.Error(err)
 		return
 	}
 
-	if expected, actual := 16, block.backlog(); expected != actual {
+	// Each message has 4 bytes of size, 18 bytes of header and 4 bytes of
+	// checksum.
+	if expected, actual := 38, block.backlog(); expected != actual {
 		t.Errorf("Wrong backlog count: %v != %v", expected, actual)
 	}
 
@@ -129,13 +131,13 @@ func TestMmapBufferBacklogCounter(t *testing.T) {
 		[][]byte{
 			[]byte("1234"),
 			[]byte("1234"),
-		}, // ( 4 bytes + 4 bytes ) * 2
+		}, // ( 4 bytes + 4 bytes + 4 bytes ) * 2
 	)); err != nil {
 		t.Error(err)
 		return
 	}
 
-	if expected, actual := 40, block.backlog(); expected != actual {
+	if expected, actual := 88, block.backlog(); expected != actual {
 		t.Errorf("Wrong backlog count: %v != %v", expected, actual)
 	}
 
@@ -144,7 +146,7 @@ func TestMmapBufferBacklogCounter(t *testing.T) {
 		return
 	}
 
-	if expected, actual := 24, block.backlog(); expected != actual {
+	if expected, actual := 50, block.backlog(); expected != actual {
 		t.Errorf("Wrong backlog count: %v != %v", expected, actual)
 	}
 
@@ -336,6 +338,54 @@ func TestMmapBufferRecoverFiles(t *testing.T) {
 	block.Close()
 }
 
+func TestMmapBufferRecoverContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_test_")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer cleanUpMmapDir(dir)
+
+	conf := NewMmapBufferConfig()
+	conf.FileSize = 1000
+	conf.Path = dir
+
+	block, err := NewMmapBuffer(conf, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	msg := types.NewMessage([][]byte{[]byte("hello"), []byte("world")})
+	msg.GetMetadata(1).Set("foo", "bar")
+	if _, err = block.PushMessage(msg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	block.Close()
+
+	block, err = NewMmapBuffer(conf, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer block.Close()
+
+	m, err := block.NextMessage()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if expected, actual := "bar", m.GetMetadata(1).Get("foo"); expected != actual {
+		t.Errorf("Wrong metadata, %v != %v", expected, actual)
+	}
+	if expected, actual := msg.CreatedAt(), m.CreatedAt(); !expected.Equal(actual) {
+		t.Errorf("Wrong created at, %v != %v", expected, actual)
+	}
+}
+
 func TestMmapBufferRejectLargeMessage(t *testing.T) {
 	dir, err := ioutil.TempDir("", "benthos_test_")
 	if err != nil {
@@ -48,16 +48,20 @@ var Constructors = map[string]TypeSpec{}
 // Config is the all encompassing configuration struct for all cache types.
 type Config struct {
 	Type      string          `json:"type" yaml:"type"`
+	File      FileConfig      `json:"file" yaml:"file"`
 	Memcached MemcachedConfig `json:"memcached" yaml:"memcached"`
 	Memory    MemoryConfig    `json:"memory" yaml:"memory"`
+	Redis     RedisConfig     `json:"redis" yaml:"redis"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
 		Type:      "memory",
+		File:      NewFileConfig(),
 		Memcached: NewMemcachedConfig(),
 		Memory:    NewMemoryConfig(),
+		Redis:     NewRedisConfig(),
 	}
 }
 
@@ -134,7 +138,15 @@ resources:
 In that example we have a single memcached based cache 'foobar', which is used
 by the dedupe processors of both the 'foo' and 'bar' inputs. A message received
 from both 'foo' and 'bar' would therefore be detected and removed since the
-cache is the same for both inputs.`
+cache is the same for both inputs.
+
+Caches can also be read from and written to directly with the
+[` + "`cache`" + ` processor](../processors/README.md#cache).
+
+The keys of a cache resource can be inspected and edited at runtime through the
+HTTP API endpoint ` + "`/caches/{name}/{key}`" + `, where ` + "`GET`" + ` returns the
+value of a key, ` + "`PUT`" + ` sets the value of a key to the request body and
+` + "`DELETE`" + ` removes a key.`
 
 // Descriptions returns a formatted string of descriptions for each type.
 func Descriptions() string {
@@ -0,0 +1,423 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/disk"
+	bolt "go.etcd.io/bbolt"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["file"] = TypeSpec{
+		constructor: NewFile,
+		description: `
+The file cache stores key/value pairs in a single embedded database file on
+the local disk, which allows the contents of the cache to survive restarts of
+the service.
+
+Each item in the cache has a TTL set from the moment it was last edited, after
+which it can no longer be read. Expired items are removed from the file by a
+sweep, which runs in the background after a write where the time since the
+last sweep is above the sweep interval. A TTL of zero means items never expire.
+
+Writes from concurrent callers are combined into batches of up to
+` + "`batch_max_size`" + ` writes, waiting at most ` + "`batch_max_delay_ms`" + `
+for a batch to fill before it is committed.
+
+When ` + "`integrity_check`" + ` is enabled the database file is checked for
+consistency when the cache is created, and the service fails to start if
+problems are found. Writes fail when the free space remaining on the disk
+drops below ` + "`reserved_disk_space`" + ` bytes.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Errors for the File cache type.
+var (
+	ErrNotEnoughSpace = errors.New("not enough disk space remaining for cache")
+)
+
+//------------------------------------------------------------------------------
+
+// FileConfig contains config fields for the File cache type.
+type FileConfig struct {
+	Path              string `json:"path" yaml:"path"`
+	Bucket            string `json:"bucket" yaml:"bucket"`
+	TTL               int    `json:"ttl" yaml:"ttl"`
+	SweepIntervalS    int    `json:"sweep_interval_s" yaml:"sweep_interval_s"`
+	BatchMaxSize      int    `json:"batch_max_size" yaml:"batch_max_size"`
+	BatchMaxDelayMS   int    `json:"batch_max_delay_ms" yaml:"batch_max_delay_ms"`
+	IntegrityCheck    bool   `json:"integrity_check" yaml:"integrity_check"`
+	ReservedDiskSpace uint64 `json:"reserved_disk_space" yaml:"reserved_disk_space"`
+}
+
+// NewFileConfig creates a FileConfig populated with default values.
+func NewFileConfig() FileConfig {
+	return FileConfig{
+		Path:              "benthos_cache.db",
+		Bucket:            "benthos",
+		TTL:               300, // 5 Mins
+		SweepIntervalS:    60,
+		BatchMaxSize:      1000,
+		BatchMaxDelayMS:   10,
+		IntegrityCheck:    true,
+		ReservedDiskSpace: 100 * 1024 * 1024, // 100MiB
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// File is a cache implementation that stores items in an embedded database
+// file.
+type File struct {
+	lastSweep int64
+	sweeping  int32
+	running   int32
+
+	conf   FileConfig
+	log    log.Modular
+	db     *bolt.DB
+	bucket []byte
+	ttl    time.Duration
+
+	sweepInterval time.Duration
+
+	closedChan chan struct{}
+
+	mGetCount     metrics.StatCounter
+	mGetSuccess   metrics.StatCounter
+	mGetNotFound  metrics.StatCounter
+	mGetFailed    metrics.StatCounter
+	mSetCount     metrics.StatCounter
+	mSetSuccess   metrics.StatCounter
+	mSetFailed    metrics.StatCounter
+	mAddCount     metrics.StatCounter
+	mAddSuccess   metrics.StatCounter
+	mAddDupe      metrics.StatCounter
+	mAddFailed    metrics.StatCounter
+	mDelCount     metrics.StatCounter
+	mDelSuccess   metrics.StatCounter
+	mDelFailed    metrics.StatCounter
+	mSweepSuccess metrics.StatCounter
+	mSweepFailed  metrics.StatCounter
+	mExpired      metrics.StatCounter
+}
+
+// NewFile creates a new File cache type.
+func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error) {
+	if len(conf.File.Path) == 0 {
+		return nil, errors.New("a path must be specified")
+	}
+	if len(conf.File.Bucket) == 0 {
+		return nil, errors.New("a bucket must be specified")
+	}
+
+	db, err := bolt.Open(conf.File.Path, 0600, &bolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache file: %v", err)
+	}
+	db.MaxBatchSize = conf.File.BatchMaxSize
+	db.MaxBatchDelay = time.Millisecond * time.Duration(conf.File.BatchMaxDelayMS)
+
+	f := &File{
+		lastSweep:     time.Now().UnixNano(),
+		running:       1,
+		conf:          conf.File,
+		log:           log.NewModule(".cache.file"),
+		db:            db,
+		bucket:        []byte(conf.File.Bucket),
+		ttl:           time.Second * time.Duration(conf.File.TTL),
+		sweepInterval: time.Second * time.Duration(conf.File.SweepIntervalS),
+		closedChan:    make(chan struct{}),
+
+		mGetCount:     stats.GetCounter("cache.file.get.count"),
+		mGetSuccess:   stats.GetCounter("cache.file.get.success"),
+		mGetNotFound:  stats.GetCounter("cache.file.get.failed.not_found"),
+		mGetFailed:    stats.GetCounter("cache.file.get.failed.error"),
+		mSetCount:     stats.GetCounter("cache.file.set.count"),
+		mSetSuccess:   stats.GetCounter("cache.file.set.success"),
+		mSetFailed:    stats.GetCounter("cache.file.set.failed.error"),
+		mAddCount:     stats.GetCounter("cache.file.add.count"),
+		mAddSuccess:   stats.GetCounter("cache.file.add.success"),
+		mAddDupe:      stats.GetCounter("cache.file.add.failed.duplicate"),
+		mAddFailed:    stats.GetCounter("cache.file.add.failed.error"),
+		mDelCount:     stats.GetCounter("cache.file.delete.count"),
+		mDelSuccess:   stats.GetCounter("cache.file.delete.success"),
+		mDelFailed:    stats.GetCounter("cache.file.delete.failed.error"),
+		mSweepSuccess: stats.GetCounter("cache.file.sweep.success"),
+		mSweepFailed:  stats.GetCounter("cache.file.sweep.failed.error"),
+		mExpired:      stats.GetCounter("cache.file.expired"),
+	}
+
+	if conf.File.IntegrityCheck {
+		if err = f.check(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, berr := tx.CreateBucketIfNotExists(f.bucket)
+		return berr
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create bucket: %v", err)
+	}
+	return f, nil
+}
+
+//------------------------------------------------------------------------------
+
+// check verifies the consistency of the database file.
+func (f *File) check() error {
+	return f.db.View(func(tx *bolt.Tx) error {
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		for _, err := range errs {
+			f.log.Errorf("Integrity check error: %v\n", err)
+		}
+		return fmt.Errorf("integrity check of cache file found %v errors, first: %v", len(errs), errs[0])
+	})
+}
+
+// Items are stored with their last edit time prefixed to the value as a big
+// endian unix nanosecond timestamp.
+func (f *File) encode(value []byte) []byte {
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()))
+	copy(b[8:], value)
+	return b
+}
+
+func (f *File) expired(stored []byte) bool {
+	if len(stored) < 8 {
+		return true
+	}
+	if f.ttl <= 0 {
+		return false
+	}
+	ts := time.Unix(0, int64(binary.BigEndian.Uint64(stored)))
+	return time.Since(ts) >= f.ttl
+}
+
+func (f *File) checkSpace() error {
+	if f.conf.ReservedDiskSpace == 0 {
+		return nil
+	}
+	if disk.TotalRemaining(filepath.Dir(f.conf.Path)) < f.conf.ReservedDiskSpace {
+		return ErrNotEnoughSpace
+	}
+	return nil
+}
+
+// sweep triggers a background removal of expired items if the time since the
+// last sweep is above the sweep interval and a sweep is not already running.
+func (f *File) sweep() {
+	if f.ttl <= 0 || atomic.LoadInt32(&f.running) == 0 {
+		return
+	}
+	last := time.Unix(0, atomic.LoadInt64(&f.lastSweep))
+	if time.Since(last) < f.sweepInterval {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&f.sweeping, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&f.sweeping, 0)
+		var removed int64
+		err := f.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(f.bucket)
+
+			var expired [][]byte
+			b.ForEach(func(k, v []byte) error {
+				if f.expired(v) {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			removed = int64(len(expired))
+			return nil
+		})
+		if err != nil {
+			f.mSweepFailed.Incr(1)
+			f.log.Errorf("Failed to sweep expired items: %v\n", err)
+		} else {
+			f.mSweepSuccess.Incr(1)
+			f.mExpired.Incr(removed)
+		}
+		atomic.StoreInt64(&f.lastSweep, time.Now().UnixNano())
+	}()
+}
+
+//------------------------------------------------------------------------------
+
+// Get attempts to locate and return a cached value by its key, returns an error
+// if the key does not exist.
+func (f *File) Get(key string) ([]byte, error) {
+	f.mGetCount.Incr(1)
+	var value []byte
+	err := f.db.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(f.bucket).Get([]byte(key))
+		if stored == nil || f.expired(stored) {
+			return types.ErrKeyNotFound
+		}
+		// Values are only valid for the life of the transaction.
+		value = make([]byte, len(stored)-8)
+		copy(value, stored[8:])
+		return nil
+	})
+	if err != nil {
+		if err == types.ErrKeyNotFound {
+			f.mGetNotFound.Incr(1)
+		} else {
+			f.mGetFailed.Incr(1)
+		}
+		return nil, err
+	}
+	f.mGetSuccess.Incr(1)
+	return value, nil
+}
+
+// Set attempts to set the value of a key.
+func (f *File) Set(key string, value []byte) error {
+	f.mSetCount.Incr(1)
+	err := f.checkSpace()
+	if err == nil {
+		stored := f.encode(value)
+		err = f.db.Batch(func(tx *bolt.Tx) error {
+			return tx.Bucket(f.bucket).Put([]byte(key), stored)
+		})
+	}
+	if err != nil {
+		f.mSetFailed.Incr(1)
+		return err
+	}
+	f.mSetSuccess.Incr(1)
+	f.sweep()
+	return nil
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// and returns an error if the key already exists.
+func (f *File) Add(key string, value []byte) error {
+	f.mAddCount.Incr(1)
+
+	// A failed function causes its batch to be retried, therefore we check for
+	// duplicates before joining a batch.
+	err := f.db.View(func(tx *bolt.Tx) error {
+		if existing := tx.Bucket(f.bucket).Get([]byte(key)); existing != nil && !f.expired(existing) {
+			return types.ErrKeyAlreadyExists
+		}
+		return nil
+	})
+	if err == nil {
+		err = f.checkSpace()
+	}
+	if err == nil {
+		stored := f.encode(value)
+		err = f.db.Batch(func(tx *bolt.Tx) error {
+			b := tx.Bucket(f.bucket)
+			if existing := b.Get([]byte(key)); existing != nil && !f.expired(existing) {
+				return types.ErrKeyAlreadyExists
+			}
+			return b.Put([]byte(key), stored)
+		})
+	}
+	if err != nil {
+		if err == types.ErrKeyAlreadyExists {
+			f.mAddDupe.Incr(1)
+		} else {
+			f.mAddFailed.Incr(1)
+		}
+		return err
+	}
+	f.mAddSuccess.Incr(1)
+	f.sweep()
+	return nil
+}
+
+// Delete attempts to remove a key.
+func (f *File) Delete(key string) error {
+	f.mDelCount.Incr(1)
+	err := f.db.Batch(func(tx *bolt.Tx) error {
+		return tx.Bucket(f.bucket).Delete([]byte(key))
+	})
+	if err != nil {
+		f.mDelFailed.Incr(1)
+		return err
+	}
+	f.mDelSuccess.Incr(1)
+	f.sweep()
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down the cache and closes the database file once any
+// ongoing operations have finished.
+func (f *File) CloseAsync() {
+	if !atomic.CompareAndSwapInt32(&f.running, 1, 0) {
+		return
+	}
+	go func() {
+		if err := f.db.Close(); err != nil {
+			f.log.Errorf("Failed to close cache file: %v\n", err)
+		}
+		close(f.closedChan)
+	}()
+}
+
+// WaitForClose blocks until the cache has closed down.
+func (f *File) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-f.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
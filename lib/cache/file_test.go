@@ -0,0 +1,285 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+//------------------------------------------------------------------------------
+
+func TestFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "file"
+	conf.File.Path = filepath.Join(dir, "cache.db")
+	conf.File.ReservedDiskSpace = 0
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expErr := types.ErrKeyNotFound
+	if _, act := c.Get("foo"); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Error(err)
+	}
+
+	exp := "1"
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	if err = c.Add("bar", []byte("2")); err != nil {
+		t.Error(err)
+	}
+
+	exp = "2"
+	if act, err := c.Get("bar"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	expErr = types.ErrKeyAlreadyExists
+	if act := c.Add("foo", []byte("2")); expErr != act {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+
+	if err = c.Set("foo", []byte("3")); err != nil {
+		t.Error(err)
+	}
+
+	exp = "3"
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	if err = c.Delete("foo"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+
+	// Items should survive the cache being reopened.
+	if err = c.(*File).db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = New(conf, nil, testLog, metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.(*File).db.Close()
+
+	exp = "2"
+	if act, err := c.Get("bar"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+}
+
+func TestFileCacheClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "file"
+	conf.File.Path = filepath.Join(dir, "cache.db")
+	conf.File.ReservedDiskSpace = 0
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	closable, ok := c.(types.Closable)
+	if !ok {
+		t.Fatal("File cache does not implement types.Closable")
+	}
+	closable.CloseAsync()
+	if err = closable.WaitForClose(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file lock is released, allowing a new cache to open the file.
+	if c, err = New(conf, nil, testLog, metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.(types.Closable).CloseAsync()
+
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp := "1"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+}
+
+func TestFileCacheTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "file"
+	conf.File.Path = filepath.Join(dir, "cache.db")
+	conf.File.ReservedDiskSpace = 0
+	conf.File.SweepIntervalS = 0
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := c.(*File)
+	defer f.db.Close()
+
+	f.ttl = time.Millisecond * 50
+
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Get("foo"); err != nil {
+		t.Error(err)
+	}
+
+	<-time.After(time.Millisecond * 100)
+
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+
+	// An expired key can be added again.
+	if err = c.Add("foo", []byte("2")); err != nil {
+		t.Error(err)
+	}
+	<-time.After(time.Millisecond * 100)
+
+	// This write should trigger a sweep that removes foo.
+	if err = c.Set("bar", []byte("3")); err != nil {
+		t.Error(err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		var stored []byte
+		f.db.View(func(tx *bolt.Tx) error {
+			stored = tx.Bucket(f.bucket).Get([]byte("foo"))
+			return nil
+		})
+		if stored == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expired key was not swept")
+		}
+		<-time.After(time.Millisecond * 10)
+	}
+}
+
+func TestFileCacheDiskSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "file"
+	conf.File.Path = filepath.Join(dir, "cache.db")
+	conf.File.ReservedDiskSpace = ^uint64(0)
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.(*File).db.Close()
+
+	if err = c.Set("foo", []byte("1")); err != ErrNotEnoughSpace {
+		t.Errorf("Wrong error returned: %v != %v", err, ErrNotEnoughSpace)
+	}
+	if err = c.Add("foo", []byte("1")); err != ErrNotEnoughSpace {
+		t.Errorf("Wrong error returned: %v != %v", err, ErrNotEnoughSpace)
+	}
+}
+
+func TestFileCacheBadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	path := filepath.Join(dir, "cache.db")
+	if err = ioutil.WriteFile(path, []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.Type = "file"
+	conf.File.Path = path
+
+	if _, err = New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from corrupt file")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -75,6 +75,7 @@ type Memcached struct {
 	mGetCount      metrics.StatCounter
 	mGetRetry      metrics.StatCounter
 	mGetFailed     metrics.StatCounter
+	mGetNotFound   metrics.StatCounter
 	mGetSuccess    metrics.StatCounter
 	mSetCount      metrics.StatCounter
 	mSetRetry      metrics.StatCounter
@@ -115,6 +116,7 @@ func NewMemcached(
 		mGetCount:      stats.GetCounter("cache.memcached.get.count"),
 		mGetRetry:      stats.GetCounter("cache.memcached.get.retry"),
 		mGetFailed:     stats.GetCounter("cache.memcached.get.failed.error"),
+		mGetNotFound:   stats.GetCounter("cache.memcached.get.failed.not_found"),
 		mGetSuccess:    stats.GetCounter("cache.memcached.get.success"),
 		mSetCount:      stats.GetCounter("cache.memcached.set.count"),
 		mSetRetry:      stats.GetCounter("cache.memcached.set.retry"),
@@ -153,11 +155,15 @@ func (m *Memcached) Get(key string) ([]byte, error) {
 	m.mGetCount.Incr(1)
 
 	item, err := m.mc.Get(m.conf.Memcached.Prefix + key)
-	for i := 0; i < m.conf.Memcached.Retries && err != nil; i++ {
+	for i := 0; i < m.conf.Memcached.Retries && err != nil && err != memcache.ErrCacheMiss; i++ {
 		<-time.After(m.retryPeriod)
 		m.mGetRetry.Incr(1)
 		item, err = m.mc.Get(m.conf.Memcached.Prefix + key)
 	}
+	if err == memcache.ErrCacheMiss {
+		m.mGetNotFound.Incr(1)
+		return nil, types.ErrKeyNotFound
+	}
 	if err != nil {
 		m.mGetFailed.Incr(1)
 		return nil, err
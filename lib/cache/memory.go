@@ -21,7 +21,12 @@
 package cache
 
 import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Jeffail/benthos/lib/metrics"
@@ -38,11 +43,25 @@ func init() {
 The memory cache simply stores key/value pairs in a map held in memory. This
 cache is therefore reset every time the service restarts. Each item in the cache
 has a TTL set from the moment it was last edited, after which it will be removed
-during the next compaction.
+during the next compaction. The TTL is the same for all items of the cache, as
+setting a TTL per key is not supported.
 
 A compaction only occurs during a write where the time since the last compaction
 is above the compaction interval. It is therefore possible to obtain values of
-keys that have expired between compactions.`,
+keys that have expired between compactions.
+
+The size of the cache can be bounded with ` + "`max_items`" + `, which limits the
+number of keys held, and ` + "`max_bytes`" + `, which limits the total size of
+keys and values held. A limit of zero means unbounded. When a write would exceed
+a limit other items are evicted according to the ` + "`eviction`" + ` policy,
+which can be either ` + "`lru`" + ` (least recently used) or ` + "`lfu`" + `
+(least frequently used). Writing a single item that is larger than the byte
+limit of a shard results in an error.
+
+Items are spread across a number of ` + "`shards`" + `, each with its own lock,
+in order to reduce contention when the cache is shared by many threads. Limits
+are divided evenly between shards and eviction occurs within a shard, so when
+more than one shard is used the limits are approximate.`,
 	}
 }
 
@@ -50,8 +69,12 @@ keys that have expired between compactions.`,
 
 // MemoryConfig contains config fields for the Memory cache type.
 type MemoryConfig struct {
-	TTL                 int `json:"ttl" yaml:"ttl"`
-	CompactionIntervalS int `json:"compaction_interval_s" yaml:"compaction_interval_s"`
+	TTL                 int    `json:"ttl" yaml:"ttl"`
+	CompactionIntervalS int    `json:"compaction_interval_s" yaml:"compaction_interval_s"`
+	MaxItems            int    `json:"max_items" yaml:"max_items"`
+	MaxBytes            int    `json:"max_bytes" yaml:"max_bytes"`
+	Eviction            string `json:"eviction" yaml:"eviction"`
+	Shards              int    `json:"shards" yaml:"shards"`
 }
 
 // NewMemoryConfig creates a MemoryConfig populated with default values.
@@ -59,89 +82,313 @@ func NewMemoryConfig() MemoryConfig {
 	return MemoryConfig{
 		TTL:                 300, // 5 Mins
 		CompactionIntervalS: 60,
+		MaxItems:            0,
+		MaxBytes:            0,
+		Eviction:            "lru",
+		Shards:              4,
 	}
 }
 
 //------------------------------------------------------------------------------
 
+var errItemTooLarge = errors.New("item exceeds the byte limit of the cache")
+
 type item struct {
+	key   string
 	value []byte
 	ts    time.Time
+
+	// Fields used for determining eviction order.
+	lastUsed uint64
+	uses     uint64
+	index    int
+}
+
+func (i *item) size() int {
+	return len(i.key) + len(i.value)
+}
+
+// itemHeap orders items so that the next item to be evicted is at the root.
+type itemHeap struct {
+	items []*item
+	lfu   bool
+}
+
+func (h *itemHeap) Len() int { return len(h.items) }
+
+func (h *itemHeap) Less(i, j int) bool {
+	if h.lfu && h.items[i].uses != h.items[j].uses {
+		return h.items[i].uses < h.items[j].uses
+	}
+	return h.items[i].lastUsed < h.items[j].lastUsed
+}
+
+func (h *itemHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *itemHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.index = len(h.items)
+	h.items = append(h.items, it)
+}
+
+func (h *itemHeap) Pop() interface{} {
+	n := len(h.items)
+	it := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	it.index = -1
+	return it
+}
+
+//------------------------------------------------------------------------------
+
+// shard is an independently locked portion of a Memory cache.
+type shard struct {
+	items map[string]*item
+	order *itemHeap
+	bytes int
+	tick  uint64
+	sync.Mutex
 }
 
 // Memory is a memory based cache implementation.
 type Memory struct {
-	items          map[string]item
-	ttl            time.Duration
-	compInterval   time.Duration
-	lastCompaction time.Time
-	sync.RWMutex
+	lastCompaction int64
+
+	shards       []*shard
+	ttl          time.Duration
+	compInterval time.Duration
+	maxItems     int
+	maxBytes     int
+
+	compMut sync.Mutex
+
+	mGetCount    metrics.StatCounter
+	mGetSuccess  metrics.StatCounter
+	mGetNotFound metrics.StatCounter
+	mSetCount    metrics.StatCounter
+	mSetSuccess  metrics.StatCounter
+	mSetFailed   metrics.StatCounter
+	mAddCount    metrics.StatCounter
+	mAddSuccess  metrics.StatCounter
+	mAddDupe     metrics.StatCounter
+	mAddFailed   metrics.StatCounter
+	mDelCount    metrics.StatCounter
+	mDelSuccess  metrics.StatCounter
+	mEvicted     metrics.StatCounter
+	mExpired     metrics.StatCounter
 }
 
 // NewMemory creates a new Memory cache type.
 func NewMemory(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error) {
-	return &Memory{
-		items:          map[string]item{},
-		ttl:            time.Second * time.Duration(conf.Memory.TTL),
-		compInterval:   time.Second * time.Duration(conf.Memory.CompactionIntervalS),
-		lastCompaction: time.Now(),
-	}, nil
+	var lfu bool
+	switch conf.Memory.Eviction {
+	case "lru":
+	case "lfu":
+		lfu = true
+	default:
+		return nil, fmt.Errorf("eviction policy not recognised: %v", conf.Memory.Eviction)
+	}
+
+	nShards := conf.Memory.Shards
+	if nShards <= 0 {
+		nShards = 1
+	}
+
+	// Limits are divided evenly between shards, rounding up.
+	maxItems, maxBytes := conf.Memory.MaxItems, conf.Memory.MaxBytes
+	if maxItems > 0 {
+		maxItems = (maxItems + nShards - 1) / nShards
+	}
+	if maxBytes > 0 {
+		maxBytes = (maxBytes + nShards - 1) / nShards
+	}
+
+	m := &Memory{
+		ttl:          time.Second * time.Duration(conf.Memory.TTL),
+		compInterval: time.Second * time.Duration(conf.Memory.CompactionIntervalS),
+		maxItems:     maxItems,
+		maxBytes:     maxBytes,
+
+		lastCompaction: time.Now().UnixNano(),
+
+		mGetCount:    stats.GetCounter("cache.memory.get.count"),
+		mGetSuccess:  stats.GetCounter("cache.memory.get.success"),
+		mGetNotFound: stats.GetCounter("cache.memory.get.failed.not_found"),
+		mSetCount:    stats.GetCounter("cache.memory.set.count"),
+		mSetSuccess:  stats.GetCounter("cache.memory.set.success"),
+		mSetFailed:   stats.GetCounter("cache.memory.set.failed.error"),
+		mAddCount:    stats.GetCounter("cache.memory.add.count"),
+		mAddSuccess:  stats.GetCounter("cache.memory.add.success"),
+		mAddDupe:     stats.GetCounter("cache.memory.add.failed.duplicate"),
+		mAddFailed:   stats.GetCounter("cache.memory.add.failed.error"),
+		mDelCount:    stats.GetCounter("cache.memory.delete.count"),
+		mDelSuccess:  stats.GetCounter("cache.memory.delete.success"),
+		mEvicted:     stats.GetCounter("cache.memory.evicted"),
+		mExpired:     stats.GetCounter("cache.memory.expired"),
+	}
+	for i := 0; i < nShards; i++ {
+		m.shards = append(m.shards, &shard{
+			items: map[string]*item{},
+			order: &itemHeap{lfu: lfu},
+		})
+	}
+	return m, nil
 }
 
 //------------------------------------------------------------------------------
 
+func (m *Memory) getShard(key string) *shard {
+	if len(m.shards) == 1 {
+		return m.shards[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+// touch marks an item as having been used.
+func (s *shard) touch(it *item) {
+	s.tick++
+	it.lastUsed = s.tick
+	it.uses++
+	heap.Fix(s.order, it.index)
+}
+
+func (s *shard) remove(it *item) {
+	heap.Remove(s.order, it.index)
+	delete(s.items, it.key)
+	s.bytes -= it.size()
+}
+
+// compaction removes expired items from all shards if the time since the last
+// compaction is above the compaction interval. Shards are locked one at a time,
+// and therefore the caller must not hold the lock of a shard.
 func (m *Memory) compaction() {
-	if time.Since(m.lastCompaction) < m.compInterval {
+	if !m.compactionDue() {
+		return
+	}
+	m.compMut.Lock()
+	defer m.compMut.Unlock()
+	if !m.compactionDue() {
 		return
 	}
-	for k, v := range m.items {
-		if time.Since(v.ts) >= m.ttl {
-			delete(m.items, k)
+	for _, s := range m.shards {
+		s.Lock()
+		for _, v := range s.items {
+			if time.Since(v.ts) >= m.ttl {
+				s.remove(v)
+				m.mExpired.Incr(1)
+			}
 		}
+		s.Unlock()
 	}
+	atomic.StoreInt64(&m.lastCompaction, time.Now().UnixNano())
+}
+
+func (m *Memory) compactionDue() bool {
+	last := time.Unix(0, atomic.LoadInt64(&m.lastCompaction))
+	return time.Since(last) >= m.compInterval
+}
+
+// store writes an item to a shard, evicting other items until it fits within
+// the limits of the shard.
+func (m *Memory) store(s *shard, key string, value []byte) error {
+	it := &item{key: key, value: value, ts: time.Now()}
+	if m.maxBytes > 0 && it.size() > m.maxBytes {
+		return errItemTooLarge
+	}
+	if existing, exists := s.items[key]; exists {
+		s.remove(existing)
+		it.uses = existing.uses
+	}
+	for s.order.Len() > 0 {
+		if (m.maxItems <= 0 || s.order.Len() < m.maxItems) &&
+			(m.maxBytes <= 0 || s.bytes+it.size() <= m.maxBytes) {
+			break
+		}
+		s.remove(s.order.items[0])
+		m.mEvicted.Incr(1)
+	}
+	s.items[key] = it
+	s.bytes += it.size()
+	heap.Push(s.order, it)
+	s.touch(it)
+	return nil
 }
 
 // Get attempts to locate and return a cached value by its key, returns an error
 // if the key does not exist.
 func (m *Memory) Get(key string) ([]byte, error) {
-	m.RLock()
-	k, exists := m.items[key]
-	m.RUnlock()
+	m.mGetCount.Incr(1)
+	s := m.getShard(key)
+	s.Lock()
+	k, exists := s.items[key]
+	if exists {
+		s.touch(k)
+	}
+	s.Unlock()
 	if !exists {
+		m.mGetNotFound.Incr(1)
 		return nil, types.ErrKeyNotFound
 	}
+	m.mGetSuccess.Incr(1)
 	return k.value, nil
 }
 
 // Set attempts to set the value of a key.
 func (m *Memory) Set(key string, value []byte) error {
-	m.Lock()
+	m.mSetCount.Incr(1)
 	m.compaction()
-	m.items[key] = item{value: value, ts: time.Now()}
-	m.Unlock()
+	s := m.getShard(key)
+	s.Lock()
+	err := m.store(s, key, value)
+	s.Unlock()
+	if err != nil {
+		m.mSetFailed.Incr(1)
+		return err
+	}
+	m.mSetSuccess.Incr(1)
 	return nil
 }
 
 // Add attempts to set the value of a key only if the key does not already exist
 // and returns an error if the key already exists.
 func (m *Memory) Add(key string, value []byte) error {
-	m.Lock()
-	if _, exists := m.items[key]; exists {
-		m.Unlock()
+	m.mAddCount.Incr(1)
+	m.compaction()
+	s := m.getShard(key)
+	s.Lock()
+	if _, exists := s.items[key]; exists {
+		s.Unlock()
+		m.mAddDupe.Incr(1)
 		return types.ErrKeyAlreadyExists
 	}
-	m.compaction()
-	m.items[key] = item{value: value, ts: time.Now()}
-	m.Unlock()
+	err := m.store(s, key, value)
+	s.Unlock()
+	if err != nil {
+		m.mAddFailed.Incr(1)
+		return err
+	}
+	m.mAddSuccess.Incr(1)
 	return nil
 }
 
 // Delete attempts to remove a key.
 func (m *Memory) Delete(key string) error {
-	m.Lock()
+	m.mDelCount.Incr(1)
 	m.compaction()
-	delete(m.items, key)
-	m.Unlock()
+	s := m.getShard(key)
+	s.Lock()
+	if it, exists := s.items[key]; exists {
+		s.remove(it)
+	}
+	s.Unlock()
+	m.mDelSuccess.Incr(1)
 	return nil
 }
 
@@ -21,6 +21,7 @@
 package cache
 
 import (
+	"fmt"
 	"os"
 	"testing"
 
@@ -141,4 +142,192 @@ func TestMemoryCacheCompaction(t *testing.T) {
 	}
 }
 
+func TestMemoryCacheBadEviction(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.Eviction = "nope"
+
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad eviction policy")
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.MaxItems = 3
+	conf.Memory.Shards = 1
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"foo", "bar", "baz"} {
+		if err = c.Set(k, []byte(k)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Reading foo means bar is now the least recently used.
+	if _, err = c.Get("foo"); err != nil {
+		t.Error(err)
+	}
+	if err = c.Add("qux", []byte("qux")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = c.Get("bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	for _, k := range []string{"foo", "baz", "qux"} {
+		if act, err := c.Get(k); err != nil {
+			t.Error(err)
+		} else if string(act) != k {
+			t.Errorf("Wrong result: %v != %v", string(act), k)
+		}
+	}
+
+	// Overwriting an existing key does not evict anything.
+	if err = c.Set("foo", []byte("foo2")); err != nil {
+		t.Error(err)
+	}
+	for _, k := range []string{"foo", "baz", "qux"} {
+		if _, err := c.Get(k); err != nil {
+			t.Errorf("Key %v: %v", k, err)
+		}
+	}
+}
+
+func TestMemoryCacheLFU(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.MaxItems = 3
+	conf.Memory.Eviction = "lfu"
+	conf.Memory.Shards = 1
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"foo", "bar", "baz"} {
+		if err = c.Set(k, []byte(k)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Reading foo and baz means bar is the least frequently used, despite foo
+	// being used less recently.
+	for i := 0; i < 2; i++ {
+		if _, err = c.Get("foo"); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err = c.Get("baz"); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Get("bar"); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Get("baz"); err != nil {
+		t.Error(err)
+	}
+
+	if err = c.Set("qux", []byte("qux")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = c.Get("bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	for _, k := range []string{"foo", "baz", "qux"} {
+		if act, err := c.Get(k); err != nil {
+			t.Error(err)
+		} else if string(act) != k {
+			t.Errorf("Wrong result: %v != %v", string(act), k)
+		}
+	}
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.MaxBytes = 20
+	conf.Memory.Shards = 1
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each item is 8 bytes including its key.
+	if err = c.Set("foo", []byte("12345")); err != nil {
+		t.Error(err)
+	}
+	if err = c.Set("bar", []byte("12345")); err != nil {
+		t.Error(err)
+	}
+	if err = c.Set("baz", []byte("12345")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	for _, k := range []string{"bar", "baz"} {
+		if _, err := c.Get(k); err != nil {
+			t.Errorf("Key %v: %v", k, err)
+		}
+	}
+
+	if err = c.Set("qux", []byte("this value is too large")); err != errItemTooLarge {
+		t.Errorf("Wrong error returned: %v != %v", err, errItemTooLarge)
+	}
+	for _, k := range []string{"bar", "baz"} {
+		if _, err := c.Get(k); err != nil {
+			t.Errorf("Key %v: %v", k, err)
+		}
+	}
+}
+
+func TestMemoryCacheShardedLimits(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.MaxItems = 100
+	conf.Memory.Shards = 4
+
+	c, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if err = c.Set(fmt.Sprintf("key%v", i), []byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := 0
+	for _, s := range c.(*Memory).shards {
+		if exp, act := 25, len(s.items); act > exp {
+			t.Errorf("Too many items in shard: %v > %v", act, exp)
+		}
+		count += len(s.items)
+	}
+	if count == 0 {
+		t.Error("Expected items in cache")
+	}
+}
+
 //------------------------------------------------------------------------------
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["redis"] = TypeSpec{
+		constructor: NewRedis,
+		description: `
+Use a Redis instance as a cache. A prefix can be specified to allow multiple
+cache types to share a Redis instance under different namespaces.
+
+Keys are set with an expiration of ` + "`ttl`" + ` seconds, where zero means
+keys do not expire. The ` + "`add`" + ` operation is implemented with
+` + "`SETNX`" + `.
+
+Failed commands are retried up to ` + "`retries`" + ` times, with a backoff
+that starts at ` + "`retry_period_ms`" + ` and increases exponentially up to
+` + "`max_retry_backoff_ms`" + `. Connections are pooled, with at most
+` + "`pool_size`" + ` connections open at a time.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RedisConfig is a config struct for a redis connection.
+type RedisConfig struct {
+	URL               string `json:"url" yaml:"url"`
+	Prefix            string `json:"prefix" yaml:"prefix"`
+	TTL               int    `json:"ttl" yaml:"ttl"`
+	Retries           int    `json:"retries" yaml:"retries"`
+	RetryPeriodMS     int    `json:"retry_period_ms" yaml:"retry_period_ms"`
+	MaxRetryBackoffMS int    `json:"max_retry_backoff_ms" yaml:"max_retry_backoff_ms"`
+	PoolSize          int    `json:"pool_size" yaml:"pool_size"`
+	PoolTimeoutMS     int    `json:"pool_timeout_ms" yaml:"pool_timeout_ms"`
+	IdleTimeoutMS     int    `json:"idle_timeout_ms" yaml:"idle_timeout_ms"`
+}
+
+// NewRedisConfig returns a RedisConfig with default values.
+func NewRedisConfig() RedisConfig {
+	return RedisConfig{
+		URL:               "tcp://localhost:6379",
+		Prefix:            "",
+		TTL:               300,
+		Retries:           3,
+		RetryPeriodMS:     500,
+		MaxRetryBackoffMS: 5000,
+		PoolSize:          10,
+		PoolTimeoutMS:     4000,
+		IdleTimeoutMS:     300000,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Redis is a cache that connects to a redis server.
+type Redis struct {
+	conf  Config
+	log   log.Modular
+	stats metrics.Type
+
+	mGetCount      metrics.StatCounter
+	mGetNotFound   metrics.StatCounter
+	mGetFailed     metrics.StatCounter
+	mGetSuccess    metrics.StatCounter
+	mSetCount      metrics.StatCounter
+	mSetFailed     metrics.StatCounter
+	mSetSuccess    metrics.StatCounter
+	mAddCount      metrics.StatCounter
+	mAddFailedDupe metrics.StatCounter
+	mAddFailedErr  metrics.StatCounter
+	mAddSuccess    metrics.StatCounter
+	mDelCount      metrics.StatCounter
+	mDelFailedErr  metrics.StatCounter
+	mDelSuccess    metrics.StatCounter
+
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedis returns a Redis cache.
+func NewRedis(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (types.Cache, error) {
+	rURL, err := url.Parse(conf.Redis.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var pass string
+	if rURL.User != nil {
+		pass, _ = rURL.User.Password()
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:            rURL.Host,
+		Network:         rURL.Scheme,
+		Password:        pass,
+		MaxRetries:      conf.Redis.Retries,
+		MinRetryBackoff: time.Duration(conf.Redis.RetryPeriodMS) * time.Millisecond,
+		MaxRetryBackoff: time.Duration(conf.Redis.MaxRetryBackoffMS) * time.Millisecond,
+		PoolSize:        conf.Redis.PoolSize,
+		PoolTimeout:     time.Duration(conf.Redis.PoolTimeoutMS) * time.Millisecond,
+		IdleTimeout:     time.Duration(conf.Redis.IdleTimeoutMS) * time.Millisecond,
+	})
+
+	return &Redis{
+		conf:  conf,
+		log:   log.NewModule(".cache.redis"),
+		stats: stats,
+
+		mGetCount:      stats.GetCounter("cache.redis.get.count"),
+		mGetNotFound:   stats.GetCounter("cache.redis.get.failed.not_found"),
+		mGetFailed:     stats.GetCounter("cache.redis.get.failed.error"),
+		mGetSuccess:    stats.GetCounter("cache.redis.get.success"),
+		mSetCount:      stats.GetCounter("cache.redis.set.count"),
+		mSetFailed:     stats.GetCounter("cache.redis.set.failed.error"),
+		mSetSuccess:    stats.GetCounter("cache.redis.set.success"),
+		mAddCount:      stats.GetCounter("cache.redis.add.count"),
+		mAddFailedDupe: stats.GetCounter("cache.redis.add.failed.duplicate"),
+		mAddFailedErr:  stats.GetCounter("cache.redis.add.failed.error"),
+		mAddSuccess:    stats.GetCounter("cache.redis.add.success"),
+		mDelCount:      stats.GetCounter("cache.redis.delete.count"),
+		mDelFailedErr:  stats.GetCounter("cache.redis.delete.failed.error"),
+		mDelSuccess:    stats.GetCounter("cache.redis.delete.success"),
+
+		client: client,
+		prefix: conf.Redis.Prefix,
+		ttl:    time.Duration(conf.Redis.TTL) * time.Second,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Get attempts to locate and return a cached value by its key, returns an error
+// if the key does not exist or if the operation failed.
+func (r *Redis) Get(key string) ([]byte, error) {
+	r.mGetCount.Incr(1)
+
+	result, err := r.client.Get(r.prefix + key).Bytes()
+	if err == redis.Nil {
+		r.mGetNotFound.Incr(1)
+		return nil, types.ErrKeyNotFound
+	}
+	if err != nil {
+		r.mGetFailed.Incr(1)
+		return nil, err
+	}
+
+	r.mGetSuccess.Incr(1)
+	return result, nil
+}
+
+// Set attempts to set the value of a key.
+func (r *Redis) Set(key string, value []byte) error {
+	r.mSetCount.Incr(1)
+
+	if err := r.client.Set(r.prefix+key, value, r.ttl).Err(); err != nil {
+		r.mSetFailed.Incr(1)
+		return err
+	}
+
+	r.mSetSuccess.Incr(1)
+	return nil
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// and returns an error if the key already exists or if the operation fails.
+func (r *Redis) Add(key string, value []byte) error {
+	r.mAddCount.Incr(1)
+
+	set, err := r.client.SetNX(r.prefix+key, value, r.ttl).Result()
+	if err != nil {
+		r.mAddFailedErr.Incr(1)
+		return err
+	}
+	if !set {
+		r.mAddFailedDupe.Incr(1)
+		return types.ErrKeyAlreadyExists
+	}
+
+	r.mAddSuccess.Incr(1)
+	return nil
+}
+
+// Delete attempts to remove a key.
+func (r *Redis) Delete(key string) error {
+	r.mDelCount.Incr(1)
+
+	if err := r.client.Del(r.prefix + key).Err(); err != nil {
+		r.mDelFailedErr.Incr(1)
+		return err
+	}
+
+	r.mDelSuccess.Incr(1)
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,220 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestRedisIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	path, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skipf("Could not find redis-server: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cmd := exec.Command(path, "--port", fmt.Sprintf("%v", port), "--save", "", "--appendonly", "no")
+	if err = cmd.Start(); err != nil {
+		t.Fatalf("Could not start redis-server: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	url := fmt.Sprintf("tcp://localhost:%v", port)
+	for i := 0; i < 50; i++ {
+		var conn net.Conn
+		if conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%v", port)); err == nil {
+			conn.Close()
+			break
+		}
+		<-time.After(time.Millisecond * 100)
+	}
+	if err != nil {
+		t.Fatalf("Could not connect to redis-server: %v", err)
+	}
+
+	t.Run("TestRedisAddDuplicate", func(te *testing.T) {
+		testRedisAddDuplicate(url, te)
+	})
+	t.Run("TestRedisGetAndSet", func(te *testing.T) {
+		testRedisGetAndSet(url, te)
+	})
+	t.Run("TestRedisPrefix", func(te *testing.T) {
+		testRedisPrefix(url, te)
+	})
+	t.Run("TestRedisTTL", func(te *testing.T) {
+		testRedisTTL(url, te)
+	})
+}
+
+func testRedisAddDuplicate(url string, t *testing.T) {
+	conf := NewConfig()
+	conf.Redis.URL = url
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	c, err := NewRedis(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Delete("benthos_test_foo"); err != nil {
+		t.Error(err)
+	}
+
+	if err = c.Add("benthos_test_foo", []byte("bar")); err != nil {
+		t.Error(err)
+	}
+	if err = c.Add("benthos_test_foo", []byte("baz")); err != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyAlreadyExists)
+	}
+
+	exp := "bar"
+	var act []byte
+
+	if act, err = c.Get("benthos_test_foo"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong value returned: %v != %v", string(act), exp)
+	}
+
+	if err = c.Delete("benthos_test_foo"); err != nil {
+		t.Error(err)
+	}
+}
+
+func testRedisGetAndSet(url string, t *testing.T) {
+	conf := NewConfig()
+	conf.Redis.URL = url
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	c, err := NewRedis(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.Get("benthos_test_missing"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+
+	if err = c.Set("benthos_test_foo", []byte("bar")); err != nil {
+		t.Error(err)
+	}
+	if err = c.Set("benthos_test_foo", []byte("baz")); err != nil {
+		t.Error(err)
+	}
+
+	exp := "baz"
+	var act []byte
+
+	if act, err = c.Get("benthos_test_foo"); err != nil {
+		t.Error(err)
+	} else if string(act) != exp {
+		t.Errorf("Wrong value returned: %v != %v", string(act), exp)
+	}
+
+	if err = c.Delete("benthos_test_foo"); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Get("benthos_test_foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+}
+
+func testRedisPrefix(url string, t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Redis.URL = url
+	conf.Redis.Prefix = "foo_"
+	fooCache, err := NewRedis(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Redis.Prefix = "bar_"
+	barCache, err := NewRedis(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = fooCache.Set("benthos_test_key", []byte("foo")); err != nil {
+		t.Error(err)
+	}
+	if err = barCache.Add("benthos_test_key", []byte("bar")); err != nil {
+		t.Error(err)
+	}
+
+	if act, err := fooCache.Get("benthos_test_key"); err != nil {
+		t.Error(err)
+	} else if exp := "foo"; string(act) != exp {
+		t.Errorf("Wrong value returned: %v != %v", string(act), exp)
+	}
+	if act, err := barCache.Get("benthos_test_key"); err != nil {
+		t.Error(err)
+	} else if exp := "bar"; string(act) != exp {
+		t.Errorf("Wrong value returned: %v != %v", string(act), exp)
+	}
+}
+
+func testRedisTTL(url string, t *testing.T) {
+	conf := NewConfig()
+	conf.Redis.URL = url
+	conf.Redis.TTL = 1
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	c, err := NewRedis(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Set("benthos_test_ttl", []byte("bar")); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Get("benthos_test_ttl"); err != nil {
+		t.Error(err)
+	}
+
+	<-time.After(time.Millisecond * 1500)
+	if _, err = c.Get("benthos_test_ttl"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+}
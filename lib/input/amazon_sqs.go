@@ -34,7 +34,16 @@ func init() {
 		constructor: NewAmazonSQS,
 		description: `
 Receive messages from an Amazon SQS URL, only the body is extracted into
-messages.`,
+messages.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- sqs_message_id
+- All message attributes with a string value
+` + "```",
 	}
 }
 
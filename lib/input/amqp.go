@@ -36,7 +36,33 @@ func init() {
 AMQP (0.91) is the underlying messaging protocol that is used by various message
 brokers, including RabbitMQ.
 
-Exchange type options are: direct|fanout|topic|x-custom`,
+Exchange type options are: direct|fanout|topic|x-custom
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- amqp_content_type
+- amqp_content_encoding
+- amqp_delivery_mode
+- amqp_priority
+- amqp_correlation_id
+- amqp_reply_to
+- amqp_expiration
+- amqp_message_id
+- amqp_timestamp
+- amqp_type
+- amqp_user_id
+- amqp_app_id
+- amqp_consumer_tag
+- amqp_delivery_tag
+- amqp_redelivered
+- amqp_exchange
+- amqp_routing_key
+- All existing message headers, including nested headers prefixed with the key
+  of their respective parent.
+` + "```",
 	}
 }
 
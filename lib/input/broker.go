@@ -129,6 +129,21 @@ Which results in a total of four kafka_balanced inputs. Note that ditto_0 will
 result in no duplicate configs, this might be useful if the config is generated
 and there's a chance you won't want any duplicates.
 
+### Patterns
+
+By default the ` + "`fan_in`" + ` pattern is used, where messages from all
+inputs are passed on as soon as they are read. With the ` + "`partition`" + `
+pattern a message is not passed on until all prior messages that share its key
+have been acknowledged, where the key is the value of ` + "`partition.key`" + `
+and should contain
+[function interpolations](../config_interpolation.md#functions) that resolve to
+an identifier of the message, such as ` + "`${!json_field:user.id}`" + `. This
+means that messages of a key are processed in the order they were read even
+when the pipeline has multiple processing threads.
+
+When a message arrives with a key that is already in flight the broker stops
+reading from all inputs until that key has been acknowledged.
+
 ### Processors
 
 It is possible to configure [processors](../processors/README.md) at the broker
@@ -141,17 +156,35 @@ nodes processors.`,
 
 //------------------------------------------------------------------------------
 
+// BrokerPartitionConfig contains configuration fields for the partition broker
+// pattern.
+type BrokerPartitionConfig struct {
+	Key string `json:"key" yaml:"key"`
+}
+
+// NewBrokerPartitionConfig creates a new BrokerPartitionConfig with default
+// values.
+func NewBrokerPartitionConfig() BrokerPartitionConfig {
+	return BrokerPartitionConfig{
+		Key: "",
+	}
+}
+
 // BrokerConfig is configuration for the Broker input type.
 type BrokerConfig struct {
-	Copies int             `json:"copies" yaml:"copies"`
-	Inputs brokerInputList `json:"inputs" yaml:"inputs"`
+	Copies    int                   `json:"copies" yaml:"copies"`
+	Pattern   string                `json:"pattern" yaml:"pattern"`
+	Partition BrokerPartitionConfig `json:"partition" yaml:"partition"`
+	Inputs    brokerInputList       `json:"inputs" yaml:"inputs"`
 }
 
 // NewBrokerConfig creates a new BrokerConfig with default values.
 func NewBrokerConfig() BrokerConfig {
 	return BrokerConfig{
-		Copies: 1,
-		Inputs: brokerInputList{},
+		Copies:    1,
+		Pattern:   "fan_in",
+		Partition: NewBrokerPartitionConfig(),
+		Inputs:    brokerInputList{},
 	}
 }
 
@@ -294,7 +327,18 @@ func NewBroker(
 	if lInputs <= 0 {
 		return nil, ErrBrokerNoInputs
 	}
-	if lInputs == 1 {
+
+	// Validate the pattern before any inputs begin consuming.
+	switch conf.Broker.Pattern {
+	case "fan_in":
+	case "partition":
+		if len(conf.Broker.Partition.Key) == 0 {
+			return nil, broker.ErrPartitionNoKey
+		}
+	default:
+		return nil, fmt.Errorf("broker pattern was not recognised: %v", conf.Broker.Pattern)
+	}
+	if lInputs == 1 && conf.Broker.Pattern != "partition" {
 		return New(conf.Broker.Inputs[0], mgr, log, stats, pipelines...)
 	}
 
@@ -310,6 +354,9 @@ func NewBroker(
 		}
 	}
 
+	if conf.Broker.Pattern == "partition" {
+		return broker.NewPartitionFanIn(inputs, conf.Broker.Partition.Key, stats)
+	}
 	return broker.NewFanIn(inputs, stats)
 }
 
@@ -22,7 +22,14 @@ package input
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
 )
 
 func TestBrokerConfigDefaults(t *testing.T) {
@@ -275,3 +282,69 @@ func TestBrokerConfigDittoZeroed(t *testing.T) {
 		t.Errorf("Unexpected value from config: %v != %v", exp, actual)
 	}
 }
+
+func TestBrokerPartition(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "benthos_broker_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpfile.Write([]byte("foo\nbar\nfoo\n"))
+	tmpfile.Close()
+
+	fileConf := NewConfig()
+	fileConf.Type = "file"
+	fileConf.File.Path = tmpfile.Name()
+
+	conf := NewConfig()
+	conf.Type = "broker"
+	conf.Broker.Pattern = "partition"
+	conf.Broker.Partition.Key = "${!content}"
+	conf.Broker.Inputs = append(conf.Broker.Inputs, fileConf)
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	b, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exp := range []string{"foo", "bar", "foo"} {
+		var ts types.Transaction
+		select {
+		case ts = <-b.TransactionChan():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+		if act := string(ts.Payload.Get(0)); act != exp {
+			t.Errorf("Wrong result: %v != %v", act, exp)
+		}
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(nil):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+
+	b.CloseAsync()
+	if err = b.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBrokerBadPattern(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "broker"
+	conf.Broker.Pattern = "nope"
+	conf.Broker.Inputs = append(conf.Broker.Inputs, NewConfig(), NewConfig())
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad pattern")
+	}
+
+	conf.Broker.Pattern = "partition"
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from partition without a key")
+	}
+}
@@ -80,6 +80,7 @@ type Config struct {
 	ReadUntil     ReadUntilConfig            `json:"read_until" yaml:"read_until"`
 	RedisList     reader.RedisListConfig     `json:"redis_list" yaml:"redis_list"`
 	RedisPubSub   reader.RedisPubSubConfig   `json:"redis_pubsub" yaml:"redis_pubsub"`
+	Resource      string                     `json:"resource" yaml:"resource"`
 	ScaleProto    reader.ScaleProtoConfig    `json:"scalability_protocols" yaml:"scalability_protocols"`
 	STDIN         STDINConfig                `json:"stdin" yaml:"stdin"`
 	Websocket     reader.WebsocketConfig     `json:"websocket" yaml:"websocket"`
@@ -109,6 +110,7 @@ func NewConfig() Config {
 		ReadUntil:     NewReadUntilConfig(),
 		RedisList:     reader.NewRedisListConfig(),
 		RedisPubSub:   reader.NewRedisPubSubConfig(),
+		Resource:      "",
 		ScaleProto:    reader.NewScaleProtoConfig(),
 		STDIN:         NewSTDINConfig(),
 		Websocket:     reader.NewWebsocketConfig(),
@@ -143,10 +145,15 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 			}
 			inSlice = append(inSlice, sanInput)
 		}
-		outputMap["broker"] = map[string]interface{}{
-			"copies": conf.Broker.Copies,
-			"inputs": inSlice,
+		brokerMap := map[string]interface{}{
+			"copies":  conf.Broker.Copies,
+			"pattern": conf.Broker.Pattern,
+			"inputs":  inSlice,
 		}
+		if conf.Broker.Pattern == "partition" {
+			brokerMap["partition"] = conf.Broker.Partition
+		}
+		outputMap["broker"] = brokerMap
 	} else {
 		outputMap[t] = hashMap[t]
 	}
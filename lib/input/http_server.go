@@ -22,16 +22,20 @@ package input
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/ratelimit"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/util/throttle"
@@ -48,7 +52,28 @@ Receive messages POSTed over HTTP(S). HTTP 2.0 is supported when using TLS,
 which is enabled when key and cert files are specified.
 
 You can leave the 'address' config field blank in order to use the instance wide
-HTTP server.`,
+HTTP server.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- http_server_request_path
+- All request headers (only the first value of each header key)
+- All multipart section headers (only the first value of each header key)
+` + "```" + `
+
+When a multipart request is received the headers of each section take precedence
+over the request headers of the same key.
+
+### Rate Limiting
+
+When a [rate limit](../rate_limits/README.md) resource is specified with
+` + "`rate_limit`" + ` POST requests that exceed the limit are rejected with a
+429 (Too Many Requests) status code and a ` + "`Retry-After`" + ` header.
+Websocket messages that exceed the limit are instead held until the limit
+allows them.`,
 	}
 }
 
@@ -62,6 +87,7 @@ type HTTPServerConfig struct {
 	TimeoutMS int64  `json:"timeout_ms" yaml:"timeout_ms"`
 	CertFile  string `json:"cert_file" yaml:"cert_file"`
 	KeyFile   string `json:"key_file" yaml:"key_file"`
+	RateLimit string `json:"rate_limit" yaml:"rate_limit"`
 }
 
 // NewHTTPServerConfig creates a new HTTPServerConfig with default values.
@@ -73,6 +99,7 @@ func NewHTTPServerConfig() HTTPServerConfig {
 		TimeoutMS: 5000,
 		CertFile:  "",
 		KeyFile:   "",
+		RateLimit: "",
 	}
 }
 
@@ -106,6 +133,9 @@ type HTTPServer struct {
 	mWSSucc    metrics.StatCounter
 	mAsyncErr  metrics.StatCounter
 	mAsyncSucc metrics.StatCounter
+	mLimited   metrics.StatCounter
+
+	rateLimit types.RateLimit
 }
 
 // NewHTTPServer creates a new HTTPServer input type.
@@ -141,6 +171,14 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		mWSSucc:    stats.GetCounter("input.http_server.ws.send.success"),
 		mAsyncErr:  stats.GetCounter("input.http_server.send.async_error"),
 		mAsyncSucc: stats.GetCounter("input.http_server.send.async_success"),
+		mLimited:   stats.GetCounter("input.http_server.rate_limited"),
+	}
+
+	if len(conf.HTTPServer.RateLimit) > 0 {
+		var err error
+		if h.rateLimit, err = mgr.GetRateLimit(conf.HTTPServer.RateLimit); err != nil {
+			return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.HTTPServer.RateLimit, err)
+		}
 	}
 
 	if mux != nil {
@@ -177,6 +215,19 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.rateLimit != nil {
+		if period, err := h.rateLimit.Access(); err != nil || period > 0 {
+			h.mLimited.Incr(1)
+			if err != nil {
+				h.log.Errorf("Failed to access rate limit: %v\n", err)
+				period = time.Second
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(period.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
 	msg := types.NewMessage(nil)
 	var err error
 
@@ -209,7 +260,12 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 			if msgBytes, err = ioutil.ReadAll(p); err != nil {
 				return
 			}
-			msg.Append(msgBytes)
+			md := msg.GetMetadata(msg.Append(msgBytes))
+			for k, v := range p.Header {
+				if len(v) > 0 {
+					md.Set(k, v[0])
+				}
+			}
 		}
 	} else {
 		var msgBytes []byte
@@ -219,6 +275,18 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 		msg.Append(msgBytes)
 	}
 
+	// Set request headers as metadata of each part, without overriding the
+	// headers of individual multipart sections.
+	for i := 0; i < msg.Len(); i++ {
+		md := msg.GetMetadata(i)
+		for k, v := range r.Header {
+			if len(v) > 0 && len(md.Get(k)) == 0 {
+				md.Set(k, v[0])
+			}
+		}
+		md.Set("http_server_request_path", r.URL.Path)
+	}
+
 	resChan := make(chan types.Response)
 	select {
 	case h.transactions <- types.NewTransaction(msg, resChan):
@@ -291,6 +359,15 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			h.mWSCount.Incr(1)
 			h.mCountF.Incr(1)
+			if h.rateLimit != nil {
+				limited, err := ratelimit.Wait(h.rateLimit, h.closeChan, h.log)
+				if limited {
+					h.mLimited.Incr(1)
+				}
+				if err != nil {
+					return
+				}
+			}
 		}
 
 		msg := types.NewMessage([][]byte{message})
@@ -209,3 +209,69 @@ func TestHTTPTimeout(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type fakeRateLimit struct {
+	period time.Duration
+}
+
+func (f fakeRateLimit) Access() (time.Duration, error) {
+	return f.period, nil
+}
+
+type fakeRateLimitMgr struct {
+	types.DudMgr
+	rateLimits map[string]types.RateLimit
+}
+
+func (f fakeRateLimitMgr) GetRateLimit(name string) (types.RateLimit, error) {
+	if r, exists := f.rateLimits[name]; exists {
+		return r, nil
+	}
+	return nil, types.ErrRateLimitNotFound
+}
+
+func TestHTTPRateLimited(t *testing.T) {
+	mgr := fakeRateLimitMgr{
+		rateLimits: map[string]types.RateLimit{
+			"foo": fakeRateLimit{period: time.Second * 3},
+		},
+	}
+
+	conf := NewConfig()
+	conf.HTTPServer.Address = "localhost:1244"
+	conf.HTTPServer.Path = "/testpost"
+	conf.HTTPServer.RateLimit = "bar"
+
+	if _, err := NewHTTPServer(conf, mgr, log.New(os.Stdout, logConfig), metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing rate limit")
+	}
+
+	conf.HTTPServer.RateLimit = "foo"
+	h, err := NewHTTPServer(conf, mgr, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 500)
+
+	res, err := http.Post(
+		"http://localhost:1244/testpost",
+		"application/octet-stream",
+		bytes.NewBuffer([]byte("hello world")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if exp, act := http.StatusTooManyRequests, res.StatusCode; exp != act {
+		t.Errorf("Wrong status code returned: %v != %v", act, exp)
+	}
+	if exp, act := "3", res.Header.Get("Retry-After"); exp != act {
+		t.Errorf("Wrong Retry-After header: %v != %v", act, exp)
+	}
+
+	h.CloseAsync()
+	if err := h.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
@@ -35,7 +35,20 @@ func init() {
 		description: `
 Connects to a kafka (0.9+) server. Offsets are managed within kafka as per the
 consumer group (set via config), and partitions are automatically balanced
-across any members of the consumer group.`,
+across any members of the consumer group.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- kafka_key
+- kafka_topic
+- kafka_partition
+- kafka_offset
+- kafka_timestamp_unix
+- All existing message headers (version 0.11+)
+` + "```",
 	}
 }
 
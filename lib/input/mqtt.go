@@ -33,7 +33,19 @@ func init() {
 	Constructors["mqtt"] = TypeSpec{
 		constructor: NewMQTT,
 		description: `
-Subscribe to topics on MQTT brokers`,
+Subscribe to topics on MQTT brokers
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- mqtt_duplicate
+- mqtt_qos
+- mqtt_retained
+- mqtt_topic
+- mqtt_message_id
+` + "```",
 	}
 }
 
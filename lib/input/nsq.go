@@ -33,7 +33,17 @@ func init() {
 	Constructors["nsq"] = TypeSpec{
 		constructor: NewNSQ,
 		description: `
-Subscribe to an NSQ instance topic and channel.`,
+Subscribe to an NSQ instance topic and channel.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- nsq_attempts
+- nsq_id
+- nsq_timestamp
+` + "```",
 	}
 }
 
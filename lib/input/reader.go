@@ -136,7 +136,32 @@ func (r *Reader) loop() {
 	mConn.Incr(1)
 	mConnF.Incr(1)
 
+	acknowledge := func(err error) bool {
+		if err = r.reader.Acknowledge(err); err != nil {
+			mAckError.Incr(1)
+			mAckErrorF.Incr(1)
+			return false
+		}
+		mAckSuccess.Incr(1)
+		mAckSuccessF.Incr(1)
+		return true
+	}
+
+	// A deferred response means messages were accepted but cannot be
+	// acknowledged until it is resolved. Acknowledgements are only made between
+	// reads, and therefore a resolution is checked before each read.
+	var pendingAck *types.DeferredResponse
+
 	for atomic.LoadInt32(&r.running) == 1 {
+		if pendingAck != nil {
+			select {
+			case <-pendingAck.Resolved():
+				acknowledge(nil)
+				pendingAck = nil
+			default:
+			}
+		}
+
 		msg, err := r.reader.Read()
 
 		// If our reader says it is not connected.
@@ -206,16 +231,14 @@ func (r *Reader) loop() {
 				mSendSuccessF.Incr(1)
 			}
 			if res.Error() != nil || !res.SkipAck() {
-				if err = r.reader.Acknowledge(res.Error()); err != nil {
-					mAckError.Incr(1)
-					mAckErrorF.Incr(1)
-				} else {
+				pendingAck = nil
+				if acknowledge(res.Error()) {
 					tTaken := time.Since(msg.CreatedAt()).Nanoseconds()
 					mLatency.Timing(tTaken)
 					mLatencyF.Timing(tTaken)
-					mAckSuccess.Incr(1)
-					mAckSuccessF.Incr(1)
 				}
+			} else if d, ok := res.(*types.DeferredResponse); ok {
+				pendingAck = d
 			}
 		case <-r.closeChan:
 			return
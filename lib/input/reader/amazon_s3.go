@@ -317,7 +317,11 @@ func (a *AmazonS3) Read() (types.Message, error) {
 	}
 	a.readKeys = append(a.readKeys, target)
 
-	return types.NewMessage([][]byte{buff.Bytes()}), nil
+	msg := types.NewMessage([][]byte{buff.Bytes()})
+	msg.GetMetadata(0).
+		Set("s3_key", target.s3Key).
+		Set("s3_bucket", a.conf.Bucket)
+	return msg, nil
 }
 
 // Acknowledge confirms whether or not our unacknowledged messages have been
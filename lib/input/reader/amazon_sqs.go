@@ -130,9 +130,10 @@ func (a *AmazonSQS) Read() (types.Message, error) {
 	}
 
 	output, err := a.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
-		QueueUrl:            aws.String(a.conf.URL),
-		MaxNumberOfMessages: aws.Int64(1),
-		WaitTimeSeconds:     aws.Int64(a.conf.TimeoutS),
+		QueueUrl:              aws.String(a.conf.URL),
+		MaxNumberOfMessages:   aws.Int64(1),
+		WaitTimeSeconds:       aws.Int64(a.conf.TimeoutS),
+		MessageAttributeNames: []*string{aws.String("All")},
 	})
 	if err != nil {
 		return nil, err
@@ -153,7 +154,15 @@ func (a *AmazonSQS) Read() (types.Message, error) {
 		}
 
 		if sqsMsg.Body != nil {
-			msg.Append([]byte(*sqsMsg.Body))
+			md := msg.GetMetadata(msg.Append([]byte(*sqsMsg.Body)))
+			if sqsMsg.MessageId != nil {
+				md.Set("sqs_message_id", *sqsMsg.MessageId)
+			}
+			for k, v := range sqsMsg.MessageAttributes {
+				if v.StringValue != nil {
+					md.Set(k, *v.StringValue)
+				}
+			}
 		}
 	}
 
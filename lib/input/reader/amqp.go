@@ -22,6 +22,7 @@ package reader
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -218,7 +219,46 @@ func (a *AMQP) Read() (types.Message, error) {
 	// Only store the latest delivery tag, but always Ack multiple.
 	a.ackTag = data.DeliveryTag
 
-	return types.NewMessage([][]byte{data.Body}), nil
+	msg := types.NewMessage([][]byte{data.Body})
+	md := msg.GetMetadata(0)
+
+	addAMQPHeaders(md, "", data.Headers)
+
+	md.Set("amqp_content_type", data.ContentType)
+	md.Set("amqp_content_encoding", data.ContentEncoding)
+	md.Set("amqp_delivery_mode", strconv.Itoa(int(data.DeliveryMode)))
+	md.Set("amqp_priority", strconv.Itoa(int(data.Priority)))
+	md.Set("amqp_correlation_id", data.CorrelationId)
+	md.Set("amqp_reply_to", data.ReplyTo)
+	md.Set("amqp_expiration", data.Expiration)
+	md.Set("amqp_message_id", data.MessageId)
+	if !data.Timestamp.IsZero() {
+		md.Set("amqp_timestamp", strconv.FormatInt(data.Timestamp.Unix(), 10))
+	}
+	md.Set("amqp_type", data.Type)
+	md.Set("amqp_user_id", data.UserId)
+	md.Set("amqp_app_id", data.AppId)
+	md.Set("amqp_consumer_tag", data.ConsumerTag)
+	md.Set("amqp_delivery_tag", strconv.FormatUint(data.DeliveryTag, 10))
+	md.Set("amqp_redelivered", strconv.FormatBool(data.Redelivered))
+	md.Set("amqp_exchange", data.Exchange)
+	md.Set("amqp_routing_key", data.RoutingKey)
+
+	return msg, nil
+}
+
+// addAMQPHeaders sets a metadata field for each header of an AMQP delivery.
+// Nested header tables are flattened, where the keys of nested values are
+// prefixed with the key of their parent.
+func addAMQPHeaders(md types.Metadata, prefix string, headers amqp.Table) {
+	for k, v := range headers {
+		key := prefix + k
+		if t, isTable := v.(amqp.Table); isTable {
+			addAMQPHeaders(md, key+"_", t)
+			continue
+		}
+		md.Set(key, fmt.Sprintf("%v", v))
+	}
 }
 
 // Acknowledge instructs whether unacknowledged messages have been successfully
@@ -106,6 +106,7 @@ func (f *Files) Read() (types.Message, error) {
 	}
 
 	msg := types.NewMessage([][]byte{msgBytes})
+	msg.GetMetadata(0).Set("path", path)
 	return msg, nil
 }
 
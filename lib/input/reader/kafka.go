@@ -21,6 +21,7 @@
 package reader
 
 import (
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -247,7 +248,23 @@ func (k *Kafka) Read() (types.Message, error) {
 		return nil, types.ErrTypeClosed
 	}
 	k.offset = data.Offset + 1
-	return types.NewMessage([][]byte{data.Value}), nil
+
+	msg := types.NewMessage([][]byte{data.Value})
+	addKafkaMetadata(msg.GetMetadata(0), data)
+	return msg, nil
+}
+
+// addKafkaMetadata sets the metadata fields of a message part consumed from
+// Kafka.
+func addKafkaMetadata(md types.Metadata, data *sarama.ConsumerMessage) {
+	for _, hdr := range data.Headers {
+		md.Set(string(hdr.Key), string(hdr.Value))
+	}
+	md.Set("kafka_key", string(data.Key))
+	md.Set("kafka_topic", data.Topic)
+	md.Set("kafka_partition", strconv.Itoa(int(data.Partition)))
+	md.Set("kafka_offset", strconv.FormatInt(data.Offset, 10))
+	md.Set("kafka_timestamp_unix", strconv.FormatInt(data.Timestamp.Unix(), 10))
 }
 
 // Acknowledge instructs whether the current offset should be committed.
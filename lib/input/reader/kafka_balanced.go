@@ -185,7 +185,10 @@ func (k *KafkaBalanced) Read() (types.Message, error) {
 		return nil, types.ErrNotConnected
 	}
 	consumer.MarkOffset(data, "")
-	return types.NewMessage([][]byte{data.Value}), nil
+
+	msg := types.NewMessage([][]byte{data.Value})
+	addKafkaMetadata(msg.GetMetadata(0), data)
+	return msg, nil
 }
 
 // Acknowledge instructs whether the current offset should be committed.
@@ -21,6 +21,7 @@
 package reader
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -138,7 +139,14 @@ func (m *MQTT) msgHandler(c mqtt.Client, msg mqtt.Message) {
 func (m *MQTT) Read() (types.Message, error) {
 	select {
 	case msg := <-m.msgChan:
-		return types.NewMessage([][]byte{[]byte(msg.Payload())}), nil
+		message := types.NewMessage([][]byte{[]byte(msg.Payload())})
+		message.GetMetadata(0).
+			Set("mqtt_duplicate", strconv.FormatBool(msg.Duplicate())).
+			Set("mqtt_qos", strconv.Itoa(int(msg.Qos()))).
+			Set("mqtt_retained", strconv.FormatBool(msg.Retained())).
+			Set("mqtt_topic", msg.Topic()).
+			Set("mqtt_message_id", strconv.Itoa(int(msg.MessageID())))
+		return message, nil
 	case <-m.interruptChan:
 	}
 	return nil, types.ErrTypeClosed
@@ -126,7 +126,9 @@ func (n *NATS) Read() (types.Message, error) {
 		n.disconnect()
 		return nil, types.ErrNotConnected
 	}
-	return types.NewMessage([][]byte{msg.Data}), nil
+	message := types.NewMessage([][]byte{msg.Data})
+	message.GetMetadata(0).Set("nats_subject", msg.Subject)
+	return message, nil
 }
 
 // Acknowledge instructs whether read messages have been successfully
@@ -21,6 +21,7 @@
 package reader
 
 import (
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -184,7 +185,11 @@ func (n *NATSStream) Read() (types.Message, error) {
 		n.disconnect()
 		return nil, types.ErrTypeClosed
 	}
-	return types.NewMessage([][]byte{msg.Data}), nil
+	message := types.NewMessage([][]byte{msg.Data})
+	message.GetMetadata(0).
+		Set("nats_stream_subject", msg.Subject).
+		Set("nats_stream_sequence", strconv.FormatUint(msg.Sequence, 10))
+	return message, nil
 }
 
 // Acknowledge instructs whether unacknowledged messages have been successfully
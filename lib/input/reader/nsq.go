@@ -23,6 +23,7 @@ package reader
 import (
 	"io/ioutil"
 	llog "log"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -183,7 +184,12 @@ func (n *NSQ) Read() (types.Message, error) {
 		n.disconnect()
 		return nil, types.ErrTypeClosed
 	}
-	return types.NewMessage([][]byte{msg.Body}), nil
+	message := types.NewMessage([][]byte{msg.Body})
+	message.GetMetadata(0).
+		Set("nsq_attempts", strconv.Itoa(int(msg.Attempts))).
+		Set("nsq_id", string(msg.ID[:])).
+		Set("nsq_timestamp", strconv.FormatInt(msg.Timestamp, 10))
+	return message, nil
 }
 
 // Acknowledge instructs whether unacknowledged messages have been successfully
@@ -131,7 +131,9 @@ func (r *RedisPubSub) Read() (types.Message, error) {
 		return nil, types.ErrTypeClosed
 	}
 
-	return types.NewMessage([][]byte{[]byte(rMsg.Payload)}), nil
+	msg := types.NewMessage([][]byte{[]byte(rMsg.Payload)})
+	msg.GetMetadata(0).Set("redis_pubsub_channel", rMsg.Channel)
+	return msg, nil
 }
 
 // Acknowledge instructs whether messages have been successfully propagated.
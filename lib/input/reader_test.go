@@ -663,3 +663,73 @@ func TestReaderSkipAcks(t *testing.T) {
 }
 
 //------------------------------------------------------------------------------
+
+func TestReaderDeferredAcks(t *testing.T) {
+	t.Parallel()
+
+	readerImpl := newMockReader()
+	readerImpl.msgToSnd = types.NewMessage([][]byte{[]byte("foo")})
+	readerImpl.ackRcvd = errors.New("ack not received")
+
+	r, err := NewReader(
+		"foo", readerImpl,
+		log.New(os.Stdout, logConfig), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case readerImpl.connChan <- nil:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case readerImpl.readChan <- nil:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	var ts types.Transaction
+	select {
+	case ts = <-r.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	deferred := types.NewDeferredResponse()
+	select {
+	case ts.ResponseChan <- deferred:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	deferred.Resolve()
+
+	// The acknowledgement is made between reads.
+	timeout := time.After(time.Second)
+ackLoop:
+	for {
+		select {
+		case readerImpl.readChan <- types.ErrTimeout:
+		case readerImpl.ackChan <- nil:
+			break ackLoop
+		case <-timeout:
+			t.Fatal("Timed out waiting for deferred acknowledgement")
+		}
+	}
+	if readerImpl.ackRcvd != nil {
+		t.Errorf("Wrong ack received: %v", readerImpl.ackRcvd)
+	}
+
+	r.CloseAsync()
+	go func() {
+		select {
+		case readerImpl.readChan <- types.ErrTypeClosed:
+		case <-time.After(time.Second):
+		}
+	}()
+	if err = r.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -34,7 +34,15 @@ func init() {
 		constructor: NewRedisPubSub,
 		description: `
 Redis supports a publish/subscribe model, it's possible to subscribe to multiple
-channels using this input.`,
+channels using this input.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- redis_pubsub_channel
+` + "```",
 	}
 }
 
@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["resource"] = TypeSpec{
+		constructor: NewResource,
+		description: `
+Resource is an input type that reads messages from an input resource by its
+name. Input resources are shared, which means many inputs, or inputs of many
+streams, can consume messages from the same source. Each message of the input
+resource is only consumed once.
+
+` + "``` yaml" + `
+input:
+  type: resource
+  resource: kafka_in
+resources:
+  inputs:
+    kafka_in:
+      type: kafka
+      kafka:
+        addresses:
+        - localhost:9092
+        topic: benthos_stream
+` + "```" + `
+
+Input resources are closed when the service shuts down rather than when the
+streams that refer to them are closed.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Resource is an input type that reads from an input resource.
+type Resource struct {
+	running int32
+
+	input types.Input
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactions chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewResource creates a new Resource input type.
+func NewResource(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	in, err := mgr.GetInput(conf.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain input resource '%v': %v", conf.Resource, err)
+	}
+	r := &Resource{
+		running:      1,
+		input:        in,
+		log:          log.NewModule(".input.resource"),
+		stats:        stats,
+		transactions: make(chan types.Transaction),
+		closeChan:    make(chan struct{}),
+		closedChan:   make(chan struct{}),
+	}
+
+	go r.loop()
+	return r, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (r *Resource) loop() {
+	var (
+		mRunning      = r.stats.GetCounter("input.resource.running")
+		mCount        = r.stats.GetCounter("input.resource.count")
+		mInputClosed  = r.stats.GetCounter("input.resource.input.closed")
+		mPropagated   = r.stats.GetCounter("input.resource.propagated")
+		mReturnClosed = r.stats.GetCounter("input.resource.returned.closed")
+	)
+
+	defer func() {
+		mRunning.Decr(1)
+
+		close(r.transactions)
+		close(r.closedChan)
+	}()
+	mRunning.Incr(1)
+
+	var open bool
+	for atomic.LoadInt32(&r.running) == 1 {
+		var tran types.Transaction
+		select {
+		case tran, open = <-r.input.TransactionChan():
+			if !open {
+				mInputClosed.Incr(1)
+				return
+			}
+		case <-r.closeChan:
+			return
+		}
+		mCount.Incr(1)
+
+		select {
+		case r.transactions <- tran:
+			mPropagated.Incr(1)
+		case <-r.closeChan:
+			// The input resource is shared and waits for this transaction to
+			// be resolved, therefore we reject it so that it can be consumed
+			// elsewhere.
+			mReturnClosed.Incr(1)
+			select {
+			case tran.ResponseChan <- types.NewSimpleResponse(types.ErrTypeClosed):
+			case <-time.After(time.Second):
+			}
+			return
+		}
+	}
+}
+
+// TransactionChan returns a transactions channel for consuming messages from
+// this input type.
+func (r *Resource) TransactionChan() <-chan types.Transaction {
+	return r.transactions
+}
+
+// CloseAsync shuts down the Resource input and stops processing requests. The
+// input resource itself is not closed.
+func (r *Resource) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&r.running, 1, 0) {
+		close(r.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Resource input has closed down.
+func (r *Resource) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-r.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/gorilla/mux"
+)
+
+//------------------------------------------------------------------------------
+
+func (t *Type) registerEndpoints() {
+	t.apiReg.RegisterEndpoint(
+		"/caches/{name}/{key}",
+		"Perform operations on the keys of cache resources, supporting GET"+
+			" (Read), PUT (Set) and DELETE (Delete).",
+		t.HandleCacheCRUD,
+	)
+}
+
+// HandleCacheCRUD is an http.HandleFunc for reading, setting and deleting the
+// keys of cache resources.
+func (t *Type) HandleCacheCRUD(w http.ResponseWriter, r *http.Request) {
+	var serverErr, requestErr error
+	defer func() {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		if serverErr != nil {
+			t.logger.Errorf("Cache CRUD Error: %v\n", serverErr)
+			http.Error(w, fmt.Sprintf("Error: %v", serverErr), http.StatusBadGateway)
+		}
+		if requestErr != nil {
+			t.logger.Debugf("Cache request CRUD Error: %v\n", requestErr)
+			http.Error(w, fmt.Sprintf("Error: %v", requestErr), http.StatusBadRequest)
+		}
+	}()
+
+	name := mux.Vars(r)["name"]
+	if len(name) == 0 {
+		http.Error(w, "Var `name` must be set", http.StatusBadRequest)
+		return
+	}
+	key := mux.Vars(r)["key"]
+	if len(key) == 0 {
+		http.Error(w, "Var `key` must be set", http.StatusBadRequest)
+		return
+	}
+
+	c, err := t.GetCache(name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cache '%v' does not exist", name), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		var value []byte
+		if value, err = c.Get(key); err == types.ErrKeyNotFound {
+			http.Error(w, fmt.Sprintf("Key '%v' does not exist", key), http.StatusNotFound)
+		} else if err != nil {
+			serverErr = err
+		} else {
+			w.Write(value)
+		}
+	case "PUT":
+		var value []byte
+		if value, requestErr = ioutil.ReadAll(r.Body); requestErr != nil {
+			return
+		}
+		serverErr = c.Set(key, value)
+	case "DELETE":
+		serverErr = c.Delete(key)
+	default:
+		requestErr = errors.New("Method not supported")
+	}
+}
+
+//------------------------------------------------------------------------------
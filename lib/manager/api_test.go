@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package manager
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/gorilla/mux"
+)
+
+//------------------------------------------------------------------------------
+
+type muxReg struct {
+	router *mux.Router
+}
+
+func (m muxReg) RegisterEndpoint(path, desc string, h http.HandlerFunc) {
+	m.router.HandleFunc(path, h)
+}
+
+func TestManagerCacheAPI(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Caches["foo"] = cache.NewConfig()
+
+	router := mux.NewRouter()
+	if _, err := New(conf, muxReg{router: router}, testLog, metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(verb, url, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(verb, url, bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	if res := do("GET", "/caches/foo/bar", ""); res.Code != http.StatusNotFound {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusNotFound)
+	}
+	if res := do("GET", "/caches/nope/bar", ""); res.Code != http.StatusNotFound {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusNotFound)
+	}
+	if res := do("PUT", "/caches/nope/bar", "baz"); res.Code != http.StatusNotFound {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusNotFound)
+	}
+
+	if res := do("PUT", "/caches/foo/bar", "baz"); res.Code != http.StatusOK {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusOK)
+	}
+
+	res := do("GET", "/caches/foo/bar", "")
+	if res.Code != http.StatusOK {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusOK)
+	}
+	if exp, act := "baz", res.Body.String(); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+
+	if res := do("POST", "/caches/foo/bar", "qux"); res.Code != http.StatusBadRequest {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusBadRequest)
+	}
+
+	if res := do("DELETE", "/caches/foo/bar", ""); res.Code != http.StatusOK {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusOK)
+	}
+	if res := do("GET", "/caches/foo/bar", ""); res.Code != http.StatusNotFound {
+		t.Errorf("Wrong response code: %v != %v", res.Code, http.StatusNotFound)
+	}
+}
+
+//------------------------------------------------------------------------------
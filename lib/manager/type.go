@@ -23,10 +23,16 @@ package manager
 import (
 	"fmt"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/input"
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/output"
+	"github.com/Jeffail/benthos/lib/processor"
 	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/ratelimit"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
 )
@@ -44,6 +50,10 @@ type APIReg interface {
 type Config struct {
 	Caches     map[string]cache.Config     `json:"caches" yaml:"caches"`
 	Conditions map[string]condition.Config `json:"conditions" yaml:"conditions"`
+	Inputs     map[string]input.Config     `json:"inputs" yaml:"inputs"`
+	Outputs    map[string]output.Config    `json:"outputs" yaml:"outputs"`
+	Processors map[string]processor.Config `json:"processors" yaml:"processors"`
+	RateLimits map[string]ratelimit.Config `json:"rate_limits" yaml:"rate_limits"`
 }
 
 // NewConfig returns a Config with default values.
@@ -55,9 +65,82 @@ func NewConfig() Config {
 		Conditions: map[string]condition.Config{
 			"example": condition.NewConfig(),
 		},
+		Inputs:     map[string]input.Config{},
+		Outputs:    map[string]output.Config{},
+		Processors: map[string]processor.Config{},
+		RateLimits: map[string]ratelimit.Config{},
 	}
 }
 
+// SanitiseConfig returns a sanitised version of the Config, meaning sections
+// that aren't relevant to behaviour are removed.
+func SanitiseConfig(conf Config) (interface{}, error) {
+	var err error
+
+	conds := map[string]interface{}{}
+	for k, v := range conf.Conditions {
+		if conds[k], err = condition.SanitiseConfig(v); err != nil {
+			return nil, err
+		}
+	}
+	inputs := map[string]interface{}{}
+	for k, v := range conf.Inputs {
+		if inputs[k], err = input.SanitiseConfig(v); err != nil {
+			return nil, err
+		}
+	}
+	outputs := map[string]interface{}{}
+	for k, v := range conf.Outputs {
+		if outputs[k], err = output.SanitiseConfig(v); err != nil {
+			return nil, err
+		}
+	}
+	procs := map[string]interface{}{}
+	for k, v := range conf.Processors {
+		if procs[k], err = processor.SanitiseConfig(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]interface{}{
+		"caches":      conf.Caches,
+		"conditions":  conds,
+		"inputs":      inputs,
+		"outputs":     outputs,
+		"processors":  procs,
+		"rate_limits": conf.RateLimits,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// outputWriter is an output resource that accepts transactions from any number
+// of resource outputs.
+type outputWriter struct {
+	output    output.Type
+	tsChan    chan types.Transaction
+	closeChan chan struct{}
+}
+
+func newOutputWriter() *outputWriter {
+	return &outputWriter{
+		tsChan:    make(chan types.Transaction),
+		closeChan: make(chan struct{}),
+	}
+}
+
+// WriteTransaction attempts to pass a transaction to the output resource.
+func (w *outputWriter) WriteTransaction(t types.Transaction, timeout time.Duration) error {
+	select {
+	case w.tsChan <- t:
+	case <-w.closeChan:
+		return types.ErrTypeClosed
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
 // Type is an implementation of types.Manager, which is expected by Benthos
@@ -65,9 +148,16 @@ func NewConfig() Config {
 // endpoints and event listeners, and obtain service wide shared resources such
 // as caches and labelled conditions.
 type Type struct {
+	closed int32
+
 	apiReg     APIReg
+	logger     log.Modular
 	caches     map[string]types.Cache
 	conditions map[string]types.Condition
+	inputs     map[string]types.Input
+	outputs    map[string]*outputWriter
+	processors map[string]types.Processor
+	rateLimits map[string]types.RateLimit
 }
 
 // New returns an instance of manager.Type, which can be shared amongst
@@ -77,13 +167,30 @@ func New(
 	apiReg APIReg,
 	log log.Modular,
 	stats metrics.Type,
-) (*Type, error) {
+) (_ *Type, err error) {
 	t := &Type{
 		apiReg:     apiReg,
+		logger:     log.NewModule(".manager"),
 		caches:     map[string]types.Cache{},
 		conditions: map[string]types.Condition{},
+		inputs:     map[string]types.Input{},
+		outputs:    map[string]*outputWriter{},
+		processors: map[string]types.Processor{},
+		rateLimits: map[string]types.RateLimit{},
 	}
 
+	// Inputs begin consuming as soon as they are constructed, and therefore
+	// input resources are not able to refer to each other.
+	inputs := map[string]types.Input{}
+
+	// If a resource fails to construct then any resources already constructed
+	// must be shut down.
+	defer func() {
+		if err != nil {
+			t.closeStarted(inputs)
+		}
+	}()
+
 	for k, conf := range conf.Caches {
 		newCache, err := cache.New(conf, t, log, stats)
 		if err != nil {
@@ -95,6 +202,17 @@ func New(
 		t.caches[k] = newCache
 	}
 
+	for k, conf := range conf.RateLimits {
+		newRateLimit, err := ratelimit.New(conf, t, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to create rate limit resource '%v' of type '%v': %v",
+				k, conf.Type, err,
+			)
+		}
+		t.rateLimits[k] = newRateLimit
+	}
+
 	// Sometimes condition resources might refer to other condition resources.
 	// When they are constructed they will check with the manager to ensure the
 	// resource they point to is valid, but not use the condition. Since we
@@ -117,9 +235,70 @@ func New(
 		t.conditions[k] = newCond
 	}
 
-	// Note: Caches and conditions are considered READONLY from this point
-	// onwards and are therefore NOT protected by mutexes or channels.
+	// Processor resources are able to refer to each other in the same way as
+	// conditions.
+	for k := range conf.Processors {
+		t.processors[k] = nil
+	}
+
+	for k, newConf := range conf.Processors {
+		newProc, err := processor.New(
+			newConf, t,
+			log.NewModule(".processor.resource."+k),
+			metrics.Namespaced(stats, "processor.resource."+k),
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to create processor resource '%v' of type '%v': %v",
+				k, newConf.Type, err,
+			)
+		}
+		t.processors[k] = newProc
+	}
+
+	// Output resources are given their writers before construction so that
+	// they are able to refer to each other.
+	for k := range conf.Outputs {
+		t.outputs[k] = newOutputWriter()
+	}
+
+	for k, newConf := range conf.Outputs {
+		newOutput, err := output.New(newConf, t, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to create output resource '%v' of type '%v': %v",
+				k, newConf.Type, err,
+			)
+		}
+		if err = newOutput.StartReceiving(t.outputs[k].tsChan); err != nil {
+			return nil, fmt.Errorf(
+				"failed to start output resource '%v' of type '%v': %v",
+				k, newConf.Type, err,
+			)
+		}
+		t.outputs[k].output = newOutput
+	}
+
+	for k, newConf := range conf.Inputs {
+		newInput, err := input.New(newConf, t, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to create input resource '%v' of type '%v': %v",
+				k, newConf.Type, err,
+			)
+		}
+		inputs[k] = newInput
+	}
+	for k, v := range inputs {
+		t.inputs[k] = v
+	}
+
+	// Note: All resources are considered READONLY from this point onwards and
+	// are therefore NOT protected by mutexes or channels.
 
+	if apiReg != nil {
+		t.registerEndpoints()
+	}
 	return t, nil
 }
 
@@ -146,4 +325,133 @@ func (t *Type) GetCondition(name string) (types.Condition, error) {
 	return nil, types.ErrConditionNotFound
 }
 
+// GetInput attempts to find a service wide input by its name.
+func (t *Type) GetInput(name string) (types.Input, error) {
+	if i, exists := t.inputs[name]; exists {
+		return i, nil
+	}
+	return nil, types.ErrInputNotFound
+}
+
+// GetOutput attempts to find a service wide output by its name.
+func (t *Type) GetOutput(name string) (types.OutputWriter, error) {
+	if o, exists := t.outputs[name]; exists {
+		return o, nil
+	}
+	return nil, types.ErrOutputNotFound
+}
+
+// GetProcessor attempts to find a service wide processor by its name.
+func (t *Type) GetProcessor(name string) (types.Processor, error) {
+	if p, exists := t.processors[name]; exists {
+		return p, nil
+	}
+	return nil, types.ErrProcessorNotFound
+}
+
+// GetRateLimit attempts to find a service wide rate limit by its name.
+func (t *Type) GetRateLimit(name string) (types.RateLimit, error) {
+	if r, exists := t.rateLimits[name]; exists {
+		return r, nil
+	}
+	return nil, types.ErrRateLimitNotFound
+}
+
+//------------------------------------------------------------------------------
+
+// closeStarted shuts down all resources that have been constructed so far,
+// including inputs that have not yet been registered with the manager. This is
+// used to clean up after a resource fails to construct.
+func (t *Type) closeStarted(inputs map[string]types.Input) {
+	closables := []types.Closable{}
+	for _, i := range inputs {
+		closables = append(closables, i)
+	}
+	for _, o := range t.outputs {
+		close(o.closeChan)
+		if o.output != nil {
+			closables = append(closables, o.output)
+		}
+	}
+	for _, p := range t.processors {
+		if c, ok := p.(types.Closable); ok {
+			closables = append(closables, c)
+		}
+	}
+	for _, c := range closables {
+		c.CloseAsync()
+	}
+	for _, c := range closables {
+		if err := c.WaitForClose(time.Second); err != nil {
+			t.logger.Errorf("Failed to close resource: %v\n", err)
+		}
+	}
+	for k, c := range t.caches {
+		if closable, ok := c.(types.Closable); ok {
+			closable.CloseAsync()
+			if err := closable.WaitForClose(time.Second); err != nil {
+				t.logger.Errorf("Failed to close cache resource '%v': %v\n", k, err)
+			}
+		}
+	}
+}
+
+// CloseAsync triggers the shut down of all input, output and processor
+// resources. This should only be called once all streams that refer to the
+// resources have been closed.
+func (t *Type) CloseAsync() {
+	if !atomic.CompareAndSwapInt32(&t.closed, 0, 1) {
+		return
+	}
+	for _, i := range t.inputs {
+		i.CloseAsync()
+	}
+	for _, o := range t.outputs {
+		close(o.closeChan)
+		o.output.CloseAsync()
+	}
+	for _, p := range t.processors {
+		if c, ok := p.(types.Closable); ok {
+			c.CloseAsync()
+		}
+	}
+}
+
+// WaitForClose blocks until all input, output and processor resources have
+// closed down. Cache resources are closed once all other resources have closed,
+// since those resources might use caches whilst closing.
+func (t *Type) WaitForClose(timeout time.Duration) error {
+	started := time.Now()
+	for k, i := range t.inputs {
+		if err := i.WaitForClose(timeout - time.Since(started)); err != nil {
+			return fmt.Errorf("input resource '%v' failed to close: %v", k, err)
+		}
+	}
+	for k, o := range t.outputs {
+		if err := o.output.WaitForClose(timeout - time.Since(started)); err != nil {
+			return fmt.Errorf("output resource '%v' failed to close: %v", k, err)
+		}
+	}
+	for k, p := range t.processors {
+		if c, ok := p.(types.Closable); ok {
+			if err := c.WaitForClose(timeout - time.Since(started)); err != nil {
+				return fmt.Errorf("processor resource '%v' failed to close: %v", k, err)
+			}
+		}
+	}
+	for _, c := range t.caches {
+		if closable, ok := c.(types.Closable); ok {
+			closable.CloseAsync()
+		}
+	}
+	for k, c := range t.caches {
+		if closable, ok := c.(types.Closable); ok {
+			if err := closable.WaitForClose(timeout - time.Since(started)); err != nil {
+				return fmt.Errorf("cache resource '%v' failed to close: %v", k, err)
+			}
+		}
+	}
+	return nil
+}
+
 //------------------------------------------------------------------------------
@@ -21,12 +21,26 @@
 package manager
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/input"
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/output"
+	"github.com/Jeffail/benthos/lib/processor"
 	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/ratelimit"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
 )
@@ -56,6 +70,41 @@ func TestManagerCache(t *testing.T) {
 	}
 }
 
+func TestManagerCacheClose(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	tmpDir, err := ioutil.TempDir("", "benthos_manager_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cacheConf := cache.NewConfig()
+	cacheConf.Type = "file"
+	cacheConf.File.Path = filepath.Join(tmpDir, "cache.db")
+	cacheConf.File.ReservedDiskSpace = 0
+
+	conf := NewConfig()
+	conf.Caches["foo"] = cacheConf
+
+	mgr, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.CloseAsync()
+	if err = mgr.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache file is released once the manager has closed.
+	c, err := cache.New(cacheConf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatalf("Cache resource was not closed: %v", err)
+	}
+	c.(types.Closable).CloseAsync()
+}
+
 func TestManagerBadCache(t *testing.T) {
 	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
 
@@ -128,3 +177,301 @@ func TestManagerBadCondition(t *testing.T) {
 }
 
 //------------------------------------------------------------------------------
+
+func TestManagerProcessor(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+
+	fooConf := processor.NewConfig()
+	fooConf.Type = "insert_part"
+	fooConf.InsertPart.Content = "foo"
+	conf.Processors["foo"] = fooConf
+
+	barConf := processor.NewConfig()
+	barConf.Type = "resource"
+	barConf.Resource = "foo"
+	conf.Processors["bar"] = barConf
+
+	mgr, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc, err := mgr.GetProcessor("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("bar")}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if exp, act := [][]byte{[]byte("bar"), []byte("foo")}, msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	if _, err := mgr.GetProcessor("baz"); err != types.ErrProcessorNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrProcessorNotFound)
+	}
+}
+
+type fakeMetricPaths struct {
+	metrics.DudType
+	paths []string
+}
+
+func (f *fakeMetricPaths) GetCounter(path ...string) metrics.StatCounter {
+	f.paths = append(f.paths, strings.Join(path, "."))
+	return metrics.DudStat{}
+}
+
+func TestManagerProcessorMetrics(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	fooConf := processor.NewConfig()
+	fooConf.Type = "insert_part"
+	conf.Processors["foo"] = fooConf
+
+	stats := &fakeMetricPaths{}
+	if _, err := New(conf, nil, testLog, stats); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "processor.resource.foo.processor.insert_part.count"
+	found := false
+	for _, path := range stats.paths {
+		found = found || path == exp
+	}
+	if !found {
+		t.Errorf("Metric path %v not found in %v", exp, stats.paths)
+	}
+}
+
+func TestManagerBadProcessor(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	badConf := processor.NewConfig()
+	badConf.Type = "resource"
+	badConf.Resource = "notexist"
+	conf.Processors["bad"] = badConf
+
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Fatal("Expected error from bad processor")
+	}
+}
+
+func TestManagerBadOutputClosesProcessors(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	tmpDir, err := ioutil.TempDir("", "benthos_manager_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pidPath := filepath.Join(tmpDir, "pid")
+
+	conf := NewConfig()
+
+	procConf := processor.NewConfig()
+	procConf.Type = "subprocess"
+	procConf.Subprocess.Name = "sh"
+	procConf.Subprocess.Args = []string{
+		"-c", "echo $$ > " + pidPath + "; while read l; do :; done",
+	}
+	conf.Processors["foo"] = procConf
+
+	badConf := output.NewConfig()
+	badConf.Type = "notexist"
+	conf.Outputs["bad"] = badConf
+
+	if _, err = New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Fatal("Expected error from bad output")
+	}
+
+	// The subprocess may have been killed before writing its pid, otherwise it
+	// should no longer be running.
+	<-time.After(time.Millisecond * 100)
+	pidBytes, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		return
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	if err = proc.Signal(syscall.Signal(0)); err == nil {
+		proc.Kill()
+		t.Error("Processor resource was not closed")
+	}
+}
+
+func TestManagerInputOutput(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	tmpDir, err := ioutil.TempDir("", "benthos_manager_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inPath := filepath.Join(tmpDir, "input.txt")
+	if err = ioutil.WriteFile(inPath, []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []string
+	var receivedMut sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, rerr := ioutil.ReadAll(r.Body)
+		if rerr != nil {
+			t.Error(rerr)
+		}
+		receivedMut.Lock()
+		received = append(received, string(b))
+		receivedMut.Unlock()
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+
+	inConf := input.NewConfig()
+	inConf.Type = "file"
+	inConf.File.Path = inPath
+	conf.Inputs["foo"] = inConf
+
+	outConf := output.NewConfig()
+	outConf.Type = "http_client"
+	outConf.HTTPClient.URL = ts.URL
+	conf.Outputs["bar"] = outConf
+
+	mgr, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = mgr.GetInput("baz"); err != types.ErrInputNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrInputNotFound)
+	}
+	if _, err = mgr.GetOutput("baz"); err != types.ErrOutputNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrOutputNotFound)
+	}
+
+	resInConf := input.NewConfig()
+	resInConf.Type = "resource"
+	resInConf.Resource = "foo"
+	in, err := input.New(resInConf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resOutConf := output.NewConfig()
+	resOutConf.Type = "resource"
+	resOutConf.Resource = "bar"
+	out, err := output.New(resOutConf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = out.StartReceiving(in.TransactionChan()); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"hello", "world"}
+	for i := 0; i < 100; i++ {
+		receivedMut.Lock()
+		done := len(received) >= len(exp)
+		receivedMut.Unlock()
+		if done {
+			break
+		}
+		<-time.After(time.Millisecond * 10)
+	}
+	receivedMut.Lock()
+	if !reflect.DeepEqual(exp, received) {
+		t.Errorf("Wrong messages received: %v != %v", received, exp)
+	}
+	receivedMut.Unlock()
+
+	in.CloseAsync()
+	out.CloseAsync()
+	if err = in.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+	if err = out.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	mgr.CloseAsync()
+	mgr.CloseAsync()
+	if err = mgr.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerInputRecursion(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+
+	fooConf := input.NewConfig()
+	fooConf.Type = "resource"
+	fooConf.Resource = "bar"
+	conf.Inputs["foo"] = fooConf
+
+	barConf := input.NewConfig()
+	barConf.Type = "resource"
+	barConf.Resource = "foo"
+	conf.Inputs["bar"] = barConf
+
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from input resources referring to each other")
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestManagerRateLimit(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.RateLimits["foo"] = ratelimit.NewConfig()
+
+	procConf := processor.NewConfig()
+	procConf.Type = "rate_limit"
+	procConf.RateLimit.Resource = "foo"
+	conf.Processors["bar"] = procConf
+
+	mgr, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mgr.GetRateLimit("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.GetRateLimit("baz"); err != types.ErrRateLimitNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrRateLimitNotFound)
+	}
+}
+
+func TestManagerBadRateLimit(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	badConf := ratelimit.NewConfig()
+	badConf.Type = "notexist"
+	conf.RateLimits["bad"] = badConf
+
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Fatal("Expected error from bad rate limit")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -36,7 +36,8 @@ func init() {
 Sends message parts as objects to an Amazon S3 bucket. Each object is uploaded
 with the path specified with the 'path' field, in order to have a different path
 for each object you should use function interpolations described
-[here](../config_interpolation.md#functions).`,
+[here](../config_interpolation.md#functions), which are calculated per message
+part.`,
 	}
 }
 
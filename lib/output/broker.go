@@ -23,6 +23,7 @@ package output
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Jeffail/benthos/lib/broker"
 	"github.com/Jeffail/benthos/lib/metrics"
@@ -105,7 +106,37 @@ attempts to send to the next output in the list and so on.
 This pattern is useful for triggering events in the case where certain output
 targets have broken. For example, if you had an output type ` + "`http_client`" + `
 but wished to reroute messages whenever the endpoint becomes unreachable you
-could use a try broker.
+could use a try broker. If you instead wish to retry an output a number of times
+and then send the messages that failed, along with the reason for the failure,
+to a separate output then use the [` + "`dead_letter`" + `](#dead_letter) output.
+
+#### ` + "`partition`" + `
+
+The partition pattern sends each message to a single output chosen by hashing
+the value of ` + "`partition.key`" + `, which should contain
+[function interpolations](../config_interpolation.md#functions) that resolve to
+an identifier of the message, such as ` + "`${!json_field:user.id}`" + `. Every
+message with the same key is sent to the same output, and therefore messages of
+a key are delivered in order. Outputs are selected using rendezvous hashing,
+which means changing the number of outputs only moves the keys of the outputs
+that were added or removed.
+
+If an output applies back pressure it will block all subsequent messages. By
+default an output that fails to send a message will continue to be retried
+until it succeeds. If ` + "`partition.fallback`" + ` is set to ` + "`true`" + `
+then a message that fails because its output is unavailable is instead
+re-attempted with the next preferred output for its key, and so on. An output is
+unavailable when it reports that it is not connected or has closed, or when it
+does not accept and acknowledge the message within
+` + "`partition.fallback_timeout_ms`" + ` milliseconds, which is how most outputs
+behave while they are reconnecting. Other errors are returned to the input so
+that the message is retried with the same output. Since the broker must wait for
+each message to be acknowledged before routing the next, enabling fallback
+limits the broker to a single message in flight.
+
+An output that times out may still deliver the message once it recovers, in
+which case the message is delivered by more than one output. The timeout should
+therefore comfortably exceed the time an output normally takes to send.
 
 ### Utilising More Outputs
 
@@ -126,19 +157,39 @@ nodes processors.`,
 
 //------------------------------------------------------------------------------
 
+// BrokerPartitionConfig contains configuration fields for the partition broker
+// pattern.
+type BrokerPartitionConfig struct {
+	Key               string `json:"key" yaml:"key"`
+	Fallback          bool   `json:"fallback" yaml:"fallback"`
+	FallbackTimeoutMS int    `json:"fallback_timeout_ms" yaml:"fallback_timeout_ms"`
+}
+
+// NewBrokerPartitionConfig creates a new BrokerPartitionConfig with default
+// values.
+func NewBrokerPartitionConfig() BrokerPartitionConfig {
+	return BrokerPartitionConfig{
+		Key:               "",
+		Fallback:          false,
+		FallbackTimeoutMS: 5000,
+	}
+}
+
 // BrokerConfig is configuration for the Broker output type.
 type BrokerConfig struct {
-	Copies  int              `json:"copies" yaml:"copies"`
-	Pattern string           `json:"pattern" yaml:"pattern"`
-	Outputs brokerOutputList `json:"outputs" yaml:"outputs"`
+	Copies    int                   `json:"copies" yaml:"copies"`
+	Pattern   string                `json:"pattern" yaml:"pattern"`
+	Partition BrokerPartitionConfig `json:"partition" yaml:"partition"`
+	Outputs   brokerOutputList      `json:"outputs" yaml:"outputs"`
 }
 
 // NewBrokerConfig creates a new BrokerConfig with default values.
 func NewBrokerConfig() BrokerConfig {
 	return BrokerConfig{
-		Copies:  1,
-		Pattern: "fan_out",
-		Outputs: brokerOutputList{},
+		Copies:    1,
+		Pattern:   "fan_out",
+		Partition: NewBrokerPartitionConfig(),
+		Outputs:   brokerOutputList{},
 	}
 }
 
@@ -185,6 +236,14 @@ func NewBroker(
 		return broker.NewGreedy(outputs)
 	case "try":
 		return broker.NewTry(outputs, stats)
+	case "partition":
+		return broker.NewPartition(
+			outputs,
+			conf.Broker.Partition.Key,
+			conf.Broker.Partition.Fallback,
+			time.Duration(conf.Broker.Partition.FallbackTimeoutMS)*time.Millisecond,
+			stats,
+		)
 	}
 
 	return nil, fmt.Errorf("broker pattern was not recognised: %v", conf.Broker.Pattern)
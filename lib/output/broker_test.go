@@ -26,6 +26,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Jeffail/benthos/lib/broker"
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
@@ -237,3 +238,85 @@ func TestRoundRobinWithScaleProto(t *testing.T) {
 		}
 	}
 }
+
+//------------------------------------------------------------------------------
+
+type writerRecv struct {
+	msgs chan types.Message
+}
+
+func (w writerRecv) Connect() error { return nil }
+func (w writerRecv) Write(msg types.Message) error {
+	w.msgs <- msg
+	return nil
+}
+func (w writerRecv) CloseAsync() {}
+func (w writerRecv) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestPartitionFallbackWithWriter(t *testing.T) {
+	t.Parallel()
+
+	down, err := NewWriter(
+		"down", writerCantConnect{},
+		log.New(os.Stdout, logConfig), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := writerRecv{msgs: make(chan types.Message, 10)}
+	up, err := NewWriter(
+		"up", recv,
+		log.New(os.Stdout, logConfig), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := broker.NewPartition(
+		[]types.Output{down, up}, "${!content}", true,
+		time.Millisecond*50, metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = p.StartReceiving(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every key must reach the connected output, including keys that prefer
+	// the output that cannot connect.
+	for i := 0; i < 10; i++ {
+		content := []byte(fmt.Sprintf("key%v", i))
+		select {
+		case readChan <- types.NewTransaction(types.NewMessage([][]byte{content}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker send")
+		}
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Errorf("Unexpected error for %s: %v", content, res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for response to %s", content)
+		}
+		select {
+		case msg := <-recv.msgs:
+			if act := string(msg.Get(0)); act != string(content) {
+				t.Errorf("Wrong content: %v != %s", act, content)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s", content)
+		}
+	}
+
+	p.CloseAsync()
+	if err = p.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
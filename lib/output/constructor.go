@@ -31,6 +31,7 @@ import (
 	"github.com/Jeffail/benthos/lib/output/writer"
 	"github.com/Jeffail/benthos/lib/pipeline"
 	"github.com/Jeffail/benthos/lib/processor"
+	"github.com/Jeffail/benthos/lib/processor/condition"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/util/config"
 	"github.com/Jeffail/benthos/lib/log"
@@ -66,6 +67,7 @@ type Config struct {
 	AmazonSQS     writer.AmazonSQSConfig     `json:"amazon_sqs" yaml:"amazon_sqs"`
 	AMQP          AMQPConfig                 `json:"amqp" yaml:"amqp"`
 	Broker        BrokerConfig               `json:"broker" yaml:"broker"`
+	DeadLetter    DeadLetterConfig           `json:"dead_letter" yaml:"dead_letter"`
 	Dynamic       DynamicConfig              `json:"dynamic" yaml:"dynamic"`
 	Elasticsearch writer.ElasticsearchConfig `json:"elasticsearch" yaml:"elasticsearch"`
 	File          FileConfig                 `json:"file" yaml:"file"`
@@ -79,8 +81,10 @@ type Config struct {
 	NSQ           NSQConfig                  `json:"nsq" yaml:"nsq"`
 	RedisList     writer.RedisListConfig     `json:"redis_list" yaml:"redis_list"`
 	RedisPubSub   RedisPubSubConfig          `json:"redis_pubsub" yaml:"redis_pubsub"`
+	Resource      string                     `json:"resource" yaml:"resource"`
 	ScaleProto    ScaleProtoConfig           `json:"scalability_protocols" yaml:"scalability_protocols"`
 	STDOUT        STDOUTConfig               `json:"stdout" yaml:"stdout"`
+	Switch        SwitchConfig               `json:"switch" yaml:"switch"`
 	Websocket     writer.WebsocketConfig     `json:"websocket" yaml:"websocket"`
 	ZMQ4          *writer.ZMQ4Config         `json:"zmq4,omitempty" yaml:"zmq4,omitempty"`
 	Processors    []processor.Config         `json:"processors" yaml:"processors"`
@@ -94,6 +98,7 @@ func NewConfig() Config {
 		AmazonSQS:     writer.NewAmazonSQSConfig(),
 		AMQP:          NewAMQPConfig(),
 		Broker:        NewBrokerConfig(),
+		DeadLetter:    NewDeadLetterConfig(),
 		Dynamic:       NewDynamicConfig(),
 		Elasticsearch: writer.NewElasticsearchConfig(),
 		File:          NewFileConfig(),
@@ -107,8 +112,10 @@ func NewConfig() Config {
 		NSQ:           NewNSQConfig(),
 		RedisList:     writer.NewRedisListConfig(),
 		RedisPubSub:   NewRedisPubSubConfig(),
+		Resource:      "",
 		ScaleProto:    NewScaleProtoConfig(),
 		STDOUT:        NewSTDOUTConfig(),
+		Switch:        NewSwitchConfig(),
 		Websocket:     writer.NewWebsocketConfig(),
 		ZMQ4:          writer.NewZMQ4Config(),
 		Processors:    []processor.Config{},
@@ -148,11 +155,61 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 			}
 			outSlice = append(outSlice, sanOutput)
 		}
-		outputMap[t] = map[string]interface{}{
+		brokerMap := map[string]interface{}{
 			"copies":  conf.Broker.Copies,
 			"pattern": conf.Broker.Pattern,
 			"outputs": outSlice,
 		}
+		if conf.Broker.Pattern == "partition" {
+			brokerMap["partition"] = conf.Broker.Partition
+		}
+		outputMap[t] = brokerMap
+	} else if t == "dead_letter" {
+		dlMap := map[string]interface{}{
+			"max_retries":     conf.DeadLetter.MaxRetries,
+			"retry_period_ms": conf.DeadLetter.RetryPeriodMS,
+		}
+		for k, output := range map[string]*Config{
+			"primary":   conf.DeadLetter.Primary,
+			"secondary": conf.DeadLetter.Secondary,
+		} {
+			if output == nil {
+				defConf := NewConfig()
+				output = &defConf
+			}
+			var sanOutput interface{}
+			if sanOutput, err = SanitiseConfig(*output); err != nil {
+				return nil, err
+			}
+			dlMap[k] = sanOutput
+		}
+		outputMap[t] = dlMap
+	} else if t == "switch" {
+		caseSlice := []interface{}{}
+		for _, caseConf := range conf.Switch.Cases {
+			var sanCond, sanOutput interface{}
+			if sanCond, err = condition.SanitiseConfig(caseConf.Condition); err != nil {
+				return nil, err
+			}
+			if sanOutput, err = SanitiseConfig(caseConf.Output); err != nil {
+				return nil, err
+			}
+			caseSlice = append(caseSlice, map[string]interface{}{
+				"condition": sanCond,
+				"output":    sanOutput,
+			})
+		}
+		var sanDefault interface{}
+		if conf.Switch.Default != nil {
+			if sanDefault, err = SanitiseConfig(*conf.Switch.Default); err != nil {
+				return nil, err
+			}
+		}
+		outputMap[t] = map[string]interface{}{
+			"mode":    conf.Switch.Mode,
+			"cases":   caseSlice,
+			"default": sanDefault,
+		}
 	} else {
 		outputMap[t] = hashMap[t]
 	}
@@ -0,0 +1,392 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["dead_letter"] = TypeSpec{
+		constructor: NewDeadLetter,
+		description: `
+The dead letter output type wraps a primary output and attempts to send each
+message to it. If the primary output fails to send a message then it is retried
+up to ` + "`max_retries`" + ` times, waiting ` + "`retry_period_ms`" + ` between
+attempts. When the retries are exhausted the message is sent to the secondary
+output instead, allowing the pipeline to continue.
+
+` + "``` yaml" + `
+output:
+  type: dead_letter
+  dead_letter:
+    max_retries: 3
+    retry_period_ms: 1000
+    primary:
+      type: elasticsearch
+      elasticsearch:
+        urls:
+        - http://localhost:9200
+        index: benthos_index
+    secondary:
+      type: files
+      files:
+        path: ./dead_letters/${!count:dead_letters}.json
+` + "```" + `
+
+Each part of a message sent to the secondary output is wrapped within a JSON
+envelope that records why it was rejected:
+
+` + "``` json" + `
+{
+  "content": "dGhlIG9yaWdpbmFsIGNvbnRlbnRzIG9mIHRoZSBwYXJ0",
+  "error": "the final error returned by the primary output",
+  "attempts": 4,
+  "first_attempt": "2018-06-25T09:12:01.403515Z",
+  "last_attempt": "2018-06-25T09:12:04.411926Z"
+}
+` + "```" + `
+
+The ` + "`content`" + ` field contains the original contents of the part
+encoded as base64, which allows binary data to be preserved. The metadata of
+each part is preserved. Messages can be replayed from the envelope by
+extracting the ` + "`content`" + ` field with a
+` + "[`json`](../processors/README.md#json)" + ` processor using the
+` + "`select`" + ` operator, followed by a
+` + "[`decode`](../processors/README.md#decode)" + ` processor with the scheme
+` + "`base64`" + `.
+
+The field ` + "`max_retries`" + ` cannot be negative.
+
+If the secondary output also fails to send a message then the error is
+returned to the input, and the message is attempted again from the primary
+output.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// DeadLetterConfig is configuration for the DeadLetter output type.
+type DeadLetterConfig struct {
+	Primary       *Config `json:"primary" yaml:"primary"`
+	Secondary     *Config `json:"secondary" yaml:"secondary"`
+	MaxRetries    int     `json:"max_retries" yaml:"max_retries"`
+	RetryPeriodMS int     `json:"retry_period_ms" yaml:"retry_period_ms"`
+}
+
+// NewDeadLetterConfig creates a new DeadLetterConfig with default values. The
+// primary and secondary outputs are left nil and will default to a standard
+// output config when the output is created.
+func NewDeadLetterConfig() DeadLetterConfig {
+	return DeadLetterConfig{
+		Primary:       nil,
+		Secondary:     nil,
+		MaxRetries:    3,
+		RetryPeriodMS: 1000,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// errNegativeRetries is returned when a DeadLetter is configured with a
+// negative max_retries.
+var errNegativeRetries = errors.New("max_retries cannot be negative")
+
+// deadLetterEnvelope is the JSON structure that wraps message parts sent to the
+// secondary output.
+type deadLetterEnvelope struct {
+	Content      []byte `json:"content"`
+	Error        string `json:"error"`
+	Attempts     int    `json:"attempts"`
+	FirstAttempt string `json:"first_attempt"`
+	LastAttempt  string `json:"last_attempt"`
+}
+
+//------------------------------------------------------------------------------
+
+// DeadLetter is an output type that attempts to send messages to a primary
+// output, and on repeated failure sends them to a secondary output along with
+// the reason for the failure.
+type DeadLetter struct {
+	running int32
+
+	log   log.Modular
+	stats metrics.Type
+
+	maxRetries  int
+	retryPeriod time.Duration
+
+	primary   Type
+	secondary Type
+
+	transactions <-chan types.Transaction
+
+	primaryTsChan   chan types.Transaction
+	secondaryTsChan chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewDeadLetter creates a new DeadLetter output type.
+func NewDeadLetter(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if conf.DeadLetter.MaxRetries < 0 {
+		return nil, errNegativeRetries
+	}
+	primaryConf, secondaryConf := NewConfig(), NewConfig()
+	if conf.DeadLetter.Primary != nil {
+		primaryConf = *conf.DeadLetter.Primary
+	}
+	if conf.DeadLetter.Secondary != nil {
+		secondaryConf = *conf.DeadLetter.Secondary
+	}
+
+	primary, err := New(primaryConf, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := New(secondaryConf, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return newDeadLetter(conf.DeadLetter, primary, secondary, log, stats)
+}
+
+// newDeadLetter creates a new DeadLetter output type from already constructed
+// primary and secondary outputs.
+func newDeadLetter(
+	conf DeadLetterConfig,
+	primary, secondary Type,
+	log log.Modular,
+	stats metrics.Type,
+) (*DeadLetter, error) {
+	if conf.MaxRetries < 0 {
+		return nil, errNegativeRetries
+	}
+	d := &DeadLetter{
+		running:         1,
+		log:             log.NewModule(".output.dead_letter"),
+		stats:           stats,
+		maxRetries:      conf.MaxRetries,
+		retryPeriod:     time.Millisecond * time.Duration(conf.RetryPeriodMS),
+		primary:         primary,
+		secondary:       secondary,
+		primaryTsChan:   make(chan types.Transaction),
+		secondaryTsChan: make(chan types.Transaction),
+		closeChan:       make(chan struct{}),
+		closedChan:      make(chan struct{}),
+	}
+	if err := primary.StartReceiving(d.primaryTsChan); err != nil {
+		return nil, err
+	}
+	if err := secondary.StartReceiving(d.secondaryTsChan); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+//------------------------------------------------------------------------------
+
+// wrapMessage creates a new message where each part is a JSON envelope of the
+// original part along with the reason for the failure.
+func wrapMessage(
+	msg types.Message,
+	err error,
+	attempts int,
+	firstAttempt, lastAttempt time.Time,
+) types.Message {
+	errStr := ""
+	if err != nil {
+		errStr = err.Error()
+	}
+	newMsg := types.NewMessage(nil)
+	msg.Iter(func(i int, part []byte) error {
+		envelope, _ := json.Marshal(deadLetterEnvelope{
+			Content:      part,
+			Error:        errStr,
+			Attempts:     attempts,
+			FirstAttempt: firstAttempt.Format(time.RFC3339Nano),
+			LastAttempt:  lastAttempt.Format(time.RFC3339Nano),
+		})
+		newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(envelope))
+		return nil
+	})
+	return newMsg
+}
+
+// send sends a message to an output and waits for a response. Returns false if
+// the output was closed during the attempt.
+func (d *DeadLetter) send(
+	tsChan chan<- types.Transaction,
+	msg types.Message,
+	resChan chan types.Response,
+) (types.Response, bool) {
+	select {
+	case tsChan <- types.NewTransaction(msg, resChan):
+	case <-d.closeChan:
+		return nil, false
+	}
+	select {
+	case res, open := <-resChan:
+		return res, open
+	case <-d.closeChan:
+		return nil, false
+	}
+}
+
+// loop is an internal loop that brokers incoming messages to the primary and
+// secondary outputs.
+func (d *DeadLetter) loop() {
+	var (
+		mCount        = d.stats.GetCounter("output.dead_letter.count")
+		mPrimSuccess  = d.stats.GetCounter("output.dead_letter.primary.success")
+		mPrimErr      = d.stats.GetCounter("output.dead_letter.primary.error")
+		mPrimRetry    = d.stats.GetCounter("output.dead_letter.primary.retry")
+		mSecSuccess   = d.stats.GetCounter("output.dead_letter.secondary.success")
+		mSecErr       = d.stats.GetCounter("output.dead_letter.secondary.error")
+		mRunning      = d.stats.GetCounter("output.dead_letter.running")
+		mRunningTotal = d.stats.GetCounter("output.running")
+	)
+
+	defer func() {
+		close(d.primaryTsChan)
+		close(d.secondaryTsChan)
+		mRunning.Decr(1)
+		mRunningTotal.Decr(1)
+		close(d.closedChan)
+	}()
+	mRunning.Incr(1)
+	mRunningTotal.Incr(1)
+
+	resChan := make(chan types.Response)
+	for atomic.LoadInt32(&d.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-d.transactions:
+			if !open {
+				return
+			}
+			mCount.Incr(1)
+		case <-d.closeChan:
+			return
+		}
+
+		var res types.Response
+		var attempts int
+		firstAttempt := time.Now()
+		lastAttempt := firstAttempt
+
+		for attempts <= d.maxRetries {
+			if attempts > 0 {
+				mPrimRetry.Incr(1)
+				select {
+				case <-time.After(d.retryPeriod):
+				case <-d.closeChan:
+					return
+				}
+			}
+			lastAttempt = time.Now()
+			attempts++
+			if res, open = d.send(d.primaryTsChan, ts.Payload, resChan); !open {
+				return
+			}
+			if res.Error() == nil {
+				mPrimSuccess.Incr(1)
+				break
+			}
+			mPrimErr.Incr(1)
+			d.log.Debugf("Failed to send message to primary output: %v\n", res.Error())
+		}
+
+		if res.Error() != nil {
+			d.log.Warnf(
+				"Sending message to secondary output after %v attempts: %v\n",
+				attempts, res.Error(),
+			)
+			wrapped := wrapMessage(ts.Payload, res.Error(), attempts, firstAttempt, lastAttempt)
+			if res, open = d.send(d.secondaryTsChan, wrapped, resChan); !open {
+				return
+			}
+			if res.Error() == nil {
+				mSecSuccess.Incr(1)
+			} else {
+				mSecErr.Incr(1)
+				d.log.Errorf("Failed to send message to secondary output: %v\n", res.Error())
+			}
+		}
+
+		select {
+		case ts.ResponseChan <- res:
+		case <-d.closeChan:
+			return
+		}
+	}
+}
+
+// StartReceiving assigns a messages channel for the output to read.
+func (d *DeadLetter) StartReceiving(ts <-chan types.Transaction) error {
+	if d.transactions != nil {
+		return types.ErrAlreadyStarted
+	}
+	d.transactions = ts
+	go d.loop()
+	return nil
+}
+
+// CloseAsync shuts down the DeadLetter output and stops processing messages.
+func (d *DeadLetter) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&d.running, 1, 0) {
+		d.primary.CloseAsync()
+		d.secondary.CloseAsync()
+		close(d.closeChan)
+	}
+}
+
+// WaitForClose blocks until the DeadLetter output has closed down.
+func (d *DeadLetter) WaitForClose(timeout time.Duration) error {
+	tStarted := time.Now()
+	select {
+	case <-d.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	if err := d.primary.WaitForClose(timeout - time.Since(tStarted)); err != nil {
+		return err
+	}
+	return d.secondary.WaitForClose(timeout - time.Since(tStarted))
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,284 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type mockDLOutput struct {
+	tChan <-chan types.Transaction
+}
+
+func (m *mockDLOutput) StartReceiving(ts <-chan types.Transaction) error {
+	m.tChan = ts
+	return nil
+}
+
+func (m *mockDLOutput) CloseAsync() {}
+
+func (m *mockDLOutput) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func TestDeadLetterFromConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "dead_letter"
+
+	primConf := NewConfig()
+	primConf.Type = "http_client"
+	conf.DeadLetter.Primary = &primConf
+
+	secConf := NewConfig()
+	secConf.Type = "http_client"
+	conf.DeadLetter.Secondary = &secConf
+
+	d, err := New(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.StartReceiving(make(chan types.Transaction)); err != nil {
+		t.Fatal(err)
+	}
+
+	d.CloseAsync()
+	d.CloseAsync()
+	if err = d.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeadLetterNegativeRetries(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "dead_letter"
+	conf.DeadLetter.MaxRetries = -1
+
+	if _, err := New(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{}); err == nil {
+		t.Error("Expected error from negative max_retries")
+	}
+
+	if _, err := newDeadLetter(
+		conf.DeadLetter, &mockDLOutput{}, &mockDLOutput{},
+		log.New(os.Stdout, logConfig), metrics.DudType{},
+	); err != errNegativeRetries {
+		t.Errorf("Wrong error from negative max_retries: %v", err)
+	}
+}
+
+func TestDeadLetterWrapBinary(t *testing.T) {
+	exp := []byte{0xff, 0x00, 0xfe, 'a'}
+	wrapped := wrapMessage(
+		types.NewMessage([][]byte{exp}), errors.New("test err"), 1,
+		time.Now(), time.Now(),
+	)
+
+	var envelope deadLetterEnvelope
+	if err := json.Unmarshal(wrapped.Get(0), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if act := envelope.Content; !bytes.Equal(exp, act) {
+		t.Errorf("Wrong envelope content: %v != %v", act, exp)
+	}
+}
+
+func TestDeadLetterPrimarySuccess(t *testing.T) {
+	primary, secondary := &mockDLOutput{}, &mockDLOutput{}
+
+	conf := NewDeadLetterConfig()
+	conf.RetryPeriodMS = 1
+
+	d, err := newDeadLetter(conf, primary, secondary, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = d.StartReceiving(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{[]byte("hello world")})
+	select {
+	case tChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	for i := 0; i < 2; i++ {
+		var ts types.Transaction
+		select {
+		case ts = <-primary.tChan:
+		case <-secondary.tChan:
+			t.Fatal("Message sent to secondary")
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+		if exp, act := "hello world", string(ts.Payload.Get(0)); exp != act {
+			t.Errorf("Wrong message contents: %v != %v", act, exp)
+		}
+
+		var resErr error
+		if i == 0 {
+			resErr = errors.New("test err")
+		}
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(resErr):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	d.CloseAsync()
+	if err = d.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeadLetterSecondary(t *testing.T) {
+	primary, secondary := &mockDLOutput{}, &mockDLOutput{}
+
+	conf := NewDeadLetterConfig()
+	conf.MaxRetries = 2
+	conf.RetryPeriodMS = 1
+
+	d, err := newDeadLetter(conf, primary, secondary, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = d.StartReceiving(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{[]byte("hello"), []byte("world")})
+	msg.GetMetadata(1).Set("foo", "bar")
+	select {
+	case tChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	for i := 0; i < 3; i++ {
+		var ts types.Transaction
+		select {
+		case ts = <-primary.tChan:
+		case <-secondary.tChan:
+			t.Fatal("Message sent to secondary early")
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(errors.New("test err")):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	var ts types.Transaction
+	select {
+	case ts = <-secondary.tChan:
+	case <-primary.tChan:
+		t.Fatal("Too many attempts on primary")
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	if exp, act := 2, ts.Payload.Len(); exp != act {
+		t.Fatalf("Wrong count of parts: %v != %v", act, exp)
+	}
+	for i, exp := range []string{"hello", "world"} {
+		var envelope deadLetterEnvelope
+		if err = json.Unmarshal(ts.Payload.Get(i), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if act := string(envelope.Content); act != exp {
+			t.Errorf("Wrong envelope content: %v != %v", act, exp)
+		}
+		if exp, act := "test err", envelope.Error; act != exp {
+			t.Errorf("Wrong envelope error: %v != %v", act, exp)
+		}
+		if exp, act := 3, envelope.Attempts; act != exp {
+			t.Errorf("Wrong envelope attempts: %v != %v", act, exp)
+		}
+		first, err := time.Parse(time.RFC3339Nano, envelope.FirstAttempt)
+		if err != nil {
+			t.Error(err)
+		}
+		last, err := time.Parse(time.RFC3339Nano, envelope.LastAttempt)
+		if err != nil {
+			t.Error(err)
+		}
+		if last.Before(first) {
+			t.Errorf("Last attempt before first: %v < %v", last, first)
+		}
+	}
+	if exp, act := "bar", ts.Payload.GetMetadata(1).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+
+	select {
+	case ts.ResponseChan <- types.NewSimpleResponse(nil):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	d.CloseAsync()
+	if err = d.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -34,7 +34,11 @@ func init() {
 		constructor: NewElasticsearch,
 		description: `
 Publishes messages into an Elasticsearch index as documents. This output
-currently does not support creating the target index.`,
+currently does not support creating the target index.
+
+Both the 'id' and 'index' fields can be dynamically set using function
+interpolations described [here](../config_interpolation.md#functions), which
+are calculated per message part.`,
 	}
 }
 
@@ -42,7 +46,7 @@ currently does not support creating the target index.`,
 
 // NewElasticsearch creates a new Elasticsearch output type.
 func NewElasticsearch(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	elasticWriter, err := writer.NewElasticsearch(conf.Elasticsearch, log, stats)
+	elasticWriter, err := writer.NewElasticsearch(conf.Elasticsearch, mgr, log, stats)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +38,9 @@ Writes each individual part of each message to a new file.
 Message parts only contain raw data, and therefore in order to create a unique
 file for each part you need to generate unique file names. This can be done by
 using function interpolations on the 'path' field as described
-[here](../config_interpolation.md#functions).`,
+[here](../config_interpolation.md#functions), which are calculated per message
+part. For example, ` + "`${!metadata:path}`" + ` would write each part to a path
+taken from its metadata.`,
 	}
 }
 
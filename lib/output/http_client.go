@@ -48,13 +48,20 @@ the send is attempted again until successful whilst applying back pressure.
 
 The body of the HTTP request is the raw contents of the message payload. If the
 message has multiple parts the request will be sent according to
-[RFC1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html)`,
+[RFC1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html)
+
+The 'url' field can be dynamically set using function interpolations described
+[here](../config_interpolation.md#functions), which are calculated against the
+whole message.`,
 	}
 }
 
 // NewHTTPClient creates a new HTTPClient output type.
 func NewHTTPClient(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	h := writer.NewHTTPClient(conf.HTTPClient, log, stats)
+	h, err := writer.NewHTTPClient(conf.HTTPClient, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
 	return NewWriter("http_client", h, log, stats)
 }
 
@@ -0,0 +1,199 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["resource"] = TypeSpec{
+		constructor: NewResource,
+		description: `
+Resource is an output type that sends messages to an output resource by its
+name. Output resources are shared, which means many outputs, or outputs of many
+streams, can send messages through the same connection.
+
+For example, a single Kafka producer can be shared by the cases of a switch
+output:
+
+` + "``` yaml" + `
+output:
+  type: switch
+  switch:
+    cases:
+    - condition:
+        type: check
+        check: 'json("type") == "audit"'
+      output:
+        type: resource
+        resource: kafka_out
+        processors:
+        - type: jmespath
+          jmespath:
+            query: audit
+    - output:
+        type: resource
+        resource: kafka_out
+resources:
+  outputs:
+    kafka_out:
+      type: kafka
+      kafka:
+        addresses:
+        - localhost:9092
+        topic: benthos_stream
+` + "```" + `
+
+Output resources are closed when the service shuts down rather than when the
+streams that refer to them are closed.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Resource is an output that writes messages to an output resource.
+type Resource struct {
+	running int32
+
+	name   string
+	writer types.OutputWriter
+
+	log   log.Modular
+	stats metrics.Type
+
+	transactions <-chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewResource returns a resource output.
+func NewResource(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	w, err := mgr.GetOutput(conf.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain output resource '%v': %v", conf.Resource, err)
+	}
+	return &Resource{
+		running:    1,
+		name:       conf.Resource,
+		writer:     w,
+		log:        log.NewModule(".output.resource"),
+		stats:      stats,
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// loop is an internal loop that passes transactions to the output resource.
+func (r *Resource) loop() {
+	var (
+		mCount        = r.stats.GetCounter("output.resource.count")
+		mErr          = r.stats.GetCounter("output.resource.error")
+		mRunning      = r.stats.GetCounter("output.resource.running")
+		mRunningTotal = r.stats.GetCounter("output.running")
+	)
+
+	defer func() {
+		mRunning.Decr(1)
+		mRunningTotal.Decr(1)
+		close(r.closedChan)
+	}()
+	mRunning.Incr(1)
+	mRunningTotal.Incr(1)
+
+	for atomic.LoadInt32(&r.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-r.transactions:
+			if !open {
+				return
+			}
+		case <-r.closeChan:
+			return
+		}
+		mCount.Incr(1)
+
+		var err error
+		for {
+			if err = r.writer.WriteTransaction(ts, time.Millisecond*100); err != types.ErrTimeout {
+				break
+			}
+			if atomic.LoadInt32(&r.running) != 1 {
+				break
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		mErr.Incr(1)
+		r.log.Debugf("Failed to send message to output resource '%v': %v\n", r.name, err)
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(err):
+		case <-r.closeChan:
+			return
+		}
+	}
+}
+
+// StartReceiving assigns a messages channel for the output to read.
+func (r *Resource) StartReceiving(ts <-chan types.Transaction) error {
+	if r.transactions != nil {
+		return types.ErrAlreadyStarted
+	}
+	r.transactions = ts
+	go r.loop()
+	return nil
+}
+
+// CloseAsync shuts down the Resource output and stops processing messages.
+// The output resource itself is not closed.
+func (r *Resource) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&r.running, 1, 0) {
+		close(r.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Resource output has closed down.
+func (r *Resource) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-r.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
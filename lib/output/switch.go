@@ -0,0 +1,392 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/throttle"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["switch"] = TypeSpec{
+		constructor: NewSwitch,
+		description: `
+The switch output type routes messages to outputs according to conditions. It
+has an ordered list of cases, each containing a condition and an output, and
+an optional default output.
+
+` + "``` yaml" + `
+output:
+  type: switch
+  switch:
+    mode: first
+    cases:
+    - condition:
+        type: check
+        check: 'json("level") == "error"'
+      output:
+        type: kafka
+        kafka:
+          topic: errors
+    - condition:
+        type: check
+        check: 'json("type") == "metric"'
+      output:
+        type: http_client
+        http_client:
+          url: http://localhost:8080/metrics
+    default:
+      type: files
+      files:
+        path: ./other/${!count:files}.json
+` + "```" + `
+
+When ` + "`mode`" + ` is ` + "`first`" + ` each message is sent only to the
+output of the first case with a condition that passes. When ` + "`mode`" + ` is
+` + "`all`" + ` each message is sent to the outputs of all cases with conditions
+that pass. A case without a condition always passes.
+
+Messages that do not pass the condition of any case are sent to the
+` + "`default`" + ` output if one is configured, otherwise they are dropped.
+
+A message is only acknowledged once every output it was sent to has
+successfully sent it. When an output fails to send a message it is retried for
+that output only, until it succeeds.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SwitchCaseConfig contains a condition and an output for an individual case
+// in the Switch output type.
+type SwitchCaseConfig struct {
+	Condition condition.Config `json:"condition" yaml:"condition"`
+	Output    Config           `json:"output" yaml:"output"`
+}
+
+// NewSwitchCaseConfig creates a new SwitchCaseConfig with default values.
+func NewSwitchCaseConfig() SwitchCaseConfig {
+	cond := condition.NewConfig()
+	cond.Type = "static"
+	cond.Static = true
+	return SwitchCaseConfig{
+		Condition: cond,
+		Output:    NewConfig(),
+	}
+}
+
+// UnmarshalJSON ensures that when parsing configs that are in a slice the
+// default values are still applied.
+func (s *SwitchCaseConfig) UnmarshalJSON(bytes []byte) error {
+	type confAlias SwitchCaseConfig
+	aliased := confAlias(NewSwitchCaseConfig())
+
+	if err := json.Unmarshal(bytes, &aliased); err != nil {
+		return err
+	}
+
+	*s = SwitchCaseConfig(aliased)
+	return nil
+}
+
+// UnmarshalYAML ensures that when parsing configs that are in a slice the
+// default values are still applied.
+func (s *SwitchCaseConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type confAlias SwitchCaseConfig
+	aliased := confAlias(NewSwitchCaseConfig())
+
+	if err := unmarshal(&aliased); err != nil {
+		return err
+	}
+
+	*s = SwitchCaseConfig(aliased)
+	return nil
+}
+
+// SwitchConfig is configuration for the Switch output type.
+type SwitchConfig struct {
+	Mode    string             `json:"mode" yaml:"mode"`
+	Cases   []SwitchCaseConfig `json:"cases" yaml:"cases"`
+	Default *Config            `json:"default" yaml:"default"`
+}
+
+// NewSwitchConfig creates a new SwitchConfig with default values. The default
+// output is left nil, meaning messages that match no cases are dropped.
+func NewSwitchConfig() SwitchConfig {
+	return SwitchConfig{
+		Mode:    "first",
+		Cases:   []SwitchCaseConfig{},
+		Default: nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Switch is an output type that routes messages to outputs by conditions.
+type Switch struct {
+	running int32
+
+	log   log.Modular
+	stats metrics.Type
+
+	throt *throttle.Type
+
+	allMatches bool
+	conditions []condition.Type
+	outputs    []Type
+	hasDefault bool
+
+	transactions <-chan types.Transaction
+
+	outputTsChans  []chan types.Transaction
+	outputResChans []chan types.Response
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewSwitch creates a new Switch output type.
+func NewSwitch(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	var conditions []condition.Type
+	var outputs []Type
+	for i, caseConf := range conf.Switch.Cases {
+		cond, err := condition.New(caseConf.Condition, mgr, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create case %v condition: %v", i, err)
+		}
+		output, err := New(caseConf.Output, mgr, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create case %v output: %v", i, err)
+		}
+		conditions = append(conditions, cond)
+		outputs = append(outputs, output)
+	}
+	if conf.Switch.Default != nil {
+		output, err := New(*conf.Switch.Default, mgr, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create default output: %v", err)
+		}
+		outputs = append(outputs, output)
+	}
+	return newSwitch(conf.Switch, conditions, outputs, log, stats)
+}
+
+// newSwitch creates a new Switch output type from already constructed
+// conditions and outputs, where an output following the last condition is the
+// default output.
+func newSwitch(
+	conf SwitchConfig,
+	conditions []condition.Type,
+	outputs []Type,
+	log log.Modular,
+	stats metrics.Type,
+) (*Switch, error) {
+	var allMatches bool
+	switch conf.Mode {
+	case "first":
+	case "all":
+		allMatches = true
+	default:
+		return nil, fmt.Errorf("mode not recognised: %v", conf.Mode)
+	}
+
+	s := &Switch{
+		running:        1,
+		log:            log.NewModule(".output.switch"),
+		stats:          stats,
+		allMatches:     allMatches,
+		conditions:     conditions,
+		outputs:        outputs,
+		hasDefault:     len(outputs) > len(conditions),
+		outputTsChans:  make([]chan types.Transaction, len(outputs)),
+		outputResChans: make([]chan types.Response, len(outputs)),
+		closeChan:      make(chan struct{}),
+		closedChan:     make(chan struct{}),
+	}
+	s.throt = throttle.New(throttle.OptCloseChan(s.closeChan))
+
+	for i, output := range outputs {
+		s.outputTsChans[i] = make(chan types.Transaction)
+		s.outputResChans[i] = make(chan types.Response)
+		if err := output.StartReceiving(s.outputTsChans[i]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// targets returns the indexes of the outputs that a message should be sent to.
+func (s *Switch) targets(msg types.Message) []int {
+	var targets []int
+	for i, cond := range s.conditions {
+		if cond.Check(msg) {
+			targets = append(targets, i)
+			if !s.allMatches {
+				break
+			}
+		}
+	}
+	if len(targets) == 0 && s.hasDefault {
+		targets = append(targets, len(s.outputs)-1)
+	}
+	return targets
+}
+
+// loop is an internal loop that routes incoming messages to outputs.
+func (s *Switch) loop() {
+	var (
+		mMsgsRcvd     = s.stats.GetCounter("output.switch.messages.received")
+		mOutputErr    = s.stats.GetCounter("output.switch.output.error")
+		mMsgsSnt      = s.stats.GetCounter("output.switch.messages.sent")
+		mUnmatched    = s.stats.GetCounter("output.switch.messages.unmatched")
+		mDropped      = s.stats.GetCounter("output.switch.messages.dropped")
+		mRunning      = s.stats.GetCounter("output.switch.running")
+		mRunningTotal = s.stats.GetCounter("output.running")
+	)
+
+	defer func() {
+		for _, c := range s.outputTsChans {
+			close(c)
+		}
+		mRunning.Decr(1)
+		mRunningTotal.Decr(1)
+		close(s.closedChan)
+	}()
+	mRunning.Incr(1)
+	mRunningTotal.Incr(1)
+
+	for atomic.LoadInt32(&s.running) == 1 {
+		var ts types.Transaction
+		var open bool
+
+		select {
+		case ts, open = <-s.transactions:
+			if !open {
+				return
+			}
+		case <-s.closeChan:
+			return
+		}
+		mMsgsRcvd.Incr(1)
+
+		outputTargets := s.targets(ts.Payload)
+		if len(outputTargets) == 0 {
+			mUnmatched.Incr(1)
+			mDropped.Incr(1)
+		} else if s.hasDefault && outputTargets[0] == len(s.outputs)-1 {
+			mUnmatched.Incr(1)
+		}
+
+		for len(outputTargets) > 0 {
+			for _, i := range outputTargets {
+				// Perform a copy here as it could be dangerous to release the
+				// same message to parallel processor pipelines.
+				msgCopy := ts.Payload.ShallowCopy()
+				select {
+				case s.outputTsChans[i] <- types.NewTransaction(msgCopy, s.outputResChans[i]):
+				case <-s.closeChan:
+					return
+				}
+			}
+			newTargets := []int{}
+			for _, i := range outputTargets {
+				select {
+				case res := <-s.outputResChans[i]:
+					if res.Error() != nil {
+						newTargets = append(newTargets, i)
+						s.log.Errorf("Failed to dispatch switch message: %v\n", res.Error())
+						mOutputErr.Incr(1)
+						if !s.throt.Retry() {
+							return
+						}
+					} else {
+						s.throt.Reset()
+						mMsgsSnt.Incr(1)
+					}
+				case <-s.closeChan:
+					return
+				}
+			}
+			outputTargets = newTargets
+		}
+
+		select {
+		case ts.ResponseChan <- types.NewSimpleResponse(nil):
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+// StartReceiving assigns a messages channel for the output to read.
+func (s *Switch) StartReceiving(ts <-chan types.Transaction) error {
+	if s.transactions != nil {
+		return types.ErrAlreadyStarted
+	}
+	s.transactions = ts
+	go s.loop()
+	return nil
+}
+
+// CloseAsync shuts down the Switch output and stops processing messages.
+func (s *Switch) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&s.running, 1, 0) {
+		for _, output := range s.outputs {
+			output.CloseAsync()
+		}
+		close(s.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Switch output has closed down.
+func (s *Switch) WaitForClose(timeout time.Duration) error {
+	tStarted := time.Now()
+	select {
+	case <-s.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	for _, output := range s.outputs {
+		if err := output.WaitForClose(timeout - time.Since(tStarted)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
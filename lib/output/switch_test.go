@@ -0,0 +1,249 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+func newSwitchContentCond(t *testing.T, arg string) condition.Type {
+	t.Helper()
+
+	conf := condition.NewConfig()
+	conf.Type = "content"
+	conf.Content.Operator = "contains_cs"
+	conf.Content.Arg = arg
+
+	cond, err := condition.New(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cond
+}
+
+func sendSwitchMsg(t *testing.T, tChan chan types.Transaction, resChan chan types.Response, content string) {
+	t.Helper()
+	select {
+	case tChan <- types.NewTransaction(types.NewMessage([][]byte{[]byte(content)}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+}
+
+func expectSwitchMsg(t *testing.T, output *mockDLOutput, content string) types.Transaction {
+	t.Helper()
+	var ts types.Transaction
+	select {
+	case ts = <-output.tChan:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	if act := string(ts.Payload.Get(0)); act != content {
+		t.Errorf("Wrong message contents: %v != %v", act, content)
+	}
+	return ts
+}
+
+func respondSwitchMsg(t *testing.T, ts types.Transaction, err error) {
+	t.Helper()
+	select {
+	case ts.ResponseChan <- types.NewSimpleResponse(err):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+}
+
+func expectSwitchRes(t *testing.T, resChan chan types.Response) {
+	t.Helper()
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+}
+
+func expectNoSwitchRes(t *testing.T, resChan chan types.Response) {
+	t.Helper()
+	select {
+	case <-resChan:
+		t.Fatal("Received premature response")
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestSwitchFromConfig(t *testing.T) {
+	conf := NewConfig()
+	if err := yaml.Unmarshal([]byte(`
+type: switch
+switch:
+  mode: all
+  cases:
+  - condition:
+      type: check
+      check: 'content() == "foo"'
+    output:
+      type: http_client
+  - output:
+      type: http_client
+  default:
+    type: http_client
+`), &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "static", conf.Switch.Cases[1].Condition.Type; exp != act {
+		t.Errorf("Wrong default condition type: %v != %v", act, exp)
+	}
+
+	s, err := New(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.StartReceiving(make(chan types.Transaction)); err != nil {
+		t.Fatal(err)
+	}
+
+	s.CloseAsync()
+	s.CloseAsync()
+	if err = s.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	conf.Switch.Mode = "nope"
+	if _, err = New(conf, nil, log.New(os.Stdout, logConfig), metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad mode")
+	}
+}
+
+func TestSwitchFirstMatch(t *testing.T) {
+	fooOut, barOut, defOut := &mockDLOutput{}, &mockDLOutput{}, &mockDLOutput{}
+
+	conf := NewSwitchConfig()
+	conf.Mode = "first"
+
+	s, err := newSwitch(
+		conf,
+		[]condition.Type{newSwitchContentCond(t, "foo"), newSwitchContentCond(t, "bar")},
+		[]Type{fooOut, barOut, defOut},
+		log.New(os.Stdout, logConfig), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = s.StartReceiving(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	// Matches both cases, but only the first should receive it.
+	sendSwitchMsg(t, tChan, resChan, "foo bar")
+	ts := expectSwitchMsg(t, fooOut, "foo bar")
+	respondSwitchMsg(t, ts, nil)
+	expectSwitchRes(t, resChan)
+
+	sendSwitchMsg(t, tChan, resChan, "bar")
+	ts = expectSwitchMsg(t, barOut, "bar")
+	respondSwitchMsg(t, ts, nil)
+	expectSwitchRes(t, resChan)
+
+	sendSwitchMsg(t, tChan, resChan, "baz")
+	ts = expectSwitchMsg(t, defOut, "baz")
+	respondSwitchMsg(t, ts, nil)
+	expectSwitchRes(t, resChan)
+
+	s.CloseAsync()
+	if err = s.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSwitchAllMatches(t *testing.T) {
+	fooOut, barOut := &mockDLOutput{}, &mockDLOutput{}
+
+	conf := NewSwitchConfig()
+	conf.Mode = "all"
+
+	s, err := newSwitch(
+		conf,
+		[]condition.Type{newSwitchContentCond(t, "foo"), newSwitchContentCond(t, "bar")},
+		[]Type{fooOut, barOut},
+		log.New(os.Stdout, logConfig), metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = s.StartReceiving(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	sendSwitchMsg(t, tChan, resChan, "foo bar")
+	fooTs := expectSwitchMsg(t, fooOut, "foo bar")
+	barTs := expectSwitchMsg(t, barOut, "foo bar")
+
+	// The message is only acknowledged once both outputs have succeeded, and a
+	// failed output is retried alone.
+	respondSwitchMsg(t, fooTs, nil)
+	respondSwitchMsg(t, barTs, errors.New("nope"))
+	expectNoSwitchRes(t, resChan)
+
+	barTs = expectSwitchMsg(t, barOut, "foo bar")
+	select {
+	case <-fooOut.tChan:
+		t.Fatal("Message resent to successful output")
+	default:
+	}
+	respondSwitchMsg(t, barTs, nil)
+	expectSwitchRes(t, resChan)
+
+	// Without a default output unmatched messages are acknowledged and
+	// dropped.
+	sendSwitchMsg(t, tChan, resChan, "baz")
+	expectSwitchRes(t, resChan)
+
+	s.CloseAsync()
+	if err = s.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -146,22 +146,21 @@ func (a *AmazonS3) Write(msg types.Message) error {
 		return types.ErrNotConnected
 	}
 
-	for _, part := range msg.GetAll() {
+	return msg.Iter(func(i int, part []byte) error {
 		path := a.conf.Path
 		if a.interpolatePath {
-			path = string(text.ReplaceFunctionVariables(a.pathBytes))
+			path = string(text.ReplaceFunctionVariablesForPart(
+				msg, i, a.pathBytes,
+			))
 		}
 
-		if _, err := a.uploader.Upload(&s3manager.UploadInput{
+		_, err := a.uploader.Upload(&s3manager.UploadInput{
 			Body:   bytes.NewReader(part),
 			Bucket: aws.String(a.conf.Bucket),
 			Key:    aws.String(path),
-		}); err != nil {
-			return err
-		}
-	}
-
-	return nil
+		})
+		return err
+	})
 }
 
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
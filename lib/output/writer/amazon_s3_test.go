@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+func TestAmazonS3InterpolatedPerPart(t *testing.T) {
+	var mut sync.Mutex
+	uploads := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		mut.Lock()
+		uploads[r.URL.Path] = string(body)
+		mut.Unlock()
+		w.Header().Set("ETag", "\"test\"")
+	}))
+	defer ts.Close()
+
+	conf := NewAmazonS3Config()
+	conf.Bucket = "test_bucket"
+	conf.Path = "${!batch_size}/${!metadata:id}.txt"
+
+	a := NewAmazonS3(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("eu-west-1").
+		WithEndpoint(ts.URL).
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.session = sess
+	a.uploader = s3manager.NewUploader(sess)
+
+	msg := types.NewMessage([][]byte{[]byte("foo"), []byte("bar")})
+	msg.GetMetadata(0).Set("id", "a")
+	msg.GetMetadata(1).Set("id", "b")
+	if err = a.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]string{
+		"/test_bucket/2/a.txt": "foo",
+		"/test_bucket/2/b.txt": "bar",
+	}
+	mut.Lock()
+	if !reflect.DeepEqual(exp, uploads) {
+		t.Errorf("Wrong uploads: %v != %v", uploads, exp)
+	}
+	mut.Unlock()
+}
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/ratelimit"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/util/http/auth"
 	"github.com/Jeffail/benthos/lib/log"
@@ -44,6 +45,7 @@ type ElasticsearchConfig struct {
 	Index     string               `json:"index" yaml:"index"`
 	TimeoutMS int                  `json:"timeout_ms" yaml:"timeout_ms"`
 	Auth      auth.BasicAuthConfig `json:"basic_auth" yaml:"basic_auth"`
+	RateLimit string               `json:"rate_limit" yaml:"rate_limit"`
 }
 
 // NewElasticsearchConfig creates a new ElasticsearchConfig with default values.
@@ -54,6 +56,7 @@ func NewElasticsearchConfig() ElasticsearchConfig {
 		Index:     "benthos_index",
 		TimeoutMS: 5000,
 		Auth:      auth.NewBasicAuthConfig(),
+		RateLimit: "",
 	}
 }
 
@@ -70,20 +73,45 @@ type Elasticsearch struct {
 	idBytes       []byte
 	interpolateID bool
 
+	indexBytes       []byte
+	interpolateIndex bool
+
+	rateLimit types.RateLimit
+
 	client *elastic.Client
+
+	closeChan chan struct{}
 }
 
 // NewElasticsearch creates a new Elasticsearch writer type.
-func NewElasticsearch(conf ElasticsearchConfig, log log.Modular, stats metrics.Type) (*Elasticsearch, error) {
+func NewElasticsearch(
+	conf ElasticsearchConfig,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (*Elasticsearch, error) {
 	idBytes := []byte(conf.ID)
 	interpolateID := text.ContainsFunctionVariables(idBytes)
 
+	indexBytes := []byte(conf.Index)
+	interpolateIndex := text.ContainsFunctionVariables(indexBytes)
+
 	e := Elasticsearch{
-		log:           log.NewModule(".output.elasticsearch"),
-		stats:         stats,
-		conf:          conf,
-		idBytes:       idBytes,
-		interpolateID: interpolateID,
+		log:              log.NewModule(".output.elasticsearch"),
+		stats:            stats,
+		conf:             conf,
+		idBytes:          idBytes,
+		interpolateID:    interpolateID,
+		indexBytes:       indexBytes,
+		interpolateIndex: interpolateIndex,
+		closeChan:        make(chan struct{}),
+	}
+
+	if len(conf.RateLimit) > 0 {
+		var err error
+		if e.rateLimit, err = mgr.GetRateLimit(conf.RateLimit); err != nil {
+			return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.RateLimit, err)
+		}
 	}
 
 	for _, u := range conf.URLs {
@@ -123,7 +151,9 @@ func (e *Elasticsearch) Connect() error {
 		return err
 	}
 
-	if err == nil {
+	// When the index is interpolated we cannot know the target indexes ahead of
+	// time.
+	if err == nil && !e.interpolateIndex {
 		var indexExists bool
 		indexExists, err = e.client.IndexExists(e.conf.Index).Do(context.Background())
 		if err == nil && !indexExists {
@@ -147,11 +177,21 @@ func (e *Elasticsearch) Write(msg types.Message) error {
 	return msg.Iter(func(i int, part []byte) error {
 		id := e.idBytes
 		if e.interpolateID {
-			id = text.ReplaceFunctionVariables(id)
+			id = text.ReplaceFunctionVariablesForPart(msg, i, id)
+		}
+		index := e.conf.Index
+		if e.interpolateIndex {
+			index = string(text.ReplaceFunctionVariablesForPart(msg, i, e.indexBytes))
+		}
+
+		if e.rateLimit != nil {
+			if _, err := ratelimit.Wait(e.rateLimit, e.closeChan, e.log); err != nil {
+				return err
+			}
 		}
 
 		_, err := e.client.Index().
-			Index(e.conf.Index).
+			Index(index).
 			Type("doc").
 			Id(string(id)).
 			BodyString(string(part)).
@@ -163,6 +203,7 @@ func (e *Elasticsearch) Write(msg types.Message) error {
 
 // CloseAsync shuts down the Elasticsearch writer and stops processing messages.
 func (e *Elasticsearch) CloseAsync() {
+	close(e.closeChan)
 }
 
 // WaitForClose blocks until the Elasticsearch writer has closed down.
@@ -23,8 +23,12 @@ package writer
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,6 +39,60 @@ import (
 	"github.com/ory/dockertest"
 )
 
+func TestElasticInterpolatedPerPart(t *testing.T) {
+	var mut sync.Mutex
+	docs := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		mut.Lock()
+		docs[r.URL.Path] = string(body)
+		mut.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"_index":"test","_type":"doc","_id":"test","result":"created"}`))
+	}))
+	defer ts.Close()
+
+	conf := NewElasticsearchConfig()
+	conf.URLs = []string{ts.URL}
+	conf.Index = "${!metadata:index}-${!batch_size}"
+	conf.ID = "${!json_field:id}-${!json_field:id,0}"
+
+	e, err := NewElasticsearch(conf, nil, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.client, err = elastic.NewClient(
+		elastic.SetURL(ts.URL),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{
+		[]byte(`{"id":"foo"}`),
+		[]byte(`{"id":"bar"}`),
+	})
+	msg.GetMetadata(0).Set("index", "a")
+	msg.GetMetadata(1).Set("index", "b")
+	if err = e.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]string{
+		"/a-2/doc/foo-foo": `{"id":"foo"}`,
+		"/b-2/doc/bar-foo": `{"id":"bar"}`,
+	}
+	mut.Lock()
+	if !reflect.DeepEqual(exp, docs) {
+		t.Errorf("Wrong documents: %v != %v", docs, exp)
+	}
+	mut.Unlock()
+}
+
 func TestElasticIntegration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -115,7 +173,7 @@ func testElasticConnect(urls []string, client *elastic.Client, t *testing.T) {
 	conf.ID = "${!count:foo}"
 	conf.URLs = urls
 
-	m, err := NewElasticsearch(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	m, err := NewElasticsearch(conf, nil, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -85,21 +85,20 @@ func (f *Files) Connect() error {
 
 // Write attempts to write message contents to a directory as files.
 func (f *Files) Write(msg types.Message) error {
-	for _, part := range msg.GetAll() {
+	return msg.Iter(func(i int, part []byte) error {
 		path := f.conf.Path
 		if f.interpolatePath {
-			path = string(text.ReplaceFunctionVariables(f.pathBytes))
+			path = string(text.ReplaceFunctionVariablesForPart(
+				msg, i, f.pathBytes,
+			))
 		}
 
 		err := os.MkdirAll(filepath.Dir(path), os.FileMode(0777))
 		if err != nil {
 			return err
 		}
-		if err = ioutil.WriteFile(path, part, os.FileMode(0666)); err != nil {
-			return err
-		}
-	}
-	return nil
+		return ioutil.WriteFile(path, part, os.FileMode(0666))
+	})
 }
 
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestFilesInterpolatedPerPart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_files_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFilesConfig()
+	conf.Path = filepath.Join(dir, "${!batch_size}-${!metadata:id}-${!content:0}.txt")
+
+	f := NewFiles(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+
+	msg := types.NewMessage([][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})
+	msg.GetMetadata(0).Set("id", "a")
+	msg.GetMetadata(1).Set("id", "b")
+	msg.GetMetadata(2).Set("id", "c")
+	if err = f.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, exp := range map[string]string{
+		"3-a-foo.txt": "foo",
+		"3-b-foo.txt": "bar",
+		"3-c-foo.txt": "baz",
+	} {
+		act, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("Failed to read file %v: %v", name, err)
+			continue
+		}
+		if exp != string(act) {
+			t.Errorf("Wrong contents of file %v: %s != %v", name, act, exp)
+		}
+	}
+}
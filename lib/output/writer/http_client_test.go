@@ -55,7 +55,10 @@ func TestHTTPClientRetries(t *testing.T) {
 	conf.RetryMS = 1
 	conf.NumRetries = 3
 
-	h := NewHTTPClient(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	h, err := NewHTTPClient(conf, nil, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if err := h.Write(types.NewMessage([][]byte{[]byte("test")})); err == nil {
 		t.Error("Expected error from end of retries")
 	}
@@ -70,6 +73,43 @@ func TestHTTPClientRetries(t *testing.T) {
 	}
 }
 
+func TestHTTPClientInterpolatedURL(t *testing.T) {
+	pathChan := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathChan <- r.URL.Path
+	}))
+	defer ts.Close()
+
+	conf := NewHTTPClientConfig()
+	conf.URL = ts.URL + "/${!batch_size}/${!metadata:id,1}"
+
+	h, err := NewHTTPClient(conf, nil, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{[]byte("foo"), []byte("bar")})
+	msg.GetMetadata(0).Set("id", "a")
+	msg.GetMetadata(1).Set("id", "b")
+	if err = h.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case path := <-pathChan:
+		if exp, act := "/2/b", path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Action timed out")
+	}
+
+	h.CloseAsync()
+	if err := h.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestHTTPClientBasic(t *testing.T) {
 	nTestLoops := 1000
 
@@ -92,7 +132,10 @@ func TestHTTPClientBasic(t *testing.T) {
 	conf := NewHTTPClientConfig()
 	conf.URL = ts.URL + "/testpost"
 
-	h := NewHTTPClient(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	h, err := NewHTTPClient(conf, nil, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	for i := 0; i < nTestLoops; i++ {
 		testStr := fmt.Sprintf("test%v", i)
@@ -173,7 +216,10 @@ func TestHTTPClientMultipart(t *testing.T) {
 	conf := NewHTTPClientConfig()
 	conf.URL = ts.URL + "/testpost"
 
-	h := NewHTTPClient(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	h, err := NewHTTPClient(conf, nil, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	for i := 0; i < nTestLoops; i++ {
 		testStr := fmt.Sprintf("test%v", i)
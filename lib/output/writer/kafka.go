@@ -78,6 +78,9 @@ type Kafka struct {
 	keyBytes       []byte
 	interpolateKey bool
 
+	topicBytes       []byte
+	interpolateTopic bool
+
 	producer    sarama.SyncProducer
 	compression sarama.CompressionCodec
 }
@@ -87,18 +90,23 @@ func NewKafka(conf KafkaConfig, log log.Modular, stats metrics.Type) (*Kafka, er
 	keyBytes := []byte(conf.Key)
 	interpolateKey := text.ContainsFunctionVariables(keyBytes)
 
+	topicBytes := []byte(conf.Topic)
+	interpolateTopic := text.ContainsFunctionVariables(topicBytes)
+
 	compression, err := strToCompressionCodec(conf.Compression)
 	if err != nil {
 		return nil, err
 	}
 
 	k := Kafka{
-		log:            log.NewModule(".output.kafka"),
-		stats:          stats,
-		conf:           conf,
-		keyBytes:       keyBytes,
-		interpolateKey: interpolateKey,
-		compression:    compression,
+		log:              log.NewModule(".output.kafka"),
+		stats:            stats,
+		conf:             conf,
+		keyBytes:         keyBytes,
+		interpolateKey:   interpolateKey,
+		topicBytes:       topicBytes,
+		interpolateTopic: interpolateTopic,
+		compression:      compression,
 	}
 
 	if k.version, err = sarama.ParseKafkaVersion(conf.TargetVersion); err != nil {
@@ -178,7 +186,7 @@ func (k *Kafka) Write(msg types.Message) error {
 	}
 
 	msgs := []*sarama.ProducerMessage{}
-	for _, part := range msg.GetAll() {
+	for i, part := range msg.GetAll() {
 		if len(part) > k.conf.MaxMsgBytes {
 			k.stats.Incr("output.kafka.send.dropped.max_msg_bytes", 1)
 			continue
@@ -186,10 +194,14 @@ func (k *Kafka) Write(msg types.Message) error {
 
 		key := k.keyBytes
 		if k.interpolateKey {
-			key = text.ReplaceFunctionVariables(k.keyBytes)
+			key = text.ReplaceFunctionVariablesForPart(msg, i, k.keyBytes)
+		}
+		topic := k.conf.Topic
+		if k.interpolateTopic {
+			topic = string(text.ReplaceFunctionVariablesForPart(msg, i, k.topicBytes))
 		}
 		nextMsg := &sarama.ProducerMessage{
-			Topic: k.conf.Topic,
+			Topic: topic,
 			Value: sarama.ByteEncoder(part),
 		}
 		if len(key) > 0 {
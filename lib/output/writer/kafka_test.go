@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Shopify/sarama"
+)
+
+type mockSyncProducer struct {
+	msgs []*sarama.ProducerMessage
+}
+
+func (m *mockSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	m.msgs = append(m.msgs, msg)
+	return 0, 0, nil
+}
+
+func (m *mockSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	m.msgs = append(m.msgs, msgs...)
+	return nil
+}
+
+func (m *mockSyncProducer) Close() error {
+	return nil
+}
+
+func TestKafkaInterpolatedPerPart(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Key = "${!batch_size}-${!metadata:id}"
+	conf.Topic = "${!json_field:topic}-${!json_field:topic,0}"
+
+	k, err := NewKafka(conf, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &mockSyncProducer{}
+	k.producer = producer
+
+	msg := types.NewMessage([][]byte{
+		[]byte(`{"topic":"foo"}`),
+		[]byte(`{"topic":"bar"}`),
+	})
+	msg.GetMetadata(0).Set("id", "a")
+	msg.GetMetadata(1).Set("id", "b")
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of messages: %v != %v", act, exp)
+	}
+	for i, exp := range []struct {
+		key   string
+		topic string
+	}{
+		{key: "2-a", topic: "foo-foo"},
+		{key: "2-b", topic: "bar-foo"},
+	} {
+		key, _ := producer.msgs[i].Key.Encode()
+		if act := string(key); exp.key != act {
+			t.Errorf("Wrong key at index %v: %v != %v", i, act, exp.key)
+		}
+		if act := producer.msgs[i].Topic; exp.topic != act {
+			t.Errorf("Wrong topic at index %v: %v != %v", i, act, exp.topic)
+		}
+	}
+}
@@ -74,11 +74,50 @@ func NewProcessor(
 
 //------------------------------------------------------------------------------
 
+// flushPeriod returns the shortest flush period of all processors that buffer
+// messages, or zero if there are none.
+func (p *Processor) flushPeriod() time.Duration {
+	var period time.Duration
+	for _, proc := range p.msgProcessors {
+		if f, ok := proc.(processor.Flusher); ok {
+			if fp := f.FlushPeriod(); fp > 0 && (period == 0 || fp < period) {
+				period = fp
+			}
+		}
+	}
+	return period
+}
+
+// process applies the processors of the pipeline, starting from the processor
+// at index from, to a slice of messages.
+func (p *Processor) process(
+	from int, msgs []types.Message,
+) ([]types.Message, types.Response) {
+	resultMsgs := msgs
+	var resultRes types.Response
+	for i := from; len(resultMsgs) > 0 && i < len(p.msgProcessors); i++ {
+		var nextResultMsgs []types.Message
+		for _, m := range resultMsgs {
+			var rMsgs []types.Message
+			rMsgs, resultRes = p.msgProcessors[i].ProcessMessage(m)
+			nextResultMsgs = append(nextResultMsgs, rMsgs...)
+		}
+		resultMsgs = nextResultMsgs
+	}
+	return resultMsgs, resultRes
+}
+
 // loop is the processing loop of this pipeline.
 func (p *Processor) loop() {
 	defer func() {
 		atomic.StoreInt32(&p.running, 0)
 
+		for _, proc := range p.msgProcessors {
+			if c, ok := proc.(types.Closable); ok {
+				c.CloseAsync()
+			}
+		}
+
 		close(p.messagesOut)
 		close(p.closed)
 	}()
@@ -86,100 +125,158 @@ func (p *Processor) loop() {
 	var (
 		mProcCount   = p.stats.GetCounter("pipeline.processor.count")
 		mProcDropped = p.stats.GetCounter("pipeline.processor.dropped")
+		mProcFlushed = p.stats.GetCounter("pipeline.processor.flushed")
 		mSndSucc     = p.stats.GetCounter("pipeline.processor.send.success")
 		mSndErr      = p.stats.GetCounter("pipeline.processor.send.error")
 	)
 
 	throt := throttle.New(throttle.OptCloseChan(p.closeChan))
 
-	var open bool
-	for atomic.LoadInt32(&p.running) == 1 {
-		var tran types.Transaction
-		select {
-		case tran, open = <-p.messagesIn:
-			if !open {
-				return
-			}
-		case <-p.closeChan:
-			return
-		}
-		mProcCount.Incr(1)
+	sendMsg := func(m types.Message, skipAcks *int64) {
+		resChan := make(chan types.Response)
+		transac := types.NewTransaction(m, resChan)
 
-		resultMsgs := []types.Message{tran.Payload}
-		var resultRes types.Response
-		for i := 0; len(resultMsgs) > 0 && i < len(p.msgProcessors); i++ {
-			var nextResultMsgs []types.Message
-			for _, m := range resultMsgs {
-				var rMsgs []types.Message
-				rMsgs, resultRes = p.msgProcessors[i].ProcessMessage(m)
-				nextResultMsgs = append(nextResultMsgs, rMsgs...)
-			}
-			resultMsgs = nextResultMsgs
-		}
-
-		if len(resultMsgs) == 0 {
-			mProcDropped.Incr(1)
+		for {
 			select {
-			case tran.ResponseChan <- resultRes:
+			case p.messagesOut <- transac:
 			case <-p.closeChan:
 				return
 			}
-			continue
-		}
-
-		var skipAcks int64
-		sendMsg := func(m types.Message) {
-			resChan := make(chan types.Response)
-			transac := types.NewTransaction(m, resChan)
-
-			for {
-				select {
-				case p.messagesOut <- transac:
-				case <-p.closeChan:
-					return
-				}
 
-				var res types.Response
-				var open bool
-				select {
-				case res, open = <-resChan:
-					if !open {
-						return
-					}
-				case <-p.closeChan:
+			var res types.Response
+			var open bool
+			select {
+			case res, open = <-resChan:
+				if !open {
 					return
 				}
+			case <-p.closeChan:
+				return
+			}
 
-				if skipAck := res.SkipAck(); res.Error() == nil || skipAck {
-					if skipAck {
-						atomic.AddInt64(&skipAcks, 1)
-					}
-					mSndSucc.Incr(1)
-					return
-				}
-				mSndErr.Incr(1)
-				if !throt.Retry() {
-					return
+			if skipAck := res.SkipAck(); res.Error() == nil || skipAck {
+				if skipAck {
+					atomic.AddInt64(skipAcks, 1)
 				}
+				mSndSucc.Incr(1)
+				return
+			}
+			mSndErr.Incr(1)
+			if !throt.Retry() {
+				return
 			}
 		}
+	}
 
-		if len(resultMsgs) > 1 {
+	// sendMsgs sends a slice of messages and blocks until each has been
+	// successfully sent, returning the number of messages where the
+	// acknowledgement was skipped.
+	sendMsgs := func(msgs []types.Message) int64 {
+		var skipAcks int64
+		if len(msgs) > 1 {
 			wg := sync.WaitGroup{}
-			wg.Add(len(resultMsgs))
+			wg.Add(len(msgs))
 
-			for _, msg := range resultMsgs {
+			for _, msg := range msgs {
 				go func(m types.Message) {
 					defer wg.Done()
-					sendMsg(m)
+					sendMsg(m, &skipAcks)
 				}(msg)
 			}
 
 			wg.Wait()
 		} else {
-			sendMsg(resultMsgs[0])
+			sendMsg(msgs[0], &skipAcks)
 		}
 		throt.Reset()
+		return skipAcks
+	}
+
+	// Messages buffered by processors are given a deferred response, which is
+	// resolved once the buffer is sent. Processors that buffer messages are
+	// flushed each flush period in order to emit messages that expire without
+	// a new message arriving, and the period is restarted when a new buffer
+	// begins so that it is flushed as soon as it expires.
+	var pending *types.DeferredResponse
+	var flushTimer *time.Timer
+	var flushChan <-chan time.Time
+	flushPeriod := p.flushPeriod()
+	if flushPeriod > 0 {
+		flushTimer = time.NewTimer(flushPeriod)
+		defer flushTimer.Stop()
+		flushChan = flushTimer.C
+	}
+
+	resolvePending := func() {
+		if pending != nil {
+			pending.Resolve()
+			pending = nil
+		}
+	}
+
+	var open bool
+	for atomic.LoadInt32(&p.running) == 1 {
+		var tran types.Transaction
+		select {
+		case tran, open = <-p.messagesIn:
+			if !open {
+				return
+			}
+		case <-flushChan:
+			flushed := false
+			for i, proc := range p.msgProcessors {
+				f, ok := proc.(processor.Flusher)
+				if !ok {
+					continue
+				}
+				flushedMsgs := f.Flush()
+				if len(flushedMsgs) == 0 {
+					continue
+				}
+				flushed = true
+				mProcFlushed.Incr(1)
+				if resultMsgs, _ := p.process(i+1, flushedMsgs); len(resultMsgs) > 0 {
+					sendMsgs(resultMsgs)
+				}
+			}
+			if flushed {
+				resolvePending()
+			}
+			flushTimer.Reset(flushPeriod)
+			continue
+		case <-p.closeChan:
+			return
+		}
+		mProcCount.Incr(1)
+
+		resultMsgs, resultRes := p.process(0, []types.Message{tran.Payload})
+		if len(resultMsgs) == 0 {
+			mProcDropped.Incr(1)
+			if resultRes != nil && resultRes.Error() == nil && resultRes.SkipAck() {
+				// The message has been buffered by a processor.
+				if pending == nil {
+					pending = types.NewDeferredResponse()
+					if flushTimer != nil {
+						if !flushTimer.Stop() {
+							<-flushTimer.C
+						}
+						flushTimer.Reset(flushPeriod)
+					}
+				}
+				resultRes = pending
+			}
+			select {
+			case tran.ResponseChan <- resultRes:
+			case <-p.closeChan:
+				return
+			}
+			continue
+		}
+
+		skipAcks := sendMsgs(resultMsgs)
+		if skipAcks < int64(len(resultMsgs)) {
+			resolvePending()
+		}
 
 		var res types.Response
 		if skipAcks == int64(len(resultMsgs)) {
@@ -221,13 +318,22 @@ func (p *Processor) CloseAsync() {
 	}
 }
 
-// WaitForClose blocks until the StackBuffer output has closed down.
+// WaitForClose blocks until the processor pipeline, including any processors
+// that hold resources, has closed down.
 func (p *Processor) WaitForClose(timeout time.Duration) error {
+	stopBy := time.Now().Add(timeout)
 	select {
 	case <-p.closed:
 	case <-time.After(timeout):
 		return types.ErrTimeout
 	}
+	for _, proc := range p.msgProcessors {
+		if c, ok := proc.(types.Closable); ok {
+			if err := c.WaitForClose(time.Until(stopBy)); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
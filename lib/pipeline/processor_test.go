@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
 )
@@ -443,3 +444,175 @@ func TestProcessorMultiMsgsOddSync(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestProcessorFlushExpired(t *testing.T) {
+	conf := processor.NewConfig()
+	conf.Type = "batch"
+	conf.Batch.ByteSize = 1000
+	conf.Batch.PeriodMS = 50
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	batchProc, err := processor.New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc := NewProcessor(testLog, metrics.DudType{}, batchProc)
+
+	tChan, resChan := make(chan types.Transaction), make(chan types.Response)
+	if err = proc.StartReceiving(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tChan <- types.NewTransaction(types.NewMessage([][]byte{[]byte("foo")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	// The message is buffered and should not be acknowledged until the batch
+	// is sent.
+	var deferred *types.DeferredResponse
+	select {
+	case res := <-resChan:
+		if !res.SkipAck() {
+			t.Error("Expected unacknowledged response")
+		}
+		var ok bool
+		if deferred, ok = res.(*types.DeferredResponse); !ok {
+			t.Fatalf("Expected deferred response: %T", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	// The batch should be emitted once it expires.
+	select {
+	case procT := <-proc.TransactionChan():
+		if exp, act := [][]byte{[]byte("foo")}, procT.Payload.GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong message received: %s != %s", act, exp)
+		}
+		select {
+		case <-deferred.Resolved():
+			t.Error("Deferred response resolved before batch was acknowledged")
+		default:
+		}
+		select {
+		case procT.ResponseChan <- types.NewSimpleResponse(nil):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	select {
+	case <-deferred.Resolved():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for deferred response")
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+type mockClosableProcessor struct {
+	closed chan struct{}
+}
+
+func (m *mockClosableProcessor) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	return []types.Message{msg}, nil
+}
+
+func (m *mockClosableProcessor) CloseAsync() {
+	close(m.closed)
+}
+
+func (m *mockClosableProcessor) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-m.closed:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+func TestProcessorClosesProcessors(t *testing.T) {
+	mockProc := &mockClosableProcessor{closed: make(chan struct{})}
+
+	proc := NewProcessor(
+		log.New(os.Stdout, log.Config{LogLevel: "NONE"}),
+		metrics.DudType{},
+		mockProc,
+	)
+
+	if err := proc.StartReceiving(make(chan types.Transaction)); err != nil {
+		t.Fatal(err)
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case <-mockProc.closed:
+	default:
+		t.Error("Expected processor to be closed")
+	}
+}
+
+type mockFlusher struct {
+	flushed chan struct{}
+}
+
+func (m *mockFlusher) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	return nil, types.NewSimpleResponse(nil)
+}
+
+func (m *mockFlusher) FlushPeriod() time.Duration {
+	return time.Millisecond * 10
+}
+
+func (m *mockFlusher) Flush() []types.Message {
+	select {
+	case <-m.flushed:
+		return nil
+	default:
+	}
+	close(m.flushed)
+	return []types.Message{types.NewMessage([][]byte{[]byte("flushed")})}
+}
+
+func TestProcessorFlushWithoutBuffering(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc := NewProcessor(testLog, metrics.DudType{}, &mockFlusher{
+		flushed: make(chan struct{}),
+	})
+
+	if err := proc.StartReceiving(make(chan types.Transaction)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Processors are flushed even when no messages have been buffered.
+	select {
+	case procT := <-proc.TransactionChan():
+		if exp, act := [][]byte{[]byte("flushed")}, procT.Payload.GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong message received: %s != %s", act, exp)
+		}
+		select {
+		case procT.ResponseChan <- types.NewSimpleResponse(nil):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
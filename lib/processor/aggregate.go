@@ -0,0 +1,631 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/text"
+	"github.com/Jeffail/gabs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["aggregate"] = TypeSpec{
+		constructor: NewAggregate,
+		description: `
+Aggregates message parts over windows of time, grouped by a key, and emits a
+JSON summary message for each group when a window closes. Parts passing through
+this processor are consumed and are not propagated.
+
+The key of a part is either the value found at the JSON dot path
+` + "`key_path`" + `, or the result of the string ` + "`key`" + `, which
+supports [function interpolations](../config_interpolation.md#functions)
+resolved against the part. When neither is set all parts are aggregated into a
+single group with an empty key, and when ` + "`key_path`" + ` is not found the
+part is grouped under an empty key.
+
+Windows are ` + "`window_ms`" + ` milliseconds long and are aligned to the
+processing time of Benthos. When ` + "`slide_ms`" + ` is zero the windows are
+tumbling, meaning each part belongs to exactly one window. Otherwise a new window
+is opened every ` + "`slide_ms`" + ` milliseconds, and a part belongs to each
+window open at the time it is processed.
+
+For each JSON dot path in ` + "`fields`" + ` the processor calculates the
+` + "`operations`" + ` listed, which can be any of ` + "`count`, `sum`, `min`, `max`, `avg`" + `
+and ` + "`distinct_count`" + `. The operations ` + "`sum`, `min`, `max`" + ` and
+` + "`avg`" + ` only consider numerical values. The operation
+` + "`distinct_count`" + ` tracks at most ` + "`max_distinct`" + ` values of a
+field within a group, beyond which new values are not counted. The summary of a
+group looks like this:
+
+` + "``` json" + `
+{
+	"key": "foo",
+	"window_start": "2018-06-21T10:00:00Z",
+	"window_end": "2018-06-21T10:01:00Z",
+	"count": 3,
+	"fields": {
+		"price": {
+			"count": 3,
+			"sum": 30,
+			"min": 5,
+			"max": 15,
+			"avg": 10,
+			"distinct_count": 2
+		}
+	}
+}
+` + "```" + `
+
+Parts that fail to parse as JSON when either ` + "`key_path`" + ` or
+` + "`fields`" + ` are set are dropped.
+
+### State
+
+By default the state of open windows is held in memory and is lost when Benthos
+is shut down. Setting ` + "`cache`" + ` to the name of a cache resource stores
+the state of open windows in that cache, which is loaded when the processor is
+created. Caches with a TTL should be configured to keep items for longer than
+` + "`window_ms`" + `.
+
+The state is stored when a window closes, when the processor is closed, and
+otherwise at most once every ` + "`cache_period_ms`" + ` milliseconds while it
+changes. Messages are acknowledged once they have been added to the state of
+their windows, and therefore the changes made within the last period can be
+lost if Benthos exits unexpectedly.
+
+The state is stored under the key ` + "`cache_key`" + `, which must be set when
+` + "`cache`" + ` is set and must be unique to the processor. This includes
+processors of other streams and of other Benthos instances that share the cache,
+since a processor loads whatever state is found under its key. Creating a
+processor with a key already used by another aggregate processor results in an
+error, and therefore a processor that stores state cannot be run on more than
+one pipeline thread.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// AggregateConfig contains configuration for the Aggregate processor.
+type AggregateConfig struct {
+	KeyPath       string   `json:"key_path" yaml:"key_path"`
+	Key           string   `json:"key" yaml:"key"`
+	Fields        []string `json:"fields" yaml:"fields"`
+	Operations    []string `json:"operations" yaml:"operations"`
+	WindowMS      int      `json:"window_ms" yaml:"window_ms"`
+	SlideMS       int      `json:"slide_ms" yaml:"slide_ms"`
+	Cache         string   `json:"cache" yaml:"cache"`
+	CacheKey      string   `json:"cache_key" yaml:"cache_key"`
+	CachePeriodMS int      `json:"cache_period_ms" yaml:"cache_period_ms"`
+	MaxDistinct   int      `json:"max_distinct" yaml:"max_distinct"`
+}
+
+// NewAggregateConfig returns a AggregateConfig with default values.
+func NewAggregateConfig() AggregateConfig {
+	return AggregateConfig{
+		KeyPath:       "",
+		Key:           "",
+		Fields:        []string{},
+		Operations:    []string{"count", "sum", "min", "max", "avg", "distinct_count"},
+		WindowMS:      60000,
+		SlideMS:       0,
+		Cache:         "",
+		CacheKey:      "",
+		CachePeriodMS: 1000,
+		MaxDistinct:   10000,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// aggregateCacheKeys is the set of cache keys currently used by Aggregate
+// processors to store state, which is used to reject a second processor using
+// the same key.
+var (
+	aggregateCacheKeys    = map[string]struct{}{}
+	aggregateCacheKeysMut sync.Mutex
+)
+
+// claimAggregateCacheKey claims a cache key, returning false if it is already
+// claimed.
+func claimAggregateCacheKey(id string) bool {
+	aggregateCacheKeysMut.Lock()
+	defer aggregateCacheKeysMut.Unlock()
+
+	if _, exists := aggregateCacheKeys[id]; exists {
+		return false
+	}
+	aggregateCacheKeys[id] = struct{}{}
+	return true
+}
+
+// releaseAggregateCacheKey releases a claimed cache key.
+func releaseAggregateCacheKey(id string) {
+	aggregateCacheKeysMut.Lock()
+	delete(aggregateCacheKeys, id)
+	aggregateCacheKeysMut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// aggregateField is the state of a single field of a group within a window.
+type aggregateField struct {
+	Count    int64           `json:"count"`
+	Numeric  int64           `json:"numeric"`
+	Sum      float64         `json:"sum"`
+	Min      float64         `json:"min"`
+	Max      float64         `json:"max"`
+	Distinct map[string]bool `json:"distinct,omitempty"`
+}
+
+// aggregateGroup is the state of a group within a window.
+type aggregateGroup struct {
+	Count  int64                      `json:"count"`
+	Fields map[string]*aggregateField `json:"fields"`
+}
+
+// aggregateWindow is the state of a single window, the start is a unix
+// timestamp in milliseconds.
+type aggregateWindow struct {
+	Start  int64                      `json:"start"`
+	Groups map[string]*aggregateGroup `json:"groups"`
+}
+
+// aggregateState contains all open windows ordered by their start time.
+type aggregateState struct {
+	Windows []*aggregateWindow `json:"windows"`
+}
+
+//------------------------------------------------------------------------------
+
+// Aggregate is a processor that aggregates message parts over windows of time
+// and emits a summary of each group of parts when a window closes.
+type Aggregate struct {
+	log   log.Modular
+	stats metrics.Type
+
+	keyPath     string
+	key         []byte
+	interpolate bool
+	fields      []string
+	ops         map[string]struct{}
+	window      int64
+	slide       int64
+	maxDistinct int
+
+	cache       types.Cache
+	cacheID     string
+	cacheKey    string
+	cachePeriod time.Duration
+
+	mut        sync.Mutex
+	state      aggregateState
+	dirty      bool
+	lastStored time.Time
+	closed     bool
+	now        func() time.Time
+
+	mCount     metrics.StatCounter
+	mErrJSON   metrics.StatCounter
+	mErrCache  metrics.StatCounter
+	mCapped    metrics.StatCounter
+	mDropped   metrics.StatCounter
+	mWindows   metrics.StatCounter
+	mSent      metrics.StatCounter
+	mSentParts metrics.StatCounter
+}
+
+// NewAggregate returns a Aggregate processor.
+func NewAggregate(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	if conf.Aggregate.WindowMS <= 0 {
+		return nil, errors.New("window_ms must be greater than zero")
+	}
+	if conf.Aggregate.SlideMS < 0 || conf.Aggregate.SlideMS > conf.Aggregate.WindowMS {
+		return nil, errors.New("slide_ms must be between zero and window_ms")
+	}
+
+	if conf.Aggregate.MaxDistinct <= 0 {
+		return nil, errors.New("max_distinct must be greater than zero")
+	}
+
+	ops := map[string]struct{}{}
+	for _, op := range conf.Aggregate.Operations {
+		switch op {
+		case "count", "sum", "min", "max", "avg", "distinct_count":
+			ops[op] = struct{}{}
+		default:
+			return nil, fmt.Errorf("operation not recognised: %v", op)
+		}
+	}
+
+	a := &Aggregate{
+		log:   log.NewModule(".processor.aggregate"),
+		stats: stats,
+
+		keyPath:     conf.Aggregate.KeyPath,
+		key:         []byte(conf.Aggregate.Key),
+		interpolate: text.ContainsFunctionVariables([]byte(conf.Aggregate.Key)),
+		fields:      conf.Aggregate.Fields,
+		ops:         ops,
+		window:      int64(conf.Aggregate.WindowMS),
+		slide:       int64(conf.Aggregate.SlideMS),
+		maxDistinct: conf.Aggregate.MaxDistinct,
+		cachePeriod: time.Duration(conf.Aggregate.CachePeriodMS) * time.Millisecond,
+
+		now: time.Now,
+
+		mCount:     stats.GetCounter("processor.aggregate.count"),
+		mErrJSON:   stats.GetCounter("processor.aggregate.error.json_parse"),
+		mErrCache:  stats.GetCounter("processor.aggregate.error.cache"),
+		mCapped:    stats.GetCounter("processor.aggregate.distinct.capped"),
+		mDropped:   stats.GetCounter("processor.aggregate.dropped"),
+		mWindows:   stats.GetCounter("processor.aggregate.windows"),
+		mSent:      stats.GetCounter("processor.aggregate.sent"),
+		mSentParts: stats.GetCounter("processor.aggregate.parts.sent"),
+	}
+	if a.slide == 0 {
+		a.slide = a.window
+	}
+
+	if len(conf.Aggregate.Cache) > 0 {
+		var err error
+		if a.cache, err = mgr.GetCache(conf.Aggregate.Cache); err != nil {
+			return nil, err
+		}
+		if len(conf.Aggregate.CacheKey) == 0 {
+			return nil, errors.New("a cache_key must be set when a cache is used")
+		}
+		a.cacheID = conf.Aggregate.Cache + "/" + conf.Aggregate.CacheKey
+		a.cacheKey = conf.Aggregate.CacheKey
+		if !claimAggregateCacheKey(a.cacheID) {
+			return nil, fmt.Errorf(
+				"cache_key '%v' of cache '%v' is already used by another aggregate processor",
+				a.cacheKey, conf.Aggregate.Cache,
+			)
+		}
+
+		stateBytes, err := a.cache.Get(a.cacheKey)
+		if err == nil {
+			err = json.Unmarshal(stateBytes, &a.state)
+			if err != nil {
+				err = fmt.Errorf("failed to parse stored state: %v", err)
+			}
+		} else if err == types.ErrKeyNotFound {
+			err = nil
+		} else {
+			err = fmt.Errorf("failed to read stored state: %v", err)
+		}
+		if err != nil {
+			releaseAggregateCacheKey(a.cacheID)
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+//------------------------------------------------------------------------------
+
+// jsonPath returns the value found at a JSON dot path of a parsed document, or
+// nil if the path does not exist.
+func jsonPath(jObj interface{}, path string) interface{} {
+	gObj, err := gabs.Consume(jObj)
+	if err != nil {
+		return nil
+	}
+	return gObj.Path(path).Data()
+}
+
+// getKey returns the group key of a message part.
+func (a *Aggregate) getKey(msg types.Message, index int, jObj interface{}) string {
+	if len(a.keyPath) > 0 {
+		switch t := jsonPath(jObj, a.keyPath).(type) {
+		case nil:
+			return ""
+		case string:
+			return t
+		default:
+			kBytes, _ := json.Marshal(t)
+			return string(kBytes)
+		}
+	}
+	if a.interpolate {
+		return string(text.ReplaceFunctionVariablesForPart(
+			msg, index, a.key,
+		))
+	}
+	return string(a.key)
+}
+
+// getWindow returns the window starting at a given time, creating it if it does
+// not yet exist. The mutex must be held by the caller.
+func (a *Aggregate) getWindow(start int64) *aggregateWindow {
+	i := sort.Search(len(a.state.Windows), func(i int) bool {
+		return a.state.Windows[i].Start >= start
+	})
+	if i < len(a.state.Windows) && a.state.Windows[i].Start == start {
+		return a.state.Windows[i]
+	}
+	w := &aggregateWindow{
+		Start:  start,
+		Groups: map[string]*aggregateGroup{},
+	}
+	a.state.Windows = append(a.state.Windows, nil)
+	copy(a.state.Windows[i+1:], a.state.Windows[i:])
+	a.state.Windows[i] = w
+	a.mWindows.Incr(1)
+	return w
+}
+
+// addPart adds a part to a group of a window. The mutex must be held by the
+// caller.
+func (a *Aggregate) addPart(w *aggregateWindow, key string, jObj interface{}) {
+	g, exists := w.Groups[key]
+	if !exists {
+		g = &aggregateGroup{
+			Fields: map[string]*aggregateField{},
+		}
+		w.Groups[key] = g
+	}
+	g.Count++
+
+	_, distinct := a.ops["distinct_count"]
+	for _, path := range a.fields {
+		v := jsonPath(jObj, path)
+		if v == nil {
+			continue
+		}
+		f, exists := g.Fields[path]
+		if !exists {
+			f = &aggregateField{}
+			g.Fields[path] = f
+		}
+		f.Count++
+		if n, isNum := v.(float64); isNum {
+			if f.Numeric == 0 || n < f.Min {
+				f.Min = n
+			}
+			if f.Numeric == 0 || n > f.Max {
+				f.Max = n
+			}
+			f.Sum += n
+			f.Numeric++
+		}
+		if distinct {
+			if f.Distinct == nil {
+				f.Distinct = map[string]bool{}
+			}
+			vBytes, _ := json.Marshal(v)
+			if len(f.Distinct) < a.maxDistinct {
+				f.Distinct[string(vBytes)] = true
+			} else if !f.Distinct[string(vBytes)] {
+				a.mCapped.Incr(1)
+			}
+		}
+	}
+}
+
+// summarise creates a summary message for each group of a window.
+func (a *Aggregate) summarise(w *aggregateWindow) []types.Message {
+	keys := make([]string, 0, len(w.Groups))
+	for k := range w.Groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	start := time.Unix(0, w.Start*int64(time.Millisecond)).UTC()
+	end := start.Add(time.Duration(a.window) * time.Millisecond)
+
+	msgs := make([]types.Message, 0, len(keys))
+	for _, k := range keys {
+		g := w.Groups[k]
+		fields := map[string]interface{}{}
+		for path, f := range g.Fields {
+			summary := map[string]interface{}{}
+			if _, exists := a.ops["count"]; exists {
+				summary["count"] = f.Count
+			}
+			if _, exists := a.ops["distinct_count"]; exists {
+				summary["distinct_count"] = len(f.Distinct)
+			}
+			if f.Numeric > 0 {
+				if _, exists := a.ops["sum"]; exists {
+					summary["sum"] = f.Sum
+				}
+				if _, exists := a.ops["min"]; exists {
+					summary["min"] = f.Min
+				}
+				if _, exists := a.ops["max"]; exists {
+					summary["max"] = f.Max
+				}
+				if _, exists := a.ops["avg"]; exists {
+					summary["avg"] = f.Sum / float64(f.Numeric)
+				}
+			}
+			fields[path] = summary
+		}
+		sBytes, err := json.Marshal(map[string]interface{}{
+			"key":          k,
+			"window_start": start.Format(time.RFC3339Nano),
+			"window_end":   end.Format(time.RFC3339Nano),
+			"count":        g.Count,
+			"fields":       fields,
+		})
+		if err != nil {
+			a.log.Errorf("Failed to create summary: %v\n", err)
+			continue
+		}
+		msgs = append(msgs, types.NewMessage([][]byte{sBytes}))
+	}
+	return msgs
+}
+
+// closeWindows removes all windows that have closed and returns their
+// summaries. The mutex must be held by the caller.
+func (a *Aggregate) closeWindows(now int64) []types.Message {
+	var msgs []types.Message
+	i := 0
+	for ; i < len(a.state.Windows); i++ {
+		w := a.state.Windows[i]
+		if w.Start+a.window > now {
+			break
+		}
+		msgs = append(msgs, a.summarise(w)...)
+	}
+	if i > 0 {
+		a.state.Windows = a.state.Windows[i:]
+		a.dirty = true
+	}
+	return msgs
+}
+
+// storeState writes the state of open windows to the cache, if configured and
+// the state has changed since it was last stored. Unless force is true the
+// state is only written when the cache period has passed since the last write.
+// The mutex must be held by the caller.
+func (a *Aggregate) storeState(force bool) {
+	if a.cache == nil || !a.dirty {
+		return
+	}
+	if !force && a.now().Sub(a.lastStored) < a.cachePeriod {
+		return
+	}
+	a.dirty = false
+	a.lastStored = a.now()
+	stateBytes, err := json.Marshal(a.state)
+	if err == nil {
+		err = a.cache.Set(a.cacheKey, stateBytes)
+	}
+	if err != nil {
+		a.mErrCache.Incr(1)
+		a.log.Errorf("Failed to store state: %v\n", err)
+	}
+}
+
+// nowMS returns the current time as a unix timestamp in milliseconds.
+func (a *Aggregate) nowMS() int64 {
+	return a.now().UnixNano() / int64(time.Millisecond)
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage adds each part of a message to the windows that are open at
+// the time of processing, and returns the summaries of any windows that have
+// since closed.
+func (a *Aggregate) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	a.mCount.Incr(1)
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	now := a.nowMS()
+	msgs := a.closeWindows(now)
+	closedWindows := len(msgs) > 0
+
+	parseJSON := len(a.keyPath) > 0 || len(a.fields) > 0
+	msg.Iter(func(i int, part []byte) error {
+		var jObj interface{}
+		if parseJSON {
+			var err error
+			if jObj, err = msg.GetJSON(i); err != nil {
+				a.mErrJSON.Incr(1)
+				a.log.Debugf("Failed to parse part as JSON: %v\n", err)
+				return nil
+			}
+		}
+		key := a.getKey(msg, i, jObj)
+		for start := now - now%a.slide; start > now-a.window; start -= a.slide {
+			a.addPart(a.getWindow(start), key, jObj)
+		}
+		a.dirty = true
+		return nil
+	})
+
+	a.storeState(closedWindows)
+
+	if len(msgs) == 0 {
+		a.mDropped.Incr(1)
+		return nil, types.NewSimpleResponse(nil)
+	}
+	a.mSent.Incr(1)
+	a.mSentParts.Incr(int64(len(msgs)))
+	return msgs, nil
+}
+
+// FlushPeriod returns the period at which windows are opened, or the period at
+// which state is stored if that is shorter.
+func (a *Aggregate) FlushPeriod() time.Duration {
+	period := time.Duration(a.slide) * time.Millisecond
+	if a.cache != nil && a.cachePeriod > 0 && a.cachePeriod < period {
+		period = a.cachePeriod
+	}
+	return period
+}
+
+// Flush returns the summaries of any windows that have closed, and stores the
+// state of open windows if it is due.
+func (a *Aggregate) Flush() []types.Message {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	msgs := a.closeWindows(a.nowMS())
+	if len(msgs) > 0 {
+		a.storeState(true)
+		a.mSent.Incr(1)
+		a.mSentParts.Incr(int64(len(msgs)))
+	} else {
+		a.storeState(false)
+	}
+	return msgs
+}
+
+// CloseAsync stores the state of open windows and releases the cache key of
+// the processor.
+func (a *Aggregate) CloseAsync() {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if a.closed || a.cache == nil {
+		return
+	}
+	a.closed = true
+	a.storeState(true)
+	releaseAggregateCacheKey(a.cacheID)
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (a *Aggregate) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,342 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func newTestAggregate(t *testing.T, conf Config, mgr types.Manager, now *time.Time) *Aggregate {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewAggregate(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := proc.(*Aggregate)
+	agg.now = func() time.Time {
+		return *now
+	}
+	return agg
+}
+
+func msgsToStrings(msgs []types.Message) []string {
+	var strs []string
+	for _, m := range msgs {
+		for _, p := range m.GetAll() {
+			strs = append(strs, string(p))
+		}
+	}
+	return strs
+}
+
+func TestAggregateBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Aggregate.WindowMS = 0
+	if _, err := NewAggregate(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from zero window")
+	}
+
+	conf = NewConfig()
+	conf.Aggregate.SlideMS = conf.Aggregate.WindowMS + 1
+	if _, err := NewAggregate(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from slide exceeding window")
+	}
+
+	conf = NewConfig()
+	conf.Aggregate.Operations = []string{"nope"}
+	if _, err := NewAggregate(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from unknown operation")
+	}
+
+	conf = NewConfig()
+	conf.Aggregate.MaxDistinct = 0
+	if _, err := NewAggregate(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from zero max distinct")
+	}
+}
+
+func TestAggregateTumbling(t *testing.T) {
+	conf := NewConfig()
+	conf.Aggregate.KeyPath = "user"
+	conf.Aggregate.Fields = []string{"price", "item"}
+	conf.Aggregate.WindowMS = 1000
+
+	now := time.Unix(100, 0)
+	agg := newTestAggregate(t, conf, nil, &now)
+
+	msgs, res := agg.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"user":"foo","price":5,"item":"a"}`),
+		[]byte(`{"user":"bar","price":1,"item":"a"}`),
+		[]byte(`not json`),
+	}))
+	if len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %s", msgsToStrings(msgs))
+	}
+	if res == nil || res.Error() != nil || res.SkipAck() {
+		t.Errorf("Expected acknowledged response: %v", res)
+	}
+
+	now = now.Add(time.Millisecond * 500)
+	msgs, _ = agg.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"user":"foo","price":15,"item":"b"}`),
+		[]byte(`{"user":"foo","price":10,"item":"b"}`),
+	}))
+	if len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %s", msgsToStrings(msgs))
+	}
+	if msgs = agg.Flush(); len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %s", msgsToStrings(msgs))
+	}
+
+	now = now.Add(time.Millisecond * 500)
+	exp := []string{
+		`{"count":1,"fields":{"item":{"count":1,"distinct_count":1},"price":{"avg":1,"count":1,"distinct_count":1,"max":1,"min":1,"sum":1}},"key":"bar","window_end":"1970-01-01T00:01:41Z","window_start":"1970-01-01T00:01:40Z"}`,
+		`{"count":3,"fields":{"item":{"count":3,"distinct_count":2},"price":{"avg":10,"count":3,"distinct_count":3,"max":15,"min":5,"sum":30}},"key":"foo","window_end":"1970-01-01T00:01:41Z","window_start":"1970-01-01T00:01:40Z"}`,
+	}
+	if act := msgsToStrings(agg.Flush()); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if msgs = agg.Flush(); len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %s", msgsToStrings(msgs))
+	}
+}
+
+func TestAggregateSliding(t *testing.T) {
+	conf := NewConfig()
+	conf.Aggregate.Key = "${!json_field:type}"
+	conf.Aggregate.Fields = []string{"value"}
+	conf.Aggregate.Operations = []string{"sum"}
+	conf.Aggregate.WindowMS = 100
+	conf.Aggregate.SlideMS = 50
+
+	now := time.Unix(0, int64(time.Millisecond)*1020)
+	agg := newTestAggregate(t, conf, nil, &now)
+
+	agg.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"type":"foo","value":1}`),
+	}))
+	// Closes the window starting at 950ms.
+	now = time.Unix(0, int64(time.Millisecond)*1070)
+	exp := []string{
+		`{"count":1,"fields":{"value":{"sum":1}},"key":"foo","window_end":"1970-01-01T00:00:01.05Z","window_start":"1970-01-01T00:00:00.95Z"}`,
+	}
+	msgs, _ := agg.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"type":"foo","value":2}`),
+	}))
+	if act := msgsToStrings(msgs); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	now = time.Unix(0, int64(time.Millisecond)*1090)
+	msgs, _ = agg.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"type":"bar","value":4}`),
+	}))
+	if len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %s", msgsToStrings(msgs))
+	}
+
+	// Closes the windows starting at 1000ms and 1050ms.
+	now = time.Unix(0, int64(time.Millisecond)*1200)
+	exp = []string{
+		`{"count":1,"fields":{"value":{"sum":4}},"key":"bar","window_end":"1970-01-01T00:00:01.1Z","window_start":"1970-01-01T00:00:01Z"}`,
+		`{"count":2,"fields":{"value":{"sum":3}},"key":"foo","window_end":"1970-01-01T00:00:01.1Z","window_start":"1970-01-01T00:00:01Z"}`,
+		`{"count":1,"fields":{"value":{"sum":4}},"key":"bar","window_end":"1970-01-01T00:00:01.15Z","window_start":"1970-01-01T00:00:01.05Z"}`,
+		`{"count":1,"fields":{"value":{"sum":2}},"key":"foo","window_end":"1970-01-01T00:00:01.15Z","window_start":"1970-01-01T00:00:01.05Z"}`,
+	}
+	if act := msgsToStrings(agg.Flush()); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestAggregateCacheState(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Aggregate.Cache = "foocache"
+	conf.Aggregate.CacheKey = "state"
+	conf.Aggregate.WindowMS = 1000
+
+	now := time.Unix(100, 0)
+	agg := newTestAggregate(t, conf, mgr, &now)
+	agg.ProcessMessage(types.NewMessage([][]byte{[]byte("foo"), []byte("bar")}))
+	agg.CloseAsync()
+
+	// A new processor should continue from the stored state.
+	agg = newTestAggregate(t, conf, mgr, &now)
+	agg.ProcessMessage(types.NewMessage([][]byte{[]byte("baz")}))
+
+	now = now.Add(time.Second)
+	exp := []string{
+		`{"count":3,"fields":{},"key":"","window_end":"1970-01-01T00:01:41Z","window_start":"1970-01-01T00:01:40Z"}`,
+	}
+	if act := msgsToStrings(agg.Flush()); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	agg.CloseAsync()
+
+	agg = newTestAggregate(t, conf, mgr, &now)
+	if msgs := agg.Flush(); len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %s", msgsToStrings(msgs))
+	}
+	agg.CloseAsync()
+}
+
+func TestAggregateCachePeriod(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Aggregate.Cache = "foocache"
+	conf.Aggregate.CacheKey = "period"
+	conf.Aggregate.WindowMS = 10000
+	conf.Aggregate.CachePeriodMS = 1000
+
+	now := time.Unix(100, 0)
+	agg := newTestAggregate(t, conf, mgr, &now)
+	defer agg.CloseAsync()
+
+	if exp, act := time.Second, agg.FlushPeriod(); exp != act {
+		t.Errorf("Wrong flush period: %v != %v", act, exp)
+	}
+
+	storedCount := func() int64 {
+		stateBytes, err := memCache.Get("period")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var state aggregateState
+		if err = json.Unmarshal(stateBytes, &state); err != nil {
+			t.Fatal(err)
+		}
+		return state.Windows[0].Groups[""].Count
+	}
+
+	// The first change is stored immediately.
+	agg.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if exp, act := int64(1), storedCount(); exp != act {
+		t.Errorf("Wrong stored count: %v != %v", act, exp)
+	}
+
+	// Changes within the period are not stored.
+	now = now.Add(time.Millisecond * 500)
+	agg.ProcessMessage(types.NewMessage([][]byte{[]byte("bar")}))
+	if exp, act := int64(1), storedCount(); exp != act {
+		t.Errorf("Wrong stored count: %v != %v", act, exp)
+	}
+
+	// Changes are stored by a flush once the period has passed.
+	now = now.Add(time.Millisecond * 500)
+	agg.Flush()
+	if exp, act := int64(2), storedCount(); exp != act {
+		t.Errorf("Wrong stored count: %v != %v", act, exp)
+	}
+}
+
+func TestAggregateCacheKeys(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Aggregate.Cache = "foocache"
+	if _, err = NewAggregate(conf, mgr, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing cache_key")
+	}
+
+	conf.Aggregate.CacheKey = "keys"
+	now := time.Unix(100, 0)
+	first := newTestAggregate(t, conf, mgr, &now)
+	first.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if _, err = memCache.Get("keys"); err != nil {
+		t.Errorf("Missing state: %v", err)
+	}
+
+	// A second processor may not use the same key.
+	if _, err = NewAggregate(conf, mgr, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from duplicate cache_key")
+	}
+
+	// A closed processor releases its key.
+	first.CloseAsync()
+	second := newTestAggregate(t, conf, mgr, &now)
+	second.CloseAsync()
+}
+
+func TestAggregateMaxDistinct(t *testing.T) {
+	conf := NewConfig()
+	conf.Aggregate.Fields = []string{"value"}
+	conf.Aggregate.Operations = []string{"distinct_count"}
+	conf.Aggregate.WindowMS = 1000
+	conf.Aggregate.MaxDistinct = 2
+
+	now := time.Unix(100, 0)
+	agg := newTestAggregate(t, conf, nil, &now)
+	agg.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"value":1}`),
+		[]byte(`{"value":2}`),
+		[]byte(`{"value":3}`),
+		[]byte(`{"value":1}`),
+	}))
+
+	now = now.Add(time.Second)
+	exp := []string{
+		`{"count":4,"fields":{"value":{"distinct_count":2}},"key":"","window_end":"1970-01-01T00:01:41Z","window_start":"1970-01-01T00:01:40Z"}`,
+	}
+	if act := msgsToStrings(agg.Flush()); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
@@ -22,7 +22,9 @@ package processor
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -40,13 +42,19 @@ func init() {
 		constructor: NewArchive,
 		description: `
 Archives all the parts of a message into a single part according to the selected
-archive type. Supported archive types are: tar, binary (I'll add more later).
+archive type. Supported archive types are: tar, zip, binary, lines and
+json_array.
 
-Some archive types (such as tar) treat each archive item (message part) as a
-file with a path. Since message parts only contain raw data a unique path must
+Some archive types (such as tar, zip) treat each archive item (message part) as
+a file with a path. Since message parts only contain raw data a unique path must
 be generated for each part. This can be done by using function interpolations on
-the 'path' field as described [here](../config_interpolation.md#functions). For
-types that aren't file based (such as binary) the file field is ignored.`,
+the 'path' field as described [here](../config_interpolation.md#functions).
+Interpolations are resolved against the part being archived, and so functions
+such as 'metadata' can be used to name each file. For types that aren't file
+based (such as binary) the file field is ignored.
+
+The 'lines' format joins the parts with a newline, and the 'json_array' format
+joins the parts into a JSON array, which requires each part to be valid JSON.`,
 	}
 }
 
@@ -70,15 +78,15 @@ func NewArchiveConfig() ArchiveConfig {
 
 type archiveFunc func(hFunc headerFunc, parts [][]byte) ([]byte, error)
 
-type headerFunc func(body []byte) os.FileInfo
+type headerFunc func(index int, body []byte) os.FileInfo
 
 func tarArchive(hFunc headerFunc, parts [][]byte) ([]byte, error) {
 	buf := &bytes.Buffer{}
 	tw := tar.NewWriter(buf)
 
 	// Iterate through the parts of the message.
-	for _, part := range parts {
-		hdr, err := tar.FileInfoHeader(hFunc(part), "")
+	for i, part := range parts {
+		hdr, err := tar.FileInfoHeader(hFunc(i, part), "")
 		if err != nil {
 			return nil, err
 		}
@@ -94,16 +102,61 @@ func tarArchive(hFunc headerFunc, parts [][]byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func zipArchive(hFunc headerFunc, parts [][]byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	// Iterate through the parts of the message.
+	for i, part := range parts {
+		h, err := zip.FileInfoHeader(hFunc(i, part))
+		if err != nil {
+			return nil, err
+		}
+		h.Method = zip.Deflate
+
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(part); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
 func binaryArchive(hFunc headerFunc, parts [][]byte) ([]byte, error) {
 	return types.NewMessage(parts).Bytes(), nil
 }
 
+func linesArchive(hFunc headerFunc, parts [][]byte) ([]byte, error) {
+	return bytes.Join(parts, []byte("\n")), nil
+}
+
+func jsonArrayArchive(hFunc headerFunc, parts [][]byte) ([]byte, error) {
+	array := make([]json.RawMessage, len(parts))
+	for i, part := range parts {
+		array[i] = json.RawMessage(part)
+	}
+	return json.Marshal(array)
+}
+
 func strToArchiver(str string) (archiveFunc, error) {
 	switch str {
 	case "tar":
 		return tarArchive, nil
+	case "zip":
+		return zipArchive, nil
 	case "binary":
 		return binaryArchive, nil
+	case "lines":
+		return linesArchive, nil
+	case "json_array":
+		return jsonArrayArchive, nil
 	}
 	return nil, fmt.Errorf("archive format not recognised: %v", str)
 }
@@ -184,10 +237,18 @@ func (f fakeInfo) Sys() interface{} {
 	return nil
 }
 
-func (d *Archive) createHeader(body []byte) os.FileInfo {
+func (d *Archive) createHeaderFunc(msg types.Message) headerFunc {
+	return func(index int, body []byte) os.FileInfo {
+		return d.createHeader(msg, index, body)
+	}
+}
+
+func (d *Archive) createHeader(msg types.Message, index int, body []byte) os.FileInfo {
 	path := d.conf.Path
 	if d.interpolatePath {
-		path = string(text.ReplaceFunctionVariables(d.pathBytes))
+		path = string(text.ReplaceFunctionVariablesForPart(
+			msg, index, d.pathBytes,
+		))
 	}
 	return fakeInfo{
 		name: path,
@@ -208,7 +269,7 @@ func (d *Archive) ProcessMessage(msg types.Message) ([]types.Message, types.Resp
 		return nil, types.NewSimpleResponse(nil)
 	}
 
-	newPart, err := d.archive(d.createHeader, msg.GetAll())
+	newPart, err := d.archive(d.createHeaderFunc(msg), msg.GetAll())
 	if err != nil {
 		d.log.Debugf("Failed to create archive: %v\n", err)
 		d.mErr.Incr(1)
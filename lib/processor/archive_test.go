@@ -22,6 +22,7 @@ package processor
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"io"
 	"os"
@@ -101,6 +102,139 @@ func TestArchiveTar(t *testing.T) {
 	}
 }
 
+func TestArchiveZip(t *testing.T) {
+	conf := NewConfig()
+	conf.Archive.Format = "zip"
+	conf.Archive.Path = "${!metadata:name}.txt"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	exp := [][]byte{
+		[]byte("hello world first part"),
+		[]byte("hello world second part"),
+		[]byte("third part"),
+	}
+	expNames := []string{"foo.txt", "bar.txt", "baz.txt"}
+
+	input := types.NewMessage(exp)
+	input.GetMetadata(0).Set("name", "foo")
+	input.GetMetadata(1).Set("name", "bar")
+	input.GetMetadata(2).Set("name", "baz")
+
+	proc, err := NewArchive(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(input)
+	if len(msgs) != 1 {
+		t.Fatal("Archive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+	if msgs[0].Len() != 1 {
+		t.Fatal("More parts than expected")
+	}
+
+	act := [][]byte{}
+	actNames := []string{}
+
+	archive := msgs[0].Get(0)
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		fr, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		newPartBuf := bytes.Buffer{}
+		if _, err = newPartBuf.ReadFrom(fr); err != nil {
+			t.Fatal(err)
+		}
+		fr.Close()
+
+		act = append(act, newPartBuf.Bytes())
+		actNames = append(actNames, f.Name)
+	}
+
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+	if !reflect.DeepEqual(expNames, actNames) {
+		t.Errorf("Unexpected names: %s != %s", actNames, expNames)
+	}
+}
+
+func TestArchiveLines(t *testing.T) {
+	conf := NewConfig()
+	conf.Archive.Format = "lines"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewArchive(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("hello world first part"),
+		[]byte("hello world second part"),
+		[]byte("third part"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Archive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	exp := [][]byte{
+		[]byte("hello world first part\nhello world second part\nthird part"),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+}
+
+func TestArchiveJSONArray(t *testing.T) {
+	conf := NewConfig()
+	conf.Archive.Format = "json_array"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewArchive(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"foo":"bar"}`),
+		[]byte(`5`),
+		[]byte(`"baz"`),
+		[]byte(`[ "qux" ]`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Archive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	exp := [][]byte{
+		[]byte(`[{"foo":"bar"},5,"baz",["qux"]]`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+
+	msgs, _ = proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"foo":"bar"}`),
+		[]byte(`not json`),
+	}))
+	if len(msgs) != 0 {
+		t.Error("Expected failed archive to be dropped")
+	}
+}
+
 func TestArchiveBinary(t *testing.T) {
 	conf := NewConfig()
 	conf.Archive.Format = "binary"
@@ -21,7 +21,11 @@
 package processor
 
 import (
+	"sync"
+	"time"
+
 	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor/condition"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
 )
@@ -33,11 +37,29 @@ func init() {
 		constructor: NewBatch,
 		description: `
 Reads a number of discrete messages, buffering (but not acknowledging) the
-message parts until the total size of the batch in bytes matches or exceeds the
-configured byte size. Once the limit is reached the parts are combined into a
-single batch of messages and sent through the pipeline. Once the combined batch
-has reached a destination the acknowledgment is sent out for all messages inside
-the batch, preserving at-least-once delivery guarantees.
+message parts until either:
+
+- The total size of the batch in bytes matches or exceeds ` + "`byte_size`" + `.
+- The number of parts in the batch reaches ` + "`count`" + `, if greater than zero.
+- A message added to the batch passes the ` + "`condition`" + `.
+- The oldest part of the batch has been buffered for ` + "`period_ms`" + `
+  milliseconds, if greater than zero.
+
+Once a trigger is reached the parts are combined into a single batch of messages
+and sent through the pipeline. Once the combined batch has reached a destination
+the acknowledgment is sent out for all messages inside the batch, preserving
+at-least-once delivery guarantees.
+
+The ` + "`condition`" + ` is checked against each message as it arrives, and
+when it passes the batch, including that message, is sent. By default the
+condition never passes.
+
+A batch that expires due to ` + "`period_ms`" + ` is sent without waiting for
+a new message to arrive, and once it reaches its destination the source of its
+messages is notified. Inputs only acknowledge messages between reads, and
+therefore inputs that block while waiting for data acknowledge an expired batch
+along with the messages that follow it. If Benthos is restarted before this
+happens then the messages of an expired batch might be sent again.
 
 When a batch is sent to an output the behaviour will differ depending on the
 protocol. If the output type supports multipart messages then the batch is sent
@@ -56,30 +78,50 @@ unexpected behaviour and message ordering.`,
 
 // BatchConfig contains configuration for the Batch processor.
 type BatchConfig struct {
-	ByteSize int `json:"byte_size" yaml:"byte_size"`
+	ByteSize  int              `json:"byte_size" yaml:"byte_size"`
+	Count     int              `json:"count" yaml:"count"`
+	Condition condition.Config `json:"condition" yaml:"condition"`
+	PeriodMS  int              `json:"period_ms" yaml:"period_ms"`
 }
 
 // NewBatchConfig returns a BatchConfig with default values.
 func NewBatchConfig() BatchConfig {
+	cond := condition.NewConfig()
+	cond.Type = "static"
+	cond.Static = false
 	return BatchConfig{
-		ByteSize: 10000,
+		ByteSize:  10000,
+		Count:     0,
+		Condition: cond,
+		PeriodMS:  0,
 	}
 }
 
 //------------------------------------------------------------------------------
 
-// Batch is a processor that combines messages into a batch until a size limit
-// is reached, at which point the batch is sent out.
+// Batch is a processor that combines messages into a batch until a size limit,
+// count limit, condition or period is reached, at which point the batch is sent
+// out.
 type Batch struct {
-	log       log.Modular
-	stats     metrics.Type
-	n         int
+	log    log.Modular
+	stats  metrics.Type
+	n      int
+	count  int
+	cond   condition.Type
+	period time.Duration
+
+	mut       sync.Mutex
 	sizeTally int
 	parts     [][]byte
+	meta      []types.Metadata
+	started   time.Time
 
 	mCount     metrics.StatCounter
 	mWarnParts metrics.StatCounter
 	mSent      metrics.StatCounter
+	mSentCount metrics.StatCounter
+	mSentCond  metrics.StatCounter
+	mSentTime  metrics.StatCounter
 	mDropped   metrics.StatCounter
 }
 
@@ -87,40 +129,90 @@ type Batch struct {
 func NewBatch(
 	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (Type, error) {
+	cond, err := condition.New(conf.Batch.Condition, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
 	return &Batch{
-		log:   log.NewModule(".processor.batch"),
-		stats: stats,
-		n:     conf.Batch.ByteSize,
+		log:    log.NewModule(".processor.batch"),
+		stats:  stats,
+		n:      conf.Batch.ByteSize,
+		count:  conf.Batch.Count,
+		cond:   cond,
+		period: time.Duration(conf.Batch.PeriodMS) * time.Millisecond,
 
 		mCount:     stats.GetCounter("processor.batch.count"),
 		mWarnParts: stats.GetCounter("processor.batch.warning.too_many_parts"),
 		mSent:      stats.GetCounter("processor.batch.sent"),
+		mSentCount: stats.GetCounter("processor.batch.sent.count"),
+		mSentCond:  stats.GetCounter("processor.batch.sent.condition"),
+		mSentTime:  stats.GetCounter("processor.batch.sent.period"),
 		mDropped:   stats.GetCounter("processor.batch.dropped"),
 	}, nil
 }
 
 //------------------------------------------------------------------------------
 
+// flush combines the buffered parts into a single message and resets the
+// buffer. The mutex must be held by the caller.
+func (c *Batch) flush() types.Message {
+	newMsg := types.NewMessage(c.parts)
+	for i, md := range c.meta {
+		newMsg.SetMetadata(md, i)
+	}
+	c.parts = nil
+	c.meta = nil
+	c.sizeTally = 0
+	c.started = time.Time{}
+
+	c.mSent.Incr(1)
+	return newMsg
+}
+
+// expired returns whether the oldest buffered part has exceeded the configured
+// period. The mutex must be held by the caller.
+func (c *Batch) expired() bool {
+	return c.period > 0 && len(c.parts) > 0 && time.Since(c.started) >= c.period
+}
+
 // ProcessMessage takes a single message and buffers it, drops it, returning a
-// NoAck response, until eventually it reaches a size limit, at which point it
-// batches those messages into one multiple part message which is sent on.
+// NoAck response, until eventually it reaches a size limit, count limit,
+// condition or period, at which point it batches those messages into one
+// multiple part message which is sent on.
 func (c *Batch) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
 	c.mCount.Incr(1)
 
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if len(c.parts) == 0 {
+		c.started = time.Now()
+	}
+
 	// Add new parts to the buffer.
-	for _, part := range msg.GetAll() {
+	for i, part := range msg.GetAll() {
 		c.sizeTally += len(part)
 		c.parts = append(c.parts, part)
+		c.meta = append(c.meta, msg.GetMetadata(i))
 	}
 
-	// If we have reached our target count of parts in the buffer.
-	if c.sizeTally >= c.n {
-		newMsg := types.NewMessage(c.parts)
-		c.parts = nil
-		c.sizeTally = 0
+	// If we have reached our target size of parts in the buffer.
+	send := c.sizeTally >= c.n
+	if !send && c.count > 0 && len(c.parts) >= c.count {
+		c.mSentCount.Incr(1)
+		send = true
+	}
+	if !send && c.cond.Check(msg) {
+		c.mSentCond.Incr(1)
+		send = true
+	}
+	if !send && c.expired() {
+		c.mSentTime.Incr(1)
+		send = true
+	}
 
-		c.mSent.Incr(1)
-		msgs := [1]types.Message{newMsg}
+	if send {
+		msgs := [1]types.Message{c.flush()}
 		return msgs[:], nil
 	}
 
@@ -128,4 +220,22 @@ func (c *Batch) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 	return nil, types.NewUnacknowledgedResponse()
 }
 
+// FlushPeriod returns the configured period after which a batch is sent.
+func (c *Batch) FlushPeriod() time.Duration {
+	return c.period
+}
+
+// Flush returns the current batch if it has been buffered for longer than the
+// configured period.
+func (c *Batch) Flush() []types.Message {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if !c.expired() {
+		return nil
+	}
+	c.mSentTime.Incr(1)
+	return []types.Message{c.flush()}
+}
+
 //------------------------------------------------------------------------------
@@ -24,6 +24,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
@@ -196,3 +197,141 @@ func TestBatchTwoDiffParts(t *testing.T) {
 		t.Error("Expected nil res")
 	}
 }
+
+func TestBatchCount(t *testing.T) {
+	conf := NewConfig()
+	conf.Batch.ByteSize = 1000
+	conf.Batch.Count = 3
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewBatch(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}
+
+	for i, part := range exp[:2] {
+		msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{part}))
+		if len(msgs) != 0 {
+			t.Errorf("Expected no batch at index %v", i)
+		}
+		if res == nil || !res.SkipAck() {
+			t.Errorf("Expected unacknowledged response at index %v", i)
+		}
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{exp[2]}))
+	if len(msgs) != 1 {
+		t.Fatal("Expected success")
+	}
+	if !reflect.DeepEqual(exp, msgs[0].GetAll()) {
+		t.Errorf("Wrong result: %s != %s", msgs[0].GetAll(), exp)
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+}
+
+func TestBatchCondition(t *testing.T) {
+	conf := NewConfig()
+	conf.Batch.ByteSize = 1000
+	conf.Batch.Condition.Type = "content"
+	conf.Batch.Condition.Content.Operator = "equals_cs"
+	conf.Batch.Condition.Content.Arg = "end"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewBatch(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]byte{[]byte("foo"), []byte("bar"), []byte("end")}
+
+	for i, part := range exp[:2] {
+		msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{part}))
+		if len(msgs) != 0 {
+			t.Errorf("Expected no batch at index %v", i)
+		}
+		if res == nil || !res.SkipAck() {
+			t.Errorf("Expected unacknowledged response at index %v", i)
+		}
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{exp[2]}))
+	if len(msgs) != 1 {
+		t.Fatal("Expected success")
+	}
+	if !reflect.DeepEqual(exp, msgs[0].GetAll()) {
+		t.Errorf("Wrong result: %s != %s", msgs[0].GetAll(), exp)
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+}
+
+func TestBatchPeriod(t *testing.T) {
+	conf := NewConfig()
+	conf.Batch.ByteSize = 1000
+	conf.Batch.PeriodMS = 10
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewBatch(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flusher, ok := proc.(Flusher)
+	if !ok {
+		t.Fatal("Batch does not implement Flusher")
+	}
+	if exp, act := time.Millisecond*10, flusher.FlushPeriod(); exp != act {
+		t.Errorf("Wrong flush period: %v != %v", act, exp)
+	}
+	if msgs := flusher.Flush(); len(msgs) != 0 {
+		t.Errorf("Expected no flushed messages: %v", len(msgs))
+	}
+
+	exp := [][]byte{[]byte("foo"), []byte("bar")}
+
+	for i, part := range exp {
+		msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{part}))
+		if len(msgs) != 0 {
+			t.Errorf("Expected no batch at index %v", i)
+		}
+		if res == nil || !res.SkipAck() {
+			t.Errorf("Expected unacknowledged response at index %v", i)
+		}
+	}
+	if msgs := flusher.Flush(); len(msgs) != 0 {
+		t.Errorf("Expected no flushed messages: %v", len(msgs))
+	}
+
+	<-time.After(time.Millisecond * 20)
+
+	msgs := flusher.Flush()
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of flushed messages: %v", len(msgs))
+	}
+	if !reflect.DeepEqual(exp, msgs[0].GetAll()) {
+		t.Errorf("Wrong result: %s != %s", msgs[0].GetAll(), exp)
+	}
+	if msgs = flusher.Flush(); len(msgs) != 0 {
+		t.Errorf("Expected no flushed messages: %v", len(msgs))
+	}
+
+	// An expired batch is also sent when a new message arrives.
+	proc.ProcessMessage(types.NewMessage([][]byte{[]byte("baz")}))
+	<-time.After(time.Millisecond * 20)
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("qux")}))
+	if len(msgs) != 1 {
+		t.Fatal("Expected success")
+	}
+	if exp, act := [][]byte{[]byte("baz"), []byte("qux")}, msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+}
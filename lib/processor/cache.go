@@ -0,0 +1,337 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/text"
+	"github.com/Jeffail/gabs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["cache"] = TypeSpec{
+		constructor: NewCache,
+		description: `
+Performs operations against a [cache resource](../caches) for each message
+part, allowing you to store or retrieve data within message payloads.
+
+The ` + "`key`" + ` and ` + "`value`" + ` fields support
+[function interpolations](../config_interpolation.md#functions), which are
+resolved against each part individually.
+
+### Operators
+
+#### ` + "`set`" + `
+
+Sets the ` + "`key`" + ` to the ` + "`value`" + `, replacing any existing
+value.
+
+#### ` + "`add`" + `
+
+Sets the ` + "`key`" + ` to the ` + "`value`" + ` only if the key does not
+already exist. If the key already exists the part is flagged as having failed.
+
+#### ` + "`get`" + `
+
+Retrieves the value of the ` + "`key`" + `. If ` + "`result_path`" + ` is
+empty the value replaces the contents of the part, otherwise the value is set at
+that JSON dot path within the part, which must be JSON. Values that are valid
+JSON are set as JSON, otherwise they are set as a string.
+
+When the key does not exist the behaviour depends on ` + "`on_miss`" + `,
+which can be ` + "`fail`" + ` (flag the part as having failed),
+` + "`ignore`" + ` (leave the part unchanged) or ` + "`drop`" + ` (remove the
+part from the message).
+
+#### ` + "`delete`" + `
+
+Removes the ` + "`key`" + `.
+
+### Joining Streams
+
+For example, a stream of user records can be stored with:
+
+` + "``` yaml" + `
+cache:
+  cache: users
+  operator: set
+  key: ${!json_field:user.id}
+  value: ${!content}
+` + "```" + `
+
+And events from another stream can then be enriched with the user records:
+
+` + "``` yaml" + `
+cache:
+  cache: users
+  operator: get
+  key: ${!json_field:user_id}
+  result_path: user
+  on_miss: ignore
+` + "```" + `
+
+The field ` + "`parts`" + ` selects the indexes of parts that are processed,
+where an empty array selects all parts. If a cache operation fails the part is
+left unchanged and is flagged as having failed.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CacheConfig contains configuration for the Cache processor.
+type CacheConfig struct {
+	Cache      string `json:"cache" yaml:"cache"`
+	Operator   string `json:"operator" yaml:"operator"`
+	Key        string `json:"key" yaml:"key"`
+	Value      string `json:"value" yaml:"value"`
+	ResultPath string `json:"result_path" yaml:"result_path"`
+	OnMiss     string `json:"on_miss" yaml:"on_miss"`
+	Parts      []int  `json:"parts" yaml:"parts"`
+}
+
+// NewCacheConfig returns a CacheConfig with default values.
+func NewCacheConfig() CacheConfig {
+	return CacheConfig{
+		Cache:      "",
+		Operator:   "set",
+		Key:        "",
+		Value:      "${!content}",
+		ResultPath: "",
+		OnMiss:     "fail",
+		Parts:      []int{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Cache is a processor that performs operations against a cache resource for
+// each message part.
+type Cache struct {
+	log   log.Modular
+	stats metrics.Type
+
+	cache       types.Cache
+	operator    cacheOperator
+	key         []byte
+	interpKey   bool
+	value       []byte
+	interpValue bool
+	resultPath  []string
+	onMiss      string
+	parts       []int
+
+	mCount    metrics.StatCounter
+	mErr      metrics.StatCounter
+	mErrCache metrics.StatCounter
+	mErrJSON  metrics.StatCounter
+	mMiss     metrics.StatCounter
+	mSucc     metrics.StatCounter
+	mDropped  metrics.StatCounter
+	mSent     metrics.StatCounter
+}
+
+// cacheOperator performs a cache operation for a single part message, which is
+// modified in place.
+type cacheOperator func(c *Cache, part types.Message, key string) error
+
+func cacheSet(c *Cache, part types.Message, key string) error {
+	return c.cacheErr(c.cache.Set(key, c.getValue(part)))
+}
+
+func cacheAdd(c *Cache, part types.Message, key string) error {
+	return c.cacheErr(c.cache.Add(key, c.getValue(part)))
+}
+
+func cacheDelete(c *Cache, part types.Message, key string) error {
+	return c.cacheErr(c.cache.Delete(key))
+}
+
+func cacheGet(c *Cache, part types.Message, key string) error {
+	value, err := c.cache.Get(key)
+	if err != nil {
+		return c.cacheErr(err)
+	}
+	if c.resultPath == nil {
+		part.Set(0, value)
+		return nil
+	}
+
+	var valueObj interface{}
+	if err = json.Unmarshal(value, &valueObj); err != nil {
+		valueObj = string(value)
+	}
+	jObj, err := part.GetJSON(0)
+	if err != nil {
+		c.mErrJSON.Incr(1)
+		return fmt.Errorf("failed to parse part as JSON: %v", err)
+	}
+	gObj, _ := gabs.Consume(jObj)
+	if _, err = gObj.Set(valueObj, c.resultPath...); err != nil {
+		c.mErrJSON.Incr(1)
+		return err
+	}
+	return part.SetJSON(0, gObj.Data())
+}
+
+// NewCache returns a Cache processor.
+func NewCache(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	cache, err := mgr.GetCache(conf.Cache.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	var operator cacheOperator
+	switch conf.Cache.Operator {
+	case "set":
+		operator = cacheSet
+	case "add":
+		operator = cacheAdd
+	case "get":
+		operator = cacheGet
+	case "delete":
+		operator = cacheDelete
+	default:
+		return nil, fmt.Errorf("operator not recognised: %v", conf.Cache.Operator)
+	}
+
+	switch conf.Cache.OnMiss {
+	case "fail", "ignore", "drop":
+	default:
+		return nil, fmt.Errorf("on_miss behaviour not recognised: %v", conf.Cache.OnMiss)
+	}
+
+	c := &Cache{
+		log:   log.NewModule(".processor.cache"),
+		stats: stats,
+
+		cache:    cache,
+		operator: operator,
+		key:      []byte(conf.Cache.Key),
+		value:    []byte(conf.Cache.Value),
+		onMiss:   conf.Cache.OnMiss,
+		parts:    conf.Cache.Parts,
+
+		mCount:    stats.GetCounter("processor.cache.count"),
+		mErr:      stats.GetCounter("processor.cache.error"),
+		mErrCache: stats.GetCounter("processor.cache.error.cache"),
+		mErrJSON:  stats.GetCounter("processor.cache.error.json"),
+		mMiss:     stats.GetCounter("processor.cache.miss"),
+		mSucc:     stats.GetCounter("processor.cache.success"),
+		mDropped:  stats.GetCounter("processor.cache.dropped"),
+		mSent:     stats.GetCounter("processor.cache.sent"),
+	}
+	c.interpKey = text.ContainsFunctionVariables(c.key)
+	c.interpValue = text.ContainsFunctionVariables(c.value)
+	if len(conf.Cache.ResultPath) > 0 && conf.Cache.ResultPath != "." {
+		c.resultPath = strings.Split(conf.Cache.ResultPath, ".")
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// cacheErr records errors returned by the cache that are not caused by the
+// existence or absence of a key.
+func (c *Cache) cacheErr(err error) error {
+	if err != nil && err != types.ErrKeyNotFound && err != types.ErrKeyAlreadyExists {
+		c.mErrCache.Incr(1)
+	}
+	return err
+}
+
+func (c *Cache) getKey(part types.Message) string {
+	if c.interpKey {
+		return string(text.ReplaceFunctionVariablesFor(part, c.key))
+	}
+	return string(c.key)
+}
+
+func (c *Cache) getValue(part types.Message) []byte {
+	if c.interpValue {
+		return text.ReplaceFunctionVariablesFor(part, c.value)
+	}
+	return c.value
+}
+
+// ProcessMessage performs the cache operation for each targeted part of a
+// message.
+func (c *Cache) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	c.mCount.Incr(1)
+
+	targets := map[int]struct{}{}
+	for _, index := range c.parts {
+		if index < 0 {
+			index = msg.Len() + index
+		}
+		targets[index] = struct{}{}
+	}
+
+	newMsg := types.NewMessage(nil)
+	for i := 0; i < msg.Len(); i++ {
+		part := isolatePart(msg, i)
+		if _, exists := targets[i]; len(targets) > 0 && !exists {
+			appendParts(newMsg, []types.Message{part})
+			continue
+		}
+
+		err := c.operator(c, part, c.getKey(part))
+		if err == types.ErrKeyNotFound {
+			c.mMiss.Incr(1)
+			switch c.onMiss {
+			case "ignore":
+				err = nil
+			case "drop":
+				continue
+			}
+		}
+
+		if err != nil {
+			c.mErr.Incr(1)
+			c.log.Debugf("Failed to perform cache operation: %v\n", err)
+			part = isolatePart(msg, i)
+			FlagErr(part, 0, err)
+		} else {
+			c.mSucc.Incr(1)
+		}
+		appendParts(newMsg, []types.Message{part})
+	}
+
+	if newMsg.Len() == 0 {
+		c.mDropped.Incr(1)
+		return nil, types.NewSimpleResponse(nil)
+	}
+
+	c.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
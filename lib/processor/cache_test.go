@@ -0,0 +1,247 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/cache"
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func newTestCacheMgr(t *testing.T) *fakeMgr {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+}
+
+func TestCacheBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	mgr := newTestCacheMgr(t)
+
+	conf := NewConfig()
+	conf.Cache.Cache = "nope"
+	if _, err := NewCache(conf, mgr, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+
+	conf = NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.Operator = "nope"
+	if _, err := NewCache(conf, mgr, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad operator")
+	}
+
+	conf = NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.OnMiss = "nope"
+	if _, err := NewCache(conf, mgr, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad on_miss")
+	}
+}
+
+func TestCacheSetGet(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	mgr := newTestCacheMgr(t)
+
+	conf := NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.Operator = "set"
+	conf.Cache.Key = "${!json_field:id}"
+
+	setProc, err := NewCache(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := [][]byte{
+		[]byte(`{"id":"1","name":"foo"}`),
+		[]byte(`{"id":"2","name":"bar"}`),
+	}
+	msgs, res := setProc.ProcessMessage(types.NewMessage(input))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(input, act) {
+		t.Errorf("Wrong result: %s != %s", act, input)
+	}
+
+	conf.Cache.Operator = "get"
+	conf.Cache.Key = "${!content}"
+	getProc, err := NewCache(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ = getProc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("2"), []byte("3"), []byte("1"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{
+		[]byte(`{"id":"2","name":"bar"}`),
+		[]byte("3"),
+		[]byte(`{"id":"1","name":"foo"}`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []bool{false, true, false} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestCacheGetResultPath(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	mgr := newTestCacheMgr(t)
+	mgr.caches["foocache"].Set("1", []byte(`{"name":"foo"}`))
+	mgr.caches["foocache"].Set("2", []byte(`not json`))
+
+	conf := NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.Operator = "get"
+	conf.Cache.Key = "${!json_field:user_id}"
+	conf.Cache.ResultPath = "user"
+	conf.Cache.OnMiss = "ignore"
+
+	proc, err := NewCache(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"user_id":"1"}`),
+		[]byte(`{"user_id":"2"}`),
+		[]byte(`{"user_id":"3"}`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{
+		[]byte(`{"user":{"name":"foo"},"user_id":"1"}`),
+		[]byte(`{"user":"not json","user_id":"2"}`),
+		[]byte(`{"user_id":"3"}`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i := range exp {
+		if HasFailed(msgs[0], i) {
+			t.Errorf("Unexpected fail flag at index %v", i)
+		}
+	}
+}
+
+func TestCacheGetDrop(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	mgr := newTestCacheMgr(t)
+	mgr.caches["foocache"].Set("foo", []byte("bar"))
+
+	conf := NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.Operator = "get"
+	conf.Cache.Key = "${!content}"
+	conf.Cache.OnMiss = "drop"
+
+	proc, err := NewCache(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("nope"), []byte("foo"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("bar")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("nope")}))
+	if len(msgs) != 0 {
+		t.Errorf("Expected message to be dropped: %s", msgs[0].GetAll())
+	}
+	if res == nil || res.Error() != nil {
+		t.Errorf("Expected nil error response: %v", res)
+	}
+}
+
+func TestCacheAddDelete(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	mgr := newTestCacheMgr(t)
+
+	conf := NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.Operator = "add"
+	conf.Cache.Key = "${!content}"
+	conf.Cache.Value = "value of ${!content}"
+
+	addProc, err := NewCache(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := addProc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("foo"), []byte("foo"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	for i, exp := range []bool{false, true} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+	if v, err := mgr.caches["foocache"].Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp, act := "value of foo", string(v); exp != act {
+		t.Errorf("Wrong cached value: %v != %v", act, exp)
+	}
+
+	conf.Cache.Operator = "delete"
+	delProc, err := NewCache(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delProc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if _, err := mgr.caches["foocache"].Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected key to be deleted: %v", err)
+	}
+}
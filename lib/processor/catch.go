@@ -0,0 +1,148 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["catch"] = TypeSpec{
+		constructor: NewCatch,
+		description: `
+Applies a list of child processors _only_ to message parts that have been
+flagged as having failed a processing step, parts that have not failed pass
+through unchanged. This can be used to handle, reshape or annotate bad records,
+for example the following config appends the reason for the failure to each
+failed part as a new part:
+
+` + "``` yaml" + `
+catch:
+- type: insert_part
+  insert_part:
+    index: -1
+    content: "${!metadata:benthos_processing_failed}"
+` + "```" + `
+
+Each failed part is processed individually as a single part message, and the
+resulting parts are placed back into the message in the position of the
+original part. Once the child processors have been applied the failure flags of
+the resulting parts are cleared, even if a child processor failed again.
+
+For more information about error handling read [this document](../error_handling.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Catch is a processor that applies a list of child processors to each message
+// part that has failed a processing step.
+type Catch struct {
+	children []Type
+
+	mCount   metrics.StatCounter
+	mCaught  metrics.StatCounter
+	mSent    metrics.StatCounter
+	mDropped metrics.StatCounter
+}
+
+// NewCatch returns a Catch processor.
+func NewCatch(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	var children []Type
+	for _, pconf := range conf.Catch {
+		proc, err := New(pconf, mgr, log, stats)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, proc)
+	}
+	return &Catch{
+		children: children,
+
+		mCount:   stats.GetCounter("processor.catch.count"),
+		mCaught:  stats.GetCounter("processor.catch.caught"),
+		mSent:    stats.GetCounter("processor.catch.sent"),
+		mDropped: stats.GetCounter("processor.catch.dropped"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the child processors to each failed part of a message
+// and returns the result.
+func (p *Catch) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	p.mCount.Incr(1)
+
+	newMsg := types.NewMessage(nil)
+	var res types.Response
+
+	msg.Iter(func(i int, part []byte) error {
+		if !HasFailed(msg, i) {
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(part))
+			return nil
+		}
+		p.mCaught.Incr(1)
+
+		var resultMsgs []types.Message
+		resultMsgs, res = executeAll(p.children, isolatePart(msg, i))
+
+		lParts := newMsg.Len()
+		appendParts(newMsg, resultMsgs)
+		for j := lParts; j < newMsg.Len(); j++ {
+			ClearFail(newMsg, j)
+		}
+		return nil
+	})
+
+	if newMsg.Len() == 0 {
+		p.mDropped.Incr(1)
+		if res == nil {
+			res = types.NewSimpleResponse(nil)
+		}
+		return nil, res
+	}
+
+	p.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down any child processors that hold resources.
+func (p *Catch) CloseAsync() {
+	closeAll(p.children)
+}
+
+// WaitForClose blocks until the child processors have closed down.
+func (p *Catch) WaitForClose(timeout time.Duration) error {
+	return waitForCloseAll(p.children, timeout)
+}
+
+//------------------------------------------------------------------------------
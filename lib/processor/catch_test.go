@@ -0,0 +1,170 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestCatchFailedParts(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "catch"
+
+	procConf := NewConfig()
+	procConf.Type = "insert_part"
+	procConf.InsertPart.Content = "${!metadata:benthos_processing_failed}"
+	procConf.InsertPart.Index = 0
+
+	conf.Catch = append(conf.Catch, procConf)
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := types.NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+		[]byte("baz"),
+	})
+	FlagErr(msgIn, 1, errors.New("nope"))
+
+	exp := [][]byte{
+		[]byte("foo"),
+		[]byte("nope"),
+		[]byte("bar"),
+		[]byte("baz"),
+	}
+
+	msgs, res := c.ProcessMessage(msgIn)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i := 0; i < msgs[0].Len(); i++ {
+		if HasFailed(msgs[0], i) {
+			t.Errorf("Part %v still flagged as failed", i)
+		}
+	}
+	if !HasFailed(msgIn, 1) {
+		t.Error("Original message was modified")
+	}
+}
+
+func TestCatchNoFailedParts(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "catch"
+
+	procConf := NewConfig()
+	procConf.Type = "insert_part"
+	procConf.InsertPart.Content = "foo"
+
+	conf.Catch = append(conf.Catch, procConf)
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]byte{
+		[]byte("bar"),
+		[]byte("baz"),
+	}
+
+	msgs, res := c.ProcessMessage(types.NewMessage(exp))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestCatchDropped(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "catch"
+
+	procConf := NewConfig()
+	procConf.Type = "filter"
+	procConf.Filter.Type = "static"
+	procConf.Filter.Static = false
+
+	conf.Catch = append(conf.Catch, procConf)
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := types.NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	})
+	FlagFail(msgIn, 0)
+
+	exp := [][]byte{
+		[]byte("bar"),
+	}
+
+	msgs, res := c.ProcessMessage(msgIn)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	FlagFail(msgIn, 1)
+	msgs, res = c.ProcessMessage(msgIn)
+	if len(msgs) != 0 {
+		t.Errorf("Expected message to be dropped: %v", len(msgs))
+	}
+	if res == nil {
+		t.Error("Expected non-nil response")
+	}
+}
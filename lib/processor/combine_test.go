@@ -236,3 +236,32 @@ func TestCombineTwoDiffParts(t *testing.T) {
 		t.Error("Expected nil res")
 	}
 }
+
+func TestCombineMetadata(t *testing.T) {
+	conf := NewConfig()
+	conf.Combine.Parts = 2
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewCombine(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{[]byte("foo")})
+	msg.GetMetadata(0).Set("foo", "1")
+	if msgs, _ := proc.ProcessMessage(msg); len(msgs) != 0 {
+		t.Fatal("Expected no messages")
+	}
+
+	msg = types.NewMessage([][]byte{[]byte("bar")})
+	msg.GetMetadata(0).Set("foo", "2")
+	msgs, res := proc.ProcessMessage(msg)
+	if len(msgs) != 1 {
+		t.Fatalf("Combine failed: %v", res)
+	}
+	for i, exp := range []string{"1", "2"} {
+		if act := msgs[0].GetMetadata(i).Get("foo"); exp != act {
+			t.Errorf("Wrong metadata: %v != %v", act, exp)
+		}
+	}
+}
@@ -22,12 +22,17 @@ package processor
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"fmt"
 
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
 )
 
 //------------------------------------------------------------------------------
@@ -37,15 +42,30 @@ func init() {
 		constructor: NewCompress,
 		description: `
 Compresses parts of a message according to the selected algorithm. Supported
-compression types are: gzip (I'll add more later). If the list of target parts
-is empty the compression will be applied to all message parts.
+compression types are: gzip, zlib, flate, snappy, lz4 and zstd. If the list of
+target parts is empty the compression will be applied to all message parts.
 
-The 'level' field might not apply to all algorithms.
+The meaning of the 'level' field depends on the algorithm:
+
+- gzip, zlib and flate: A level from 1 (best speed) to 9 (best compression),
+  where 0 disables compression and -1 selects the default.
+- zstd: A zstd level from 1 (fastest) to 22 (best compression), which is mapped
+  to the nearest level supported by the encoder. Levels less than 1 select the
+  default.
+- lz4: A level greater than 0 enables high compression mode.
+- snappy: The level is ignored.
+
+Snappy parts are compressed in the block format, lz4 parts are compressed in the
+frame format.
 
 Part indexes can be negative, and if so the part will be selected from the end
 counting backwards starting from -1. E.g. if index = -1 then the selected part
 will be the last part of the message, if index = -2 then the part before the
-last element with be selected, and so on.`,
+last element with be selected, and so on.
+
+Parts that fail to compress are left unchanged and flagged as having failed,
+failed parts can be handled using the methods outlined in the
+[error handling docs](../error_handling.md).`,
 	}
 }
 
@@ -85,10 +105,86 @@ func gzipCompress(level int, b []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func strToCompressor(str string) (compressFunc, error) {
+func zlibCompress(level int, b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw, err := zlib.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = zw.Write(b); err != nil {
+		return nil, err
+	}
+	zw.Close()
+	return buf.Bytes(), nil
+}
+
+func flateCompress(level int, b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw, err := flate.NewWriter(buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = zw.Write(b); err != nil {
+		return nil, err
+	}
+	zw.Close()
+	return buf.Bytes(), nil
+}
+
+func snappyCompress(level int, b []byte) ([]byte, error) {
+	return snappy.Encode(nil, b), nil
+}
+
+func lz4Compress(level int, b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := lz4.NewWriter(buf)
+	zw.Header.HighCompression = level > 0
+
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newZstdCompressor returns a compressFunc that reuses a single zstd encoder,
+// which is safe for concurrent calls to EncodeAll, for all parts.
+func newZstdCompressor(level int) (compressFunc, error) {
+	zLevel := zstd.SpeedDefault
+	if level > 0 {
+		zLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	zw, err := zstd.NewWriter(
+		nil,
+		zstd.WithEncoderLevel(zLevel),
+		zstd.WithEncoderConcurrency(1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ int, b []byte) ([]byte, error) {
+		return zw.EncodeAll(b, nil), nil
+	}, nil
+}
+
+func strToCompressor(str string, level int) (compressFunc, error) {
 	switch str {
 	case "gzip":
 		return gzipCompress, nil
+	case "zlib":
+		return zlibCompress, nil
+	case "flate":
+		return flateCompress, nil
+	case "snappy":
+		return snappyCompress, nil
+	case "lz4":
+		return lz4Compress, nil
+	case "zstd":
+		return newZstdCompressor(level)
 	}
 	return nil, fmt.Errorf("compression type not recognised: %v", str)
 }
@@ -115,7 +211,7 @@ type Compress struct {
 func NewCompress(
 	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (Type, error) {
-	cor, err := strToCompressor(conf.Compress.Algorithm)
+	cor, err := strToCompressor(conf.Compress.Algorithm, conf.Compress.Level)
 	if err != nil {
 		return nil, err
 	}
@@ -156,16 +252,19 @@ func (c *Compress) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 			}
 		}
 		if !isTarget {
-			newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(part))
 			continue
 		}
 		newPart, err := c.comp(c.conf.Level, part)
 		if err == nil {
 			c.mSucc.Incr(1)
-			newMsg.Append(newPart)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(newPart))
 		} else {
 			c.log.Debugf("Failed to compress message part: %v\n", err)
 			c.mErr.Incr(1)
+			index := newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), index)
+			FlagErr(newMsg, index, err)
 		}
 	}
 
@@ -0,0 +1,431 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/gabs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["check"] = TypeSpec{
+		constructor: NewCheck,
+		description: `
+Check is a condition that evaluates a boolean expression against a message,
+which allows complex conditions to be written without deeply nesting
+` + "`and`, `or`, `not` and `xor`" + ` conditions. The expression is parsed
+when the condition is created, and errors in the expression are reported along
+with the character position at which they occurred.
+
+For example, the following condition passes for messages where the first part
+is a JSON document with an ` + "`error`" + ` level from either the ` + "`api`" + `
+or ` + "`web`" + ` service, or where the message is larger than a kilobyte:
+
+` + "``` yaml" + `
+check: 'json("level") == "error" && (json("svc") in ["api","web"] || len(content()) > 1024)'
+` + "```" + `
+
+### Operators
+
+Expressions support the logical operators ` + "`&&`, `||`, `^` (xor) and `!`" + `,
+the comparison operators ` + "`==`, `!=`, `>`, `>=`, `<` and `<=`" + `, and
+parentheses for grouping. The ` + "`in`" + ` operator checks whether a value is
+an element of an array, a substring of a string or a key of an object.
+
+Values are written as JSON style literals: strings in single or double quotes,
+numbers, ` + "`true`, `false`, `null`" + ` and arrays such as
+` + "`[1, \"foo\"]`" + `. Values are never converted between types, and values
+that are not booleans are treated as false by logical operators.
+
+### Functions
+
+- ` + "`content(part)`" + ` returns the contents of a message part as a string.
+- ` + "`json(path, part)`" + ` returns the value at a dot separated path of a
+  message part parsed as JSON, or the whole document if the path is empty.
+- ` + "`metadata(key, part)`" + ` returns the value of a metadata key of a
+  message part.
+- ` + "`len(value)`" + ` returns the length of a string, array or object.
+- ` + "`batch_size()`" + ` returns the number of parts in the message.
+
+The ` + "`part`" + ` argument is optional and defaults to the first part.
+Negative indexes count backwards from the last part, and functions that fail to
+read a part return ` + "`null`" + `.
+
+### Conditions
+
+Any other condition type can be called as a function, configured with named
+arguments that match its config fields, or with a single argument for types
+configured by a single value:
+
+` + "``` yaml" + `
+check: 'jmespath(query: "a == b") && !processor_failed() || resource("foo")'
+` + "```" + `
+
+Functions with the same name as a condition type, such as ` + "`content`" + `,
+are only treated as conditions when called with named arguments. The results of
+conditions are cached per message in the same way as condition resources, and
+are evaluated again once the contents or metadata of the message changes.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+var errCheckEmpty = errors.New("expression must not be empty")
+
+// checkNode is a node of an evaluation tree parsed from a check expression.
+type checkNode interface {
+	eval(msg types.Message) interface{}
+}
+
+func checkTruthy(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+//------------------------------------------------------------------------------
+
+type checkLiteralNode struct {
+	value interface{}
+}
+
+func (n *checkLiteralNode) eval(msg types.Message) interface{} {
+	return n.value
+}
+
+type checkListNode struct {
+	items []checkNode
+}
+
+func (n *checkListNode) eval(msg types.Message) interface{} {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		list[i] = item.eval(msg)
+	}
+	return list
+}
+
+// checkLiteralValue returns the value of a node if it is a literal or a list
+// of literals.
+func checkLiteralValue(n checkNode) (interface{}, bool) {
+	switch t := n.(type) {
+	case *checkLiteralNode:
+		return t.value, true
+	case *checkListNode:
+		list := make([]interface{}, len(t.items))
+		for i, item := range t.items {
+			v, ok := checkLiteralValue(item)
+			if !ok {
+				return nil, false
+			}
+			list[i] = v
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+//------------------------------------------------------------------------------
+
+type checkNotNode struct {
+	child checkNode
+}
+
+func (n *checkNotNode) eval(msg types.Message) interface{} {
+	return !checkTruthy(n.child.eval(msg))
+}
+
+type checkAndNode struct {
+	lhs, rhs checkNode
+}
+
+func (n *checkAndNode) eval(msg types.Message) interface{} {
+	return checkTruthy(n.lhs.eval(msg)) && checkTruthy(n.rhs.eval(msg))
+}
+
+type checkOrNode struct {
+	lhs, rhs checkNode
+}
+
+func (n *checkOrNode) eval(msg types.Message) interface{} {
+	return checkTruthy(n.lhs.eval(msg)) || checkTruthy(n.rhs.eval(msg))
+}
+
+type checkXorNode struct {
+	lhs, rhs checkNode
+}
+
+func (n *checkXorNode) eval(msg types.Message) interface{} {
+	return checkTruthy(n.lhs.eval(msg)) != checkTruthy(n.rhs.eval(msg))
+}
+
+//------------------------------------------------------------------------------
+
+type checkCompareNode struct {
+	op       string
+	lhs, rhs checkNode
+}
+
+// checkOrder returns -1, 0 or 1 when the lhs is respectively less than, equal
+// to or greater than the rhs, and false if the values cannot be ordered.
+func checkOrder(lhs, rhs interface{}) (int, bool) {
+	switch l := lhs.(type) {
+	case float64:
+		if r, ok := rhs.(float64); ok {
+			if l < r {
+				return -1, true
+			} else if l > r {
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if r, ok := rhs.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	}
+	return 0, false
+}
+
+func checkIn(lhs, rhs interface{}) bool {
+	switch r := rhs.(type) {
+	case []interface{}:
+		for _, e := range r {
+			if reflect.DeepEqual(lhs, e) {
+				return true
+			}
+		}
+	case string:
+		if l, ok := lhs.(string); ok {
+			return strings.Contains(r, l)
+		}
+	case map[string]interface{}:
+		if l, ok := lhs.(string); ok {
+			_, exists := r[l]
+			return exists
+		}
+	}
+	return false
+}
+
+func (n *checkCompareNode) eval(msg types.Message) interface{} {
+	lhs, rhs := n.lhs.eval(msg), n.rhs.eval(msg)
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(lhs, rhs)
+	case "!=":
+		return !reflect.DeepEqual(lhs, rhs)
+	case "in":
+		return checkIn(lhs, rhs)
+	}
+	c, ok := checkOrder(lhs, rhs)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
+
+type checkFuncNode struct {
+	fn   func(msg types.Message, args []interface{}) interface{}
+	args []checkNode
+}
+
+func (n *checkFuncNode) eval(msg types.Message) interface{} {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(msg)
+	}
+	return n.fn(msg, args)
+}
+
+// checkConditionNode evaluates a condition, where the label is the source of
+// the call within the expression and is used for caching results.
+type checkConditionNode struct {
+	label string
+	cond  Type
+}
+
+func (n *checkConditionNode) eval(msg types.Message) interface{} {
+	return msg.LazyCondition(n.label, n.cond)
+}
+
+//------------------------------------------------------------------------------
+
+// checkPartArg returns the index of a message part from an optional argument,
+// or false if the index is invalid.
+func checkPartArg(msg types.Message, args []interface{}, i int) (int, bool) {
+	index := 0
+	if len(args) > i {
+		f, ok := args[i].(float64)
+		if !ok {
+			return 0, false
+		}
+		index = int(f)
+	}
+	if index < 0 {
+		index = msg.Len() + index
+	}
+	if index < 0 || index >= msg.Len() {
+		return 0, false
+	}
+	return index, true
+}
+
+type checkFunction struct {
+	minArgs int
+	maxArgs int
+	fn      func(msg types.Message, args []interface{}) interface{}
+}
+
+var checkFunctions = map[string]checkFunction{
+	"content": {
+		minArgs: 0, maxArgs: 1,
+		fn: func(msg types.Message, args []interface{}) interface{} {
+			index, ok := checkPartArg(msg, args, 0)
+			if !ok {
+				return nil
+			}
+			return string(msg.Get(index))
+		},
+	},
+	"json": {
+		minArgs: 0, maxArgs: 2,
+		fn: func(msg types.Message, args []interface{}) interface{} {
+			path := ""
+			if len(args) > 0 {
+				var ok bool
+				if path, ok = args[0].(string); !ok {
+					return nil
+				}
+			}
+			index, ok := checkPartArg(msg, args, 1)
+			if !ok {
+				return nil
+			}
+			jObj, err := msg.GetJSON(index)
+			if err != nil {
+				return nil
+			}
+			if len(path) == 0 {
+				return jObj
+			}
+			gObj, _ := gabs.Consume(jObj)
+			return gObj.Path(path).Data()
+		},
+	},
+	"metadata": {
+		minArgs: 1, maxArgs: 2,
+		fn: func(msg types.Message, args []interface{}) interface{} {
+			key, ok := args[0].(string)
+			if !ok {
+				return nil
+			}
+			index, ok := checkPartArg(msg, args, 1)
+			if !ok {
+				return nil
+			}
+			return msg.GetMetadata(index).Get(key)
+		},
+	},
+	"len": {
+		minArgs: 1, maxArgs: 1,
+		fn: func(msg types.Message, args []interface{}) interface{} {
+			switch t := args[0].(type) {
+			case string:
+				return float64(len(t))
+			case []interface{}:
+				return float64(len(t))
+			case map[string]interface{}:
+				return float64(len(t))
+			}
+			return nil
+		},
+	},
+	"batch_size": {
+		minArgs: 0, maxArgs: 0,
+		fn: func(msg types.Message, args []interface{}) interface{} {
+			return float64(msg.Len())
+		},
+	},
+}
+
+//------------------------------------------------------------------------------
+
+// Check is a condition that evaluates a boolean expression.
+type Check struct {
+	root checkNode
+
+	mTrue  metrics.StatCounter
+	mFalse metrics.StatCounter
+}
+
+// NewCheck returns a Check condition.
+func NewCheck(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	if len(strings.TrimSpace(conf.Check)) == 0 {
+		return nil, errCheckEmpty
+	}
+	root, err := parseCheckExpression(conf.Check, mgr, log, stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression: %v", err)
+	}
+	return &Check{
+		root: root,
+
+		mTrue:  stats.GetCounter("condition.check.true"),
+		mFalse: stats.GetCounter("condition.check.false"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Check attempts to check a message against a configured condition.
+func (c *Check) Check(msg types.Message) bool {
+	if checkTruthy(c.root.eval(msg)) {
+		c.mTrue.Incr(1)
+		return true
+	}
+	c.mFalse.Incr(1)
+	return false
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,471 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------
+
+type checkTokenType int
+
+const (
+	checkTokEOF checkTokenType = iota
+	checkTokIdent
+	checkTokString
+	checkTokNumber
+	checkTokPunct
+)
+
+type checkToken struct {
+	typ checkTokenType
+	val string
+	pos int
+}
+
+func (t checkToken) String() string {
+	switch t.typ {
+	case checkTokEOF:
+		return "end of expression"
+	case checkTokString:
+		return strconv.Quote(t.val)
+	}
+	return fmt.Sprintf("'%v'", t.val)
+}
+
+// checkPuncts lists punctuation tokens, longest first so that two character
+// operators are matched before their single character prefixes.
+var checkPuncts = []string{
+	"&&", "||", "==", "!=", ">=", "<=",
+	"(", ")", "[", "]", ",", ":", "!", "^", ">", "<",
+}
+
+// checkLex splits an expression into tokens.
+func checkLex(expr string) ([]checkToken, error) {
+	var tokens []checkToken
+	runes := []rune(expr)
+
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var buf strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, checkErr(start, "unterminated string literal")
+				}
+				if runes[i] == r {
+					i++
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						buf.WriteRune('\n')
+					case 't':
+						buf.WriteRune('\t')
+					case 'r':
+						buf.WriteRune('\r')
+					default:
+						buf.WriteRune(runes[i])
+					}
+					i++
+					continue
+				}
+				buf.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, checkToken{typ: checkTokString, val: buf.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' ||
+				runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, checkToken{typ: checkTokNumber, val: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, checkToken{typ: checkTokIdent, val: string(runes[start:i]), pos: start})
+		default:
+			matched := false
+			for _, p := range checkPuncts {
+				if strings.HasPrefix(string(runes[i:]), p) {
+					tokens = append(tokens, checkToken{typ: checkTokPunct, val: p, pos: i})
+					i += len([]rune(p))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, checkErr(i, fmt.Sprintf("unexpected character '%c'", r))
+			}
+		}
+	}
+	tokens = append(tokens, checkToken{typ: checkTokEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// checkErr creates an error for a position within an expression, positions are
+// reported starting from 1.
+func checkErr(pos int, msg string) error {
+	return fmt.Errorf("char %v: %v", pos+1, msg)
+}
+
+//------------------------------------------------------------------------------
+
+// checkParser builds an evaluation tree from the tokens of an expression.
+type checkParser struct {
+	expr   []rune
+	tokens []checkToken
+	i      int
+
+	mgr   types.Manager
+	log   log.Modular
+	stats metrics.Type
+}
+
+// parseCheckExpression parses an expression into an evaluation tree. Condition
+// types called as functions are constructed with the provided manager, logger
+// and metrics aggregator.
+func parseCheckExpression(
+	expr string, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (checkNode, error) {
+	tokens, err := checkLex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &checkParser{
+		expr:   []rune(expr),
+		tokens: tokens,
+		mgr:    mgr,
+		log:    log,
+		stats:  stats,
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != checkTokEOF {
+		return nil, checkErr(tok.pos, fmt.Sprintf("unexpected %v", tok))
+	}
+	return node, nil
+}
+
+func (p *checkParser) peek() checkToken {
+	return p.tokens[p.i]
+}
+
+func (p *checkParser) next() checkToken {
+	tok := p.tokens[p.i]
+	if tok.typ != checkTokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *checkParser) isPunct(val string) bool {
+	tok := p.peek()
+	return tok.typ == checkTokPunct && tok.val == val
+}
+
+func (p *checkParser) expect(val string) error {
+	if tok := p.next(); tok.typ != checkTokPunct || tok.val != val {
+		return checkErr(tok.pos, fmt.Sprintf("expected '%v' but found %v", val, tok))
+	}
+	return nil
+}
+
+func (p *checkParser) parseOr() (checkNode, error) {
+	lhs, err := p.parseXor()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("||") {
+		p.next()
+		var rhs checkNode
+		if rhs, err = p.parseXor(); err != nil {
+			return nil, err
+		}
+		lhs = &checkOrNode{lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *checkParser) parseXor() (checkNode, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("^") {
+		p.next()
+		var rhs checkNode
+		if rhs, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		lhs = &checkXorNode{lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *checkParser) parseAnd() (checkNode, error) {
+	lhs, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("&&") {
+		p.next()
+		var rhs checkNode
+		if rhs, err = p.parseNot(); err != nil {
+			return nil, err
+		}
+		lhs = &checkAndNode{lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *checkParser) parseNot() (checkNode, error) {
+	if p.isPunct("!") {
+		p.next()
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &checkNotNode{child: child}, nil
+	}
+	return p.parseComparison()
+}
+
+var checkComparators = map[string]struct{}{
+	"==": {}, "!=": {}, ">": {}, ">=": {}, "<": {}, "<=": {},
+}
+
+func (p *checkParser) parseComparison() (checkNode, error) {
+	lhs, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	op := ""
+	if tok.typ == checkTokPunct {
+		if _, exists := checkComparators[tok.val]; exists {
+			op = tok.val
+		}
+	} else if tok.typ == checkTokIdent && tok.val == "in" {
+		op = tok.val
+	}
+	if len(op) == 0 {
+		return lhs, nil
+	}
+	p.next()
+	rhs, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return &checkCompareNode{op: op, lhs: lhs, rhs: rhs}, nil
+}
+
+func (p *checkParser) parsePrimary() (checkNode, error) {
+	tok := p.next()
+	switch tok.typ {
+	case checkTokString:
+		return &checkLiteralNode{value: tok.val}, nil
+	case checkTokNumber:
+		f, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, checkErr(tok.pos, fmt.Sprintf("invalid number %v", tok))
+		}
+		return &checkLiteralNode{value: f}, nil
+	case checkTokIdent:
+		switch tok.val {
+		case "true":
+			return &checkLiteralNode{value: true}, nil
+		case "false":
+			return &checkLiteralNode{value: false}, nil
+		case "null":
+			return &checkLiteralNode{value: nil}, nil
+		}
+		if !p.isPunct("(") {
+			return nil, checkErr(tok.pos, fmt.Sprintf("unexpected %v, function names must be followed by '('", tok))
+		}
+		return p.parseCall(tok)
+	case checkTokPunct:
+		switch tok.val {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		case "[":
+			return p.parseList()
+		}
+	}
+	return nil, checkErr(tok.pos, fmt.Sprintf("unexpected %v", tok))
+}
+
+func (p *checkParser) parseList() (checkNode, error) {
+	list := &checkListNode{}
+	if p.isPunct("]") {
+		p.next()
+		return list, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		if p.isPunct("]") {
+			p.next()
+			return list, nil
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCall parses the arguments of a function call, where the name token has
+// already been consumed.
+func (p *checkParser) parseCall(name checkToken) (checkNode, error) {
+	p.next() // Consume '('
+
+	var args []checkNode
+	named := map[string]checkNode{}
+	var namedOrder []string
+
+	if !p.isPunct(")") {
+		for {
+			if tok := p.peek(); tok.typ == checkTokIdent &&
+				p.tokens[p.i+1].typ == checkTokPunct && p.tokens[p.i+1].val == ":" {
+				p.next()
+				p.next()
+				if _, exists := named[tok.val]; exists {
+					return nil, checkErr(tok.pos, fmt.Sprintf("duplicate argument '%v'", tok.val))
+				}
+				arg, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				named[tok.val] = arg
+				namedOrder = append(namedOrder, tok.val)
+			} else {
+				if len(named) > 0 {
+					return nil, checkErr(tok.pos, "positional arguments must precede named arguments")
+				}
+				arg, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+			}
+			if p.isPunct(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	end := p.next() // Consume ')'
+	source := string(p.expr[name.pos : end.pos+1])
+
+	if fn, exists := checkFunctions[name.val]; exists && len(named) == 0 {
+		if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+			return nil, checkErr(name.pos, fmt.Sprintf(
+				"function '%v' expects between %v and %v arguments, received %v",
+				name.val, fn.minArgs, fn.maxArgs, len(args),
+			))
+		}
+		return &checkFuncNode{fn: fn.fn, args: args}, nil
+	}
+
+	if _, exists := Constructors[name.val]; !exists {
+		return nil, checkErr(name.pos, fmt.Sprintf("function '%v' not recognised", name.val))
+	}
+	if len(args) > 1 || (len(args) == 1 && len(named) > 0) {
+		return nil, checkErr(name.pos, fmt.Sprintf(
+			"condition '%v' expects either a single argument or named arguments", name.val,
+		))
+	}
+
+	// Condition types are configured with literal values, which we convert
+	// into a config through YAML so that default values are applied.
+	var confValue interface{}
+	if len(args) == 1 {
+		var ok bool
+		if confValue, ok = checkLiteralValue(args[0]); !ok {
+			return nil, checkErr(name.pos, fmt.Sprintf("arguments of condition '%v' must be literal values", name.val))
+		}
+	} else {
+		fields := yaml.MapSlice{}
+		for _, k := range namedOrder {
+			v, ok := checkLiteralValue(named[k])
+			if !ok {
+				return nil, checkErr(name.pos, fmt.Sprintf("arguments of condition '%v' must be literal values", name.val))
+			}
+			fields = append(fields, yaml.MapItem{Key: k, Value: v})
+		}
+		confValue = fields
+	}
+
+	confBytes, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "type", Value: name.val},
+		{Key: name.val, Value: confValue},
+	})
+	if err != nil {
+		return nil, checkErr(name.pos, fmt.Sprintf("failed to create condition '%v' config: %v", name.val, err))
+	}
+	conf := NewConfig()
+	if err = yaml.Unmarshal(confBytes, &conf); err != nil {
+		return nil, checkErr(name.pos, fmt.Sprintf("failed to create condition '%v' config: %v", name.val, err))
+	}
+	cond, err := New(conf, p.mgr, p.log, p.stats)
+	if err != nil {
+		return nil, checkErr(name.pos, fmt.Sprintf("failed to create condition '%v': %v", name.val, err))
+	}
+	return &checkConditionNode{label: source, cond: cond}, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,341 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestCheckExpressions(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	tests := []struct {
+		name string
+		expr string
+		arg  []string
+		want bool
+	}{
+		{
+			name: "request example pos",
+			expr: `json("level") == "error" && (json("svc") in ["api","web"] || len(content()) > 1024)`,
+			arg:  []string{`{"level":"error","svc":"web"}`},
+			want: true,
+		},
+		{
+			name: "request example neg",
+			expr: `json("level") == "error" && (json("svc") in ["api","web"] || len(content()) > 1024)`,
+			arg:  []string{`{"level":"error","svc":"db"}`},
+			want: false,
+		},
+		{
+			name: "request example size pos",
+			expr: `json("level") == "error" && (json("svc") in ["api","web"] || len(content()) > 10)`,
+			arg:  []string{`{"level":"error","svc":"db"}`},
+			want: true,
+		},
+		{
+			name: "precedence and before or",
+			expr: `true || false && false`,
+			want: true,
+		},
+		{
+			name: "not",
+			expr: `!(1 > 2) && !false`,
+			want: true,
+		},
+		{
+			name: "xor",
+			expr: `true ^ true`,
+			want: false,
+		},
+		{
+			name: "single quotes and escapes",
+			expr: `content() == 'it\'s "here"'`,
+			arg:  []string{`it's "here"`},
+			want: true,
+		},
+		{
+			name: "numbers",
+			expr: `json("a") >= -1.5e1 && json("a") < 0`,
+			arg:  []string{`{"a":-15}`},
+			want: true,
+		},
+		{
+			name: "no type conversion",
+			expr: `json("a") == "5"`,
+			arg:  []string{`{"a":5}`},
+			want: false,
+		},
+		{
+			name: "strings ordered",
+			expr: `content() < "b"`,
+			arg:  []string{`abc`},
+			want: true,
+		},
+		{
+			name: "in string",
+			expr: `"ell" in content()`,
+			arg:  []string{`hello`},
+			want: true,
+		},
+		{
+			name: "in object",
+			expr: `"b" in json("a")`,
+			arg:  []string{`{"a":{"b":null}}`},
+			want: true,
+		},
+		{
+			name: "null equals missing",
+			expr: `json("b") == null`,
+			arg:  []string{`{"a":1}`},
+			want: true,
+		},
+		{
+			name: "non bool is false",
+			expr: `content()`,
+			arg:  []string{`true`},
+			want: false,
+		},
+		{
+			name: "json root",
+			expr: `len(json()) == 2`,
+			arg:  []string{`[1,2]`},
+			want: true,
+		},
+		{
+			name: "parts",
+			expr: `content(1) == "bar" && content(-1) == "baz" && content(3) == null`,
+			arg:  []string{`foo`, `bar`, `baz`},
+			want: true,
+		},
+		{
+			name: "json part",
+			expr: `json("a", 1) == 2`,
+			arg:  []string{`{"a":1}`, `{"a":2}`},
+			want: true,
+		},
+		{
+			name: "batch size",
+			expr: `batch_size() == 3`,
+			arg:  []string{`foo`, `bar`, `baz`},
+			want: true,
+		},
+		{
+			name: "metadata",
+			expr: `metadata("foo") == ""`,
+			arg:  []string{`foo`},
+			want: true,
+		},
+		{
+			name: "condition named args",
+			expr: `content(operator: "prefix", arg: "FOO") && !jmespath(query: "a == 'b'")`,
+			arg:  []string{`foobar`},
+			want: true,
+		},
+		{
+			name: "condition named args part",
+			expr: `content(operator: "equals_cs", part: 1, arg: "bar")`,
+			arg:  []string{`foo`, `bar`},
+			want: true,
+		},
+		{
+			name: "condition single arg",
+			expr: `static(false) || static(true)`,
+			want: true,
+		},
+		{
+			name: "condition no args",
+			expr: `processor_failed()`,
+			arg:  []string{`foo`},
+			want: false,
+		},
+		{
+			name: "nested check",
+			expr: `check("content() == 'foo'")`,
+			arg:  []string{`foo`},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfig()
+			conf.Type = "check"
+			conf.Check = tt.expr
+
+			c, err := New(conf, nil, testLog, testMet)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parts := [][]byte{}
+			for _, p := range tt.arg {
+				parts = append(parts, []byte(p))
+			}
+			if got := c.Check(types.NewMessage(parts)); got != tt.want {
+				t.Errorf("Check.Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLazyConditions(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "check"
+	conf.Check = `count(arg: 2)`
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The count condition is only executed once per message.
+	msg := types.NewMessage([][]byte{[]byte("foo")})
+	for i := 0; i < 3; i++ {
+		if !c.Check(msg) {
+			t.Errorf("Expected pass on check %v", i)
+		}
+	}
+	if c.Check(types.NewMessage([][]byte{[]byte("foo")})) {
+		t.Error("Expected fail from new message")
+	}
+}
+
+func TestCheckLazyConditionsMetadata(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "check"
+	conf.Check = `processor_failed(part: 0)`
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{[]byte("foo")})
+	if c.Check(msg) {
+		t.Error("Expected fail without failure flag")
+	}
+
+	// Changing metadata in place invalidates the cached result.
+	msg.GetMetadata(0).Set(types.FailFlagKey, "nope")
+	if !c.Check(msg) {
+		t.Error("Expected pass after failure flag set")
+	}
+	msg.GetMetadata(0).Delete(types.FailFlagKey)
+	if c.Check(msg) {
+		t.Error("Expected fail after failure flag deleted")
+	}
+}
+
+func TestCheckResource(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	resConf := NewConfig()
+	resConf.Type = "content"
+	resConf.Content.Operator = "equals_cs"
+	resConf.Content.Arg = "foo"
+
+	resCond, err := New(resConf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &fakeMgr{
+		conds: map[string]Type{
+			"foo": resCond,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = "check"
+	conf.Check = `resource("foo") || content() == "bar"`
+
+	c, err := New(conf, mgr, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{"foo", "bar"} {
+		if !c.Check(types.NewMessage([][]byte{[]byte(input)})) {
+			t.Errorf("Expected pass from: %v", input)
+		}
+	}
+	if c.Check(types.NewMessage([][]byte{[]byte("baz")})) {
+		t.Error("Expected fail from: baz")
+	}
+
+	conf.Check = `resource("bar")`
+	if _, err = New(conf, mgr, testLog, testMet); err == nil {
+		t.Error("Expected error from missing resource")
+	}
+}
+
+func TestCheckParseErrors(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	tests := map[string]string{
+		``:                           "expression must not be empty",
+		`content() == "foo`:          "char 14: unterminated string literal",
+		`content() == `:              "char 14: unexpected end of expression",
+		`(true`:                      "char 6: expected ')' but found end of expression",
+		`true && foo`:                "char 9: unexpected 'foo', function names must be followed by '('",
+		`nope()`:                     "char 1: function 'nope' not recognised",
+		`json("a") == 1 2`:           "char 16: unexpected '2'",
+		`len()`:                      "char 1: function 'len' expects between 1 and 1 arguments, received 0",
+		`content() # 1`:              "char 11: unexpected character '#'",
+		`[1, 2`:                      "char 6: expected ',' but found end of expression",
+		`jmespath(query: content())`: "char 1: arguments of condition 'jmespath' must be literal values",
+		`content(operator: "nope")`:  "char 1: failed to create condition 'content'",
+		`static(a: 1, b: 2, a: 3)`:   "char 20: duplicate argument 'a'",
+		`static(a: 1, true)`:         "char 14: positional arguments must precede named arguments",
+		`resource("a", "b")`:         "char 1: condition 'resource' expects either a single argument or named arguments",
+	}
+
+	for expr, exp := range tests {
+		conf := NewConfig()
+		conf.Type = "check"
+		conf.Check = expr
+
+		_, err := New(conf, nil, testLog, testMet)
+		if err == nil {
+			t.Errorf("Expected error from: %v", expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("Wrong error for '%v': %v does not contain %v", expr, err, exp)
+		}
+	}
+}
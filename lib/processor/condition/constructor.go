@@ -54,31 +54,37 @@ var Constructors = map[string]TypeSpec{}
 
 // Config is the all encompassing configuration struct for all condition types.
 type Config struct {
-	Type     string         `json:"type" yaml:"type"`
-	And      AndConfig      `json:"and" yaml:"and"`
-	Content  ContentConfig  `json:"content" yaml:"content"`
-	Count    CountConfig    `json:"count" yaml:"count"`
-	JMESPath JMESPathConfig `json:"jmespath" yaml:"jmespath"`
-	Not      NotConfig      `json:"not" yaml:"not"`
-	Or       OrConfig       `json:"or" yaml:"or"`
-	Resource string         `json:"resource" yaml:"resource"`
-	Static   bool           `json:"static" yaml:"static"`
-	Xor      XorConfig      `json:"xor" yaml:"xor"`
+	Type            string                `json:"type" yaml:"type"`
+	And             AndConfig             `json:"and" yaml:"and"`
+	Check           string                `json:"check" yaml:"check"`
+	Content         ContentConfig         `json:"content" yaml:"content"`
+	Count           CountConfig           `json:"count" yaml:"count"`
+	JMESPath        JMESPathConfig        `json:"jmespath" yaml:"jmespath"`
+	JSONField       JSONFieldConfig       `json:"json_field" yaml:"json_field"`
+	Not             NotConfig             `json:"not" yaml:"not"`
+	Or              OrConfig              `json:"or" yaml:"or"`
+	ProcessorFailed ProcessorFailedConfig `json:"processor_failed" yaml:"processor_failed"`
+	Resource        string                `json:"resource" yaml:"resource"`
+	Static          bool                  `json:"static" yaml:"static"`
+	Xor             XorConfig             `json:"xor" yaml:"xor"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:     "content",
-		And:      NewAndConfig(),
-		Content:  NewContentConfig(),
-		Count:    NewCountConfig(),
-		JMESPath: NewJMESPathConfig(),
-		Not:      NewNotConfig(),
-		Or:       NewOrConfig(),
-		Resource: "",
-		Static:   true,
-		Xor:      NewXorConfig(),
+		Type:            "content",
+		And:             NewAndConfig(),
+		Check:           "",
+		Content:         NewContentConfig(),
+		Count:           NewCountConfig(),
+		JMESPath:        NewJMESPathConfig(),
+		JSONField:       NewJSONFieldConfig(),
+		Not:             NewNotConfig(),
+		Or:              NewOrConfig(),
+		ProcessorFailed: NewProcessorFailedConfig(),
+		Resource:        "",
+		Static:          true,
+		Xor:             NewXorConfig(),
 	}
 }
 
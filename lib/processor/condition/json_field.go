@@ -0,0 +1,411 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/gabs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["json_field"] = TypeSpec{
+		constructor: NewJSONField,
+		description: `
+Parses a message part as a JSON document and compares the value found at a dot
+separated path against an argument with a logical operator. If the path is
+empty the whole document is used.
+
+For example, the following config passes for messages where the field
+` + "`latency_ms`" + ` is above 500:
+
+` + "``` yaml" + `
+json_field:
+  part: 0
+  path: latency_ms
+  operator: ">"
+  arg: 500
+` + "```" + `
+
+The ` + "`mode`" + ` field determines which parts are checked. When set to
+` + "`single`" + ` only the part at the index ` + "`part`" + ` is checked, when
+set to ` + "`all`" + ` the condition passes only if every part passes, and when
+set to ` + "`any`" + ` the condition passes if at least one part passes.
+
+Values are never converted between types, therefore a number only matches a
+numeric argument and a string only matches a string argument. Parts that cannot
+be parsed as JSON, or where the path does not exist, fail every operator.
+
+Available logical operators are:
+
+### ` + "`==`, `!=`" + `
+
+Checks whether the value is equal to (or not equal to) the argument, which can
+be any JSON value.
+
+### ` + "`>`, `>=`, `<`, `<=`" + `
+
+Compares the value with the argument, which must be either a number or a
+string. Strings are compared lexicographically.
+
+### ` + "`in`" + `
+
+Checks whether the value is equal to any element of the argument, which must be
+an array.
+
+### ` + "`range`" + `
+
+Checks whether the value is a number within an inclusive range, where the
+argument is an array of the lower and upper bounds, e.g. ` + "`[100, 500]`" + `.
+
+### ` + "`exists`" + `
+
+Checks whether the path exists within the document, including when its value is
+null. The argument is ignored.
+
+### ` + "`is_type`" + `
+
+Checks whether the value is of a JSON type given by the argument, which must be
+one of ` + "`string`, `number`, `bool`, `object`, `array` or `null`" + `.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Errors for the json_field condition.
+var (
+	ErrInvalidJSONFieldOperator = errors.New("invalid json_field operator type")
+	ErrInvalidJSONFieldMode     = errors.New("invalid json_field mode")
+)
+
+// JSONFieldConfig is a configuration struct containing fields for the
+// json_field condition.
+type JSONFieldConfig struct {
+	Mode     string      `json:"mode" yaml:"mode"`
+	Part     int         `json:"part" yaml:"part"`
+	Path     string      `json:"path" yaml:"path"`
+	Operator string      `json:"operator" yaml:"operator"`
+	Arg      interface{} `json:"arg" yaml:"arg"`
+}
+
+// NewJSONFieldConfig returns a JSONFieldConfig with default values.
+func NewJSONFieldConfig() JSONFieldConfig {
+	return JSONFieldConfig{
+		Mode:     "single",
+		Part:     0,
+		Path:     "",
+		Operator: "exists",
+		Arg:      nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// jsonFieldOperator is called with the value at a path and whether the path
+// exists.
+type jsonFieldOperator func(v interface{}, exists bool) bool
+
+// normaliseJSONValue converts values parsed from YAML or JSON configs into the
+// same types that are produced by parsing JSON documents.
+func normaliseJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case []interface{}:
+		arr := make([]interface{}, len(t))
+		for i, e := range t {
+			arr[i] = normaliseJSONValue(e)
+		}
+		return arr
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			obj[fmt.Sprintf("%v", k)] = normaliseJSONValue(e)
+		}
+		return obj
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			obj[k] = normaliseJSONValue(e)
+		}
+		return obj
+	}
+	return v
+}
+
+func jsonFieldEquals(v, arg interface{}) bool {
+	return reflect.DeepEqual(v, arg)
+}
+
+func jsonFieldEqualsOperator(arg interface{}) jsonFieldOperator {
+	return func(v interface{}, exists bool) bool {
+		return exists && jsonFieldEquals(v, arg)
+	}
+}
+
+func jsonFieldNotEqualsOperator(arg interface{}) jsonFieldOperator {
+	return func(v interface{}, exists bool) bool {
+		return exists && !jsonFieldEquals(v, arg)
+	}
+}
+
+// jsonFieldCompareOperator returns an operator that compares a value against an
+// argument, the cmp function is given -1, 0 or 1 when the value is
+// respectively less than, equal to or greater than the argument.
+func jsonFieldCompareOperator(arg interface{}, cmp func(int) bool) (jsonFieldOperator, error) {
+	switch t := arg.(type) {
+	case float64:
+		return func(v interface{}, exists bool) bool {
+			f, ok := v.(float64)
+			if !ok {
+				return false
+			}
+			if f < t {
+				return cmp(-1)
+			} else if f > t {
+				return cmp(1)
+			}
+			return cmp(0)
+		}, nil
+	case string:
+		return func(v interface{}, exists bool) bool {
+			s, ok := v.(string)
+			if !ok {
+				return false
+			}
+			if s < t {
+				return cmp(-1)
+			} else if s > t {
+				return cmp(1)
+			}
+			return cmp(0)
+		}, nil
+	}
+	return nil, fmt.Errorf("argument must be a number or a string, received: %T", arg)
+}
+
+func jsonFieldInOperator(arg interface{}) (jsonFieldOperator, error) {
+	arr, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument must be an array, received: %T", arg)
+	}
+	return func(v interface{}, exists bool) bool {
+		if !exists {
+			return false
+		}
+		for _, e := range arr {
+			if jsonFieldEquals(v, e) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func jsonFieldRangeOperator(arg interface{}) (jsonFieldOperator, error) {
+	arr, ok := arg.([]interface{})
+	if !ok || len(arr) != 2 {
+		return nil, errors.New("argument must be an array of two numbers")
+	}
+	lower, lOk := arr[0].(float64)
+	upper, uOk := arr[1].(float64)
+	if !lOk || !uOk {
+		return nil, errors.New("argument must be an array of two numbers")
+	}
+	return func(v interface{}, exists bool) bool {
+		f, ok := v.(float64)
+		return ok && f >= lower && f <= upper
+	}, nil
+}
+
+func jsonFieldExistsOperator() jsonFieldOperator {
+	return func(v interface{}, exists bool) bool {
+		return exists
+	}
+}
+
+func jsonFieldIsTypeOperator(arg interface{}) (jsonFieldOperator, error) {
+	var check func(v interface{}) bool
+	switch arg {
+	case "string":
+		check = func(v interface{}) bool { _, ok := v.(string); return ok }
+	case "number":
+		check = func(v interface{}) bool { _, ok := v.(float64); return ok }
+	case "bool":
+		check = func(v interface{}) bool { _, ok := v.(bool); return ok }
+	case "object":
+		check = func(v interface{}) bool { _, ok := v.(map[string]interface{}); return ok }
+	case "array":
+		check = func(v interface{}) bool { _, ok := v.([]interface{}); return ok }
+	case "null":
+		check = func(v interface{}) bool { return v == nil }
+	default:
+		return nil, fmt.Errorf("type not recognised: %v", arg)
+	}
+	return func(v interface{}, exists bool) bool {
+		return exists && check(v)
+	}, nil
+}
+
+func strToJSONFieldOperator(str string, arg interface{}) (jsonFieldOperator, error) {
+	arg = normaliseJSONValue(arg)
+	switch str {
+	case "==":
+		return jsonFieldEqualsOperator(arg), nil
+	case "!=":
+		return jsonFieldNotEqualsOperator(arg), nil
+	case ">":
+		return jsonFieldCompareOperator(arg, func(c int) bool { return c > 0 })
+	case ">=":
+		return jsonFieldCompareOperator(arg, func(c int) bool { return c >= 0 })
+	case "<":
+		return jsonFieldCompareOperator(arg, func(c int) bool { return c < 0 })
+	case "<=":
+		return jsonFieldCompareOperator(arg, func(c int) bool { return c <= 0 })
+	case "in":
+		return jsonFieldInOperator(arg)
+	case "range":
+		return jsonFieldRangeOperator(arg)
+	case "exists":
+		return jsonFieldExistsOperator(), nil
+	case "is_type":
+		return jsonFieldIsTypeOperator(arg)
+	}
+	return nil, ErrInvalidJSONFieldOperator
+}
+
+//------------------------------------------------------------------------------
+
+// JSONField is a condition that checks the value of a field within JSON
+// message parts against logical operators.
+type JSONField struct {
+	log      log.Modular
+	mode     string
+	part     int
+	path     string
+	operator jsonFieldOperator
+
+	mSkipped  metrics.StatCounter
+	mErrJSONP metrics.StatCounter
+	mApplied  metrics.StatCounter
+}
+
+// NewJSONField returns a JSONField condition.
+func NewJSONField(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	switch conf.JSONField.Mode {
+	case "single", "all", "any":
+	default:
+		return nil, ErrInvalidJSONFieldMode
+	}
+	op, err := strToJSONFieldOperator(conf.JSONField.Operator, conf.JSONField.Arg)
+	if err != nil {
+		return nil, fmt.Errorf("operator '%v': %v", conf.JSONField.Operator, err)
+	}
+	return &JSONField{
+		log:      log.NewModule(".condition.json_field"),
+		mode:     conf.JSONField.Mode,
+		part:     conf.JSONField.Part,
+		path:     conf.JSONField.Path,
+		operator: op,
+
+		mSkipped:  stats.GetCounter("condition.json_field.skipped"),
+		mErrJSONP: stats.GetCounter("condition.json_field.error.json_parse"),
+		mApplied:  stats.GetCounter("condition.json_field.applied"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (c *JSONField) checkPart(msg types.Message, index int) bool {
+	jsonPart, err := msg.GetJSON(index)
+	if err != nil {
+		c.mErrJSONP.Incr(1)
+		c.log.Debugf("Failed to parse part into json: %v\n", err)
+		return false
+	}
+
+	if len(c.path) == 0 {
+		return c.operator(jsonPart, true)
+	}
+
+	gPart, _ := gabs.Consume(jsonPart)
+	if gTarget := gPart.Path(c.path); gTarget != nil {
+		return c.operator(gTarget.Data(), true)
+	}
+	return c.operator(nil, false)
+}
+
+// Check attempts to check a message against a configured condition.
+func (c *JSONField) Check(msg types.Message) bool {
+	lParts := msg.Len()
+	if lParts == 0 {
+		c.mSkipped.Incr(1)
+		return false
+	}
+
+	switch c.mode {
+	case "all":
+		c.mApplied.Incr(1)
+		for i := 0; i < lParts; i++ {
+			if !c.checkPart(msg, i) {
+				return false
+			}
+		}
+		return true
+	case "any":
+		c.mApplied.Incr(1)
+		for i := 0; i < lParts; i++ {
+			if c.checkPart(msg, i) {
+				return true
+			}
+		}
+		return false
+	}
+
+	index := c.part
+	if index < 0 {
+		index = lParts + index
+	}
+	if index < 0 || index >= lParts {
+		c.mSkipped.Incr(1)
+		return false
+	}
+
+	c.mApplied.Incr(1)
+	return c.checkPart(msg, index)
+}
+
+//------------------------------------------------------------------------------
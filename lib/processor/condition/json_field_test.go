@@ -0,0 +1,319 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestJSONFieldCheck(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	type fields struct {
+		mode     string
+		part     int
+		path     string
+		operator string
+		arg      interface{}
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		arg    []string
+		want   bool
+	}{
+		{
+			name:   "greater than pos",
+			fields: fields{mode: "single", path: "latency_ms", operator: ">", arg: 500},
+			arg:    []string{`{"latency_ms":501}`},
+			want:   true,
+		},
+		{
+			name:   "greater than neg",
+			fields: fields{mode: "single", path: "latency_ms", operator: ">", arg: 500},
+			arg:    []string{`{"latency_ms":500}`},
+			want:   false,
+		},
+		{
+			name:   "greater than string value neg",
+			fields: fields{mode: "single", path: "latency_ms", operator: ">", arg: 500},
+			arg:    []string{`{"latency_ms":"600"}`},
+			want:   false,
+		},
+		{
+			name:   "greater than or equal pos",
+			fields: fields{mode: "single", path: "a.b", operator: ">=", arg: 5.5},
+			arg:    []string{`{"a":{"b":5.5}}`},
+			want:   true,
+		},
+		{
+			name:   "less than pos",
+			fields: fields{mode: "single", path: "a", operator: "<", arg: 0},
+			arg:    []string{`{"a":-1}`},
+			want:   true,
+		},
+		{
+			name:   "less than or equal neg",
+			fields: fields{mode: "single", path: "a", operator: "<=", arg: 0},
+			arg:    []string{`{"a":0.1}`},
+			want:   false,
+		},
+		{
+			name:   "less than string pos",
+			fields: fields{mode: "single", path: "a", operator: "<", arg: "b"},
+			arg:    []string{`{"a":"abc"}`},
+			want:   true,
+		},
+		{
+			name:   "equals number pos",
+			fields: fields{mode: "single", path: "a", operator: "==", arg: 10},
+			arg:    []string{`{"a":10.0}`},
+			want:   true,
+		},
+		{
+			name:   "equals string neg",
+			fields: fields{mode: "single", path: "a", operator: "==", arg: "10"},
+			arg:    []string{`{"a":10}`},
+			want:   false,
+		},
+		{
+			name:   "equals bool pos",
+			fields: fields{mode: "single", path: "a", operator: "==", arg: true},
+			arg:    []string{`{"a":true}`},
+			want:   true,
+		},
+		{
+			name:   "equals object pos",
+			fields: fields{mode: "single", path: "a", operator: "==", arg: map[string]interface{}{"b": 1}},
+			arg:    []string{`{"a":{"b":1}}`},
+			want:   true,
+		},
+		{
+			name:   "not equals pos",
+			fields: fields{mode: "single", path: "a", operator: "!=", arg: "foo"},
+			arg:    []string{`{"a":"bar"}`},
+			want:   true,
+		},
+		{
+			name:   "not equals missing neg",
+			fields: fields{mode: "single", path: "b", operator: "!=", arg: "foo"},
+			arg:    []string{`{"a":"bar"}`},
+			want:   false,
+		},
+		{
+			name:   "in pos",
+			fields: fields{mode: "single", path: "a", operator: "in", arg: []interface{}{"foo", 2, true}},
+			arg:    []string{`{"a":2}`},
+			want:   true,
+		},
+		{
+			name:   "in neg",
+			fields: fields{mode: "single", path: "a", operator: "in", arg: []interface{}{"foo", 2, true}},
+			arg:    []string{`{"a":"2"}`},
+			want:   false,
+		},
+		{
+			name:   "range pos",
+			fields: fields{mode: "single", path: "a", operator: "range", arg: []interface{}{100, 500}},
+			arg:    []string{`{"a":500}`},
+			want:   true,
+		},
+		{
+			name:   "range neg",
+			fields: fields{mode: "single", path: "a", operator: "range", arg: []interface{}{100, 500}},
+			arg:    []string{`{"a":99.9}`},
+			want:   false,
+		},
+		{
+			name:   "exists pos",
+			fields: fields{mode: "single", path: "a", operator: "exists"},
+			arg:    []string{`{"a":null}`},
+			want:   true,
+		},
+		{
+			name:   "exists neg",
+			fields: fields{mode: "single", path: "a.b", operator: "exists"},
+			arg:    []string{`{"a":{"c":1}}`},
+			want:   false,
+		},
+		{
+			name:   "is_type pos",
+			fields: fields{mode: "single", path: "a", operator: "is_type", arg: "array"},
+			arg:    []string{`{"a":[1,2]}`},
+			want:   true,
+		},
+		{
+			name:   "is_type neg",
+			fields: fields{mode: "single", path: "a", operator: "is_type", arg: "number"},
+			arg:    []string{`{"a":"1"}`},
+			want:   false,
+		},
+		{
+			name:   "is_type root pos",
+			fields: fields{mode: "single", path: "", operator: "is_type", arg: "object"},
+			arg:    []string{`{"a":"1"}`},
+			want:   true,
+		},
+		{
+			name:   "invalid json neg",
+			fields: fields{mode: "single", path: "a", operator: "exists"},
+			arg:    []string{`not json`},
+			want:   false,
+		},
+		{
+			name:   "part index pos",
+			fields: fields{mode: "single", part: 1, path: "a", operator: "==", arg: "bar"},
+			arg:    []string{`{"a":"foo"}`, `{"a":"bar"}`},
+			want:   true,
+		},
+		{
+			name:   "negative part index pos",
+			fields: fields{mode: "single", part: -1, path: "a", operator: "==", arg: "bar"},
+			arg:    []string{`{"a":"foo"}`, `{"a":"bar"}`},
+			want:   true,
+		},
+		{
+			name:   "part out of bounds neg",
+			fields: fields{mode: "single", part: 2, path: "a", operator: "exists"},
+			arg:    []string{`{"a":"foo"}`, `{"a":"bar"}`},
+			want:   false,
+		},
+		{
+			name:   "all pos",
+			fields: fields{mode: "all", path: "a", operator: ">", arg: 1},
+			arg:    []string{`{"a":2}`, `{"a":3}`},
+			want:   true,
+		},
+		{
+			name:   "all neg",
+			fields: fields{mode: "all", path: "a", operator: ">", arg: 1},
+			arg:    []string{`{"a":2}`, `{"a":1}`},
+			want:   false,
+		},
+		{
+			name:   "any pos",
+			fields: fields{mode: "any", path: "a", operator: ">", arg: 1},
+			arg:    []string{`{"a":0}`, `not json`, `{"a":3}`},
+			want:   true,
+		},
+		{
+			name:   "any neg",
+			fields: fields{mode: "any", path: "a", operator: ">", arg: 1},
+			arg:    []string{`{"a":0}`, `{"a":1}`},
+			want:   false,
+		},
+		{
+			name:   "empty message neg",
+			fields: fields{mode: "any", path: "a", operator: "exists"},
+			arg:    []string{},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfig()
+			conf.Type = "json_field"
+			conf.JSONField.Mode = tt.fields.mode
+			conf.JSONField.Part = tt.fields.part
+			conf.JSONField.Path = tt.fields.path
+			conf.JSONField.Operator = tt.fields.operator
+			conf.JSONField.Arg = tt.fields.arg
+
+			c, err := NewJSONField(conf, nil, testLog, testMet)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			parts := [][]byte{}
+			for _, p := range tt.arg {
+				parts = append(parts, []byte(p))
+			}
+			if got := c.Check(types.NewMessage(parts)); got != tt.want {
+				t.Errorf("JSONField.Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONFieldYAMLArg(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	if err := yaml.Unmarshal([]byte(`
+type: json_field
+json_field:
+  path: a
+  operator: in
+  arg:
+  - 5
+  - foo: bar
+`), &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{`{"a":5}`, `{"a":{"foo":"bar"}}`} {
+		if !c.Check(types.NewMessage([][]byte{[]byte(input)})) {
+			t.Errorf("Expected condition to pass for: %v", input)
+		}
+	}
+	if c.Check(types.NewMessage([][]byte{[]byte(`{"a":6}`)})) {
+		t.Error("Expected condition to fail")
+	}
+}
+
+func TestJSONFieldBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	tests := map[string]JSONFieldConfig{
+		"bad operator": {Mode: "single", Operator: "nope"},
+		"bad mode":     {Mode: "nope", Operator: "exists"},
+		"bad compare":  {Mode: "single", Operator: ">", Arg: true},
+		"bad in":       {Mode: "single", Operator: "in", Arg: "foo"},
+		"bad range":    {Mode: "single", Operator: "range", Arg: []interface{}{1}},
+		"bad is_type":  {Mode: "single", Operator: "is_type", Arg: "nope"},
+	}
+
+	for name, jConf := range tests {
+		conf := NewConfig()
+		conf.Type = "json_field"
+		conf.JSONField = jConf
+
+		if _, err := NewJSONField(conf, nil, testLog, testMet); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
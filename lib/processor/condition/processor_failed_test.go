@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestProcessorFailed(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	msg := types.NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	})
+	msg.GetMetadata(1).Set(types.FailFlagKey, "nope")
+
+	tests := map[int]bool{
+		0:  false,
+		1:  true,
+		-1: true,
+		-2: false,
+		2:  false,
+		-3: false,
+	}
+
+	for part, exp := range tests {
+		conf := NewConfig()
+		conf.Type = "processor_failed"
+		conf.ProcessorFailed.Part = part
+
+		c, err := New(conf, nil, testLog, testMet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if act := c.Check(msg); act != exp {
+			t.Errorf("Wrong result for part %v: %v != %v", part, act, exp)
+		}
+	}
+}
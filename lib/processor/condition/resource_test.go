@@ -45,6 +45,18 @@ func (f *fakeMgr) GetCondition(name string) (types.Condition, error) {
 	}
 	return nil, types.ErrConditionNotFound
 }
+func (f *fakeMgr) GetInput(name string) (types.Input, error) {
+	return nil, types.ErrInputNotFound
+}
+func (f *fakeMgr) GetOutput(name string) (types.OutputWriter, error) {
+	return nil, types.ErrOutputNotFound
+}
+func (f *fakeMgr) GetProcessor(name string) (types.Processor, error) {
+	return nil, types.ErrProcessorNotFound
+}
+func (f *fakeMgr) GetRateLimit(name string) (types.RateLimit, error) {
+	return nil, types.ErrRateLimitNotFound
+}
 
 func TestResourceCheck(t *testing.T) {
 	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
@@ -21,6 +21,8 @@
 package processor
 
 import (
+	"time"
+
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/processor/condition"
 	"github.com/Jeffail/benthos/lib/types"
@@ -154,3 +156,20 @@ func (c *Conditional) ProcessMessage(msg types.Message) (msgs []types.Message, r
 }
 
 //------------------------------------------------------------------------------
+
+// CloseAsync shuts down any child processors that hold resources.
+func (c *Conditional) CloseAsync() {
+	closeAll(c.children)
+	closeAll(c.elseChildren)
+}
+
+// WaitForClose blocks until the child processors have closed down.
+func (c *Conditional) WaitForClose(timeout time.Duration) error {
+	stopBy := time.Now().Add(timeout)
+	if err := waitForCloseAll(c.children, timeout); err != nil {
+		return err
+	}
+	return waitForCloseAll(c.elseChildren, time.Until(stopBy))
+}
+
+//------------------------------------------------------------------------------
@@ -55,24 +55,36 @@ var Constructors = map[string]TypeSpec{}
 // Config is the all encompassing configuration struct for all processor types.
 type Config struct {
 	Type        string            `json:"type" yaml:"type"`
+	Aggregate   AggregateConfig   `json:"aggregate" yaml:"aggregate"`
 	Archive     ArchiveConfig     `json:"archive" yaml:"archive"`
 	Batch       BatchConfig       `json:"batch" yaml:"batch"`
 	BoundsCheck BoundsCheckConfig `json:"bounds_check" yaml:"bounds_check"`
+	Cache       CacheConfig       `json:"cache" yaml:"cache"`
+	Catch       []Config          `json:"catch" yaml:"catch"`
 	Combine     CombineConfig     `json:"combine" yaml:"combine"`
 	Compress    CompressConfig    `json:"compress" yaml:"compress"`
 	Conditional ConditionalConfig `json:"conditional" yaml:"conditional"`
+	Decode      DecodeConfig      `json:"decode" yaml:"decode"`
 	Decompress  DecompressConfig  `json:"decompress" yaml:"decompress"`
 	Dedupe      DedupeConfig      `json:"dedupe" yaml:"dedupe"`
+	Encode      EncodeConfig      `json:"encode" yaml:"encode"`
 	Filter      FilterConfig      `json:"filter" yaml:"filter"`
 	Grok        GrokConfig        `json:"grok" yaml:"grok"`
 	HashSample  HashSampleConfig  `json:"hash_sample" yaml:"hash_sample"`
+	HTTP        HTTPConfig        `json:"http" yaml:"http"`
 	InsertPart  InsertPartConfig  `json:"insert_part" yaml:"insert_part"`
 	JMESPath    JMESPathConfig    `json:"jmespath" yaml:"jmespath"`
 	JSON        JSONConfig        `json:"json" yaml:"json"`
 	MergeJSON   MergeJSONConfig   `json:"merge_json" yaml:"merge_json"`
+	RateLimit   RateLimitConfig   `json:"rate_limit" yaml:"rate_limit"`
+	Resource    string            `json:"resource" yaml:"resource"`
 	Sample      SampleConfig      `json:"sample" yaml:"sample"`
+	Script      ScriptConfig      `json:"script" yaml:"script"`
 	SelectParts SelectPartsConfig `json:"select_parts" yaml:"select_parts"`
 	Split       struct{}          `json:"split" yaml:"split"`
+	Subprocess  SubprocessConfig  `json:"subprocess" yaml:"subprocess"`
+	Switch      SwitchConfig      `json:"switch" yaml:"switch"`
+	Try         []Config          `json:"try" yaml:"try"`
 	Unarchive   UnarchiveConfig   `json:"unarchive" yaml:"unarchive"`
 }
 
@@ -80,24 +92,36 @@ type Config struct {
 func NewConfig() Config {
 	return Config{
 		Type:        "bounds_check",
+		Aggregate:   NewAggregateConfig(),
 		Archive:     NewArchiveConfig(),
 		Batch:       NewBatchConfig(),
 		BoundsCheck: NewBoundsCheckConfig(),
+		Cache:       NewCacheConfig(),
+		Catch:       []Config{},
 		Combine:     NewCombineConfig(),
 		Compress:    NewCompressConfig(),
 		Conditional: NewConditionalConfig(),
+		Decode:      NewDecodeConfig(),
 		Decompress:  NewDecompressConfig(),
 		Dedupe:      NewDedupeConfig(),
+		Encode:      NewEncodeConfig(),
 		Filter:      NewFilterConfig(),
 		Grok:        NewGrokConfig(),
 		HashSample:  NewHashSampleConfig(),
+		HTTP:        NewHTTPConfig(),
 		InsertPart:  NewInsertPartConfig(),
 		JMESPath:    NewJMESPathConfig(),
 		JSON:        NewJSONConfig(),
 		MergeJSON:   NewMergeJSONConfig(),
+		RateLimit:   NewRateLimitConfig(),
+		Resource:    "",
 		Sample:      NewSampleConfig(),
+		Script:      NewScriptConfig(),
 		SelectParts: NewSelectPartsConfig(),
 		Split:       struct{}{},
+		Subprocess:  NewSubprocessConfig(),
+		Switch:      NewSwitchConfig(),
+		Try:         []Config{},
 		Unarchive:   NewUnarchiveConfig(),
 	}
 }
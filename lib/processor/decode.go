@@ -0,0 +1,240 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/ascii85"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime/quotedprintable"
+	"strings"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["decode"] = TypeSpec{
+		constructor: NewDecode,
+		description: `
+Decodes parts of a message according to the selected scheme. Supported schemes
+are: base64, base64url, base64raw, base64rawurl, hex, ascii85 and
+quoted_printable. The raw base64 schemes expect no padding characters. If the
+list of target parts is empty the decoding will be applied to all message parts.
+
+If a path is set then the part is parsed as a JSON document and only the string
+value found at the path is decoded, where the path is a dot separated sequence
+of object keys such as 'foo.bar'. The decoded value replaces the original as a
+string, and since JSON strings must be valid UTF-8 a decoded value that is not
+valid UTF-8, such as binary data, fails instead. Otherwise the entire contents
+of the part is decoded.
+
+Part indexes can be negative, and if so the part will be selected from the end
+counting backwards starting from -1. E.g. if index = -1 then the selected part
+will be the last part of the message, if index = -2 then the part before the
+last element with be selected, and so on.
+
+Parts that fail to decode are left unchanged and flagged as having failed,
+failed parts can be handled using the methods outlined in the
+[error handling docs](../error_handling.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// DecodeConfig contains any configuration for the Decode processor.
+type DecodeConfig struct {
+	Scheme string `json:"scheme" yaml:"scheme"`
+	Path   string `json:"path" yaml:"path"`
+	Parts  []int  `json:"parts" yaml:"parts"`
+}
+
+// NewDecodeConfig returns a DecodeConfig with default values.
+func NewDecodeConfig() DecodeConfig {
+	return DecodeConfig{
+		Scheme: "base64",
+		Path:   "",
+		Parts:  []int{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type decodeFunc func(bytes []byte) ([]byte, error)
+
+func base64Decoder(enc *base64.Encoding) decodeFunc {
+	return func(b []byte) ([]byte, error) {
+		d := make([]byte, enc.DecodedLen(len(b)))
+		n, err := enc.Decode(d, b)
+		if err != nil {
+			return nil, err
+		}
+		return d[:n], nil
+	}
+}
+
+func hexDecode(b []byte) ([]byte, error) {
+	d := make([]byte, hex.DecodedLen(len(b)))
+	n, err := hex.Decode(d, b)
+	if err != nil {
+		return nil, err
+	}
+	return d[:n], nil
+}
+
+func ascii85Decode(b []byte) ([]byte, error) {
+	d := make([]byte, 4*len(b))
+	n, _, err := ascii85.Decode(d, b, true)
+	if err != nil {
+		return nil, err
+	}
+	return d[:n], nil
+}
+
+func quotedPrintableDecode(b []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if _, err := buf.ReadFrom(quotedprintable.NewReader(bytes.NewReader(b))); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func strToDecoder(str string) (decodeFunc, error) {
+	switch str {
+	case "base64":
+		return base64Decoder(base64.StdEncoding), nil
+	case "base64url":
+		return base64Decoder(base64.URLEncoding), nil
+	case "base64raw":
+		return base64Decoder(base64.RawStdEncoding), nil
+	case "base64rawurl":
+		return base64Decoder(base64.RawURLEncoding), nil
+	case "hex":
+		return hexDecode, nil
+	case "ascii85":
+		return ascii85Decode, nil
+	case "quoted_printable":
+		return quotedPrintableDecode, nil
+	}
+	return nil, fmt.Errorf("decode scheme not recognised: %v", str)
+}
+
+//------------------------------------------------------------------------------
+
+// Decode is a processor that can selectively decode parts of a message with a
+// chosen scheme.
+type Decode struct {
+	conf DecodeConfig
+	fn   decodeFunc
+	path []string
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount   metrics.StatCounter
+	mSucc    metrics.StatCounter
+	mErr     metrics.StatCounter
+	mSkipped metrics.StatCounter
+	mSent    metrics.StatCounter
+}
+
+// NewDecode returns a Decode processor.
+func NewDecode(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	dec, err := strToDecoder(conf.Decode.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	var path []string
+	if len(conf.Decode.Path) > 0 {
+		path = strings.Split(conf.Decode.Path, ".")
+	}
+	return &Decode{
+		conf:  conf.Decode,
+		fn:    dec,
+		path:  path,
+		log:   log.NewModule(".processor.decode"),
+		stats: stats,
+
+		mCount:   stats.GetCounter("processor.decode.count"),
+		mSucc:    stats.GetCounter("processor.decode.success"),
+		mErr:     stats.GetCounter("processor.decode.error"),
+		mSkipped: stats.GetCounter("processor.decode.skipped"),
+		mSent:    stats.GetCounter("processor.decode.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage takes a message, attempts to decode parts of the message and
+// returns the result.
+func (d *Decode) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	d.mCount.Incr(1)
+
+	newMsg := types.NewMessage(nil)
+	lParts := msg.Len()
+
+	noParts := len(d.conf.Parts) == 0
+	for i, part := range msg.GetAll() {
+		isTarget := noParts
+		if !isTarget {
+			nI := i - lParts
+			for _, t := range d.conf.Parts {
+				if t == nI || t == i {
+					isTarget = true
+					break
+				}
+			}
+		}
+		if !isTarget {
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(part))
+			continue
+		}
+		newPart, err := applyAtJSONPath(d.path, part, d.fn)
+		if err == nil {
+			d.mSucc.Incr(1)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(newPart))
+		} else {
+			d.log.Debugf("Failed to decode message part: %v\n", err)
+			d.mErr.Incr(1)
+			index := newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), index)
+			FlagErr(newMsg, index, err)
+		}
+	}
+
+	if newMsg.Len() == 0 {
+		d.mSkipped.Incr(1)
+		return nil, types.NewSimpleResponse(nil)
+	}
+
+	d.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
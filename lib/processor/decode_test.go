@@ -0,0 +1,191 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestDecodeBadAlgo(t *testing.T) {
+	conf := NewConfig()
+	conf.Decode.Scheme = "does not exist"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	_, err := NewDecode(conf, nil, testLog, metrics.DudType{})
+	if err == nil {
+		t.Error("Expected error from bad algo")
+	}
+}
+
+func TestDecodeSchemes(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	exp := [][]byte{
+		[]byte("hello world?"),
+		[]byte("caf\xc3\xa9 = 1"),
+		[]byte("\x00\xff\x10"),
+	}
+
+	for _, scheme := range []string{
+		"base64", "base64url", "base64raw", "base64rawurl",
+		"hex", "ascii85", "quoted_printable",
+	} {
+		encConf := NewConfig()
+		encConf.Encode.Scheme = scheme
+
+		enc, err := NewEncode(encConf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatalf("%v: %v", scheme, err)
+		}
+
+		decConf := NewConfig()
+		decConf.Decode.Scheme = scheme
+
+		dec, err := NewDecode(decConf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatalf("%v: %v", scheme, err)
+		}
+
+		msgs, _ := enc.ProcessMessage(types.NewMessage(exp))
+		if len(msgs) != 1 {
+			t.Fatalf("%v: Encode failed", scheme)
+		}
+		if reflect.DeepEqual(exp, msgs[0].GetAll()) {
+			t.Errorf("%v: Input and encoded output are the same", scheme)
+		}
+
+		msgs, res := dec.ProcessMessage(msgs[0])
+		if len(msgs) != 1 {
+			t.Fatalf("%v: Decode failed", scheme)
+		} else if res != nil {
+			t.Errorf("%v: Expected nil response: %v", scheme, res)
+		}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("%v: Unexpected output: %s != %s", scheme, act, exp)
+		}
+		for i := range exp {
+			if HasFailed(msgs[0], i) {
+				t.Errorf("%v: Part %v was flagged as failed", scheme, i)
+			}
+		}
+	}
+}
+
+func TestDecodeBadInput(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	tests := map[string][]byte{
+		"base64":    []byte("not base64!"),
+		"base64raw": []byte("aGVsbG8="),
+		"hex":       []byte("not hex"),
+		"ascii85":   []byte("not~ascii85"),
+	}
+
+	for scheme, input := range tests {
+		conf := NewConfig()
+		conf.Decode.Scheme = scheme
+
+		proc, err := NewDecode(conf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatalf("%v: %v", scheme, err)
+		}
+
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{input}))
+		if len(msgs) != 1 {
+			t.Fatalf("%v: Expected bad message to be passed through", scheme)
+		}
+		if act := msgs[0].Get(0); !reflect.DeepEqual(input, act) {
+			t.Errorf("%v: Unexpected output: %s != %s", scheme, act, input)
+		}
+		if !HasFailed(msgs[0], 0) {
+			t.Errorf("%v: Expected bad message to be flagged as failed", scheme)
+		}
+	}
+}
+
+func TestDecodeJSONPath(t *testing.T) {
+	conf := NewConfig()
+	conf.Decode.Scheme = "hex"
+	conf.Decode.Path = "foo.bar"
+	conf.Decode.Parts = []int{0}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	proc, err := NewDecode(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"foo":{"bar":"68656c6c6f20776f726c64"}}`),
+		[]byte(`{"foo":{"bar":"68656c6c6f20776f726c64"}}`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Decode failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	exp := [][]byte{
+		[]byte(`{"foo":{"bar":"hello world"}}`),
+		[]byte(`{"foo":{"bar":"68656c6c6f20776f726c64"}}`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+}
+
+func TestDecodeJSONPathInvalidUTF8(t *testing.T) {
+	conf := NewConfig()
+	conf.Decode.Scheme = "hex"
+	conf.Decode.Path = "foo.bar"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	proc, err := NewDecode(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := [][]byte{
+		[]byte(`{"foo":{"bar":"fffe00"}}`),
+	}
+	msgs, res := proc.ProcessMessage(types.NewMessage(input))
+	if len(msgs) != 1 {
+		t.Fatal("Decode failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(input, act) {
+		t.Errorf("Unexpected output: %s != %s", act, input)
+	}
+	if !HasFailed(msgs[0], 0) {
+		t.Error("Expected part to be flagged as failed")
+	}
+}
@@ -22,13 +22,19 @@ package processor
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
 )
 
 //------------------------------------------------------------------------------
@@ -38,16 +44,26 @@ func init() {
 		constructor: NewDecompress,
 		description: `
 Decompresses the parts of a message according to the selected algorithm.
-Supported decompression types are: gzip (I'll add more later). If the list of
-target parts is empty the decompression will be applied to all message parts.
+Supported decompression types are: gzip, zlib, flate, snappy, lz4, zstd and
+auto. If the list of target parts is empty the decompression will be applied to
+all message parts.
+
+Snappy parts can be in either the block or the framed format, lz4 parts must be
+in the frame format.
+
+The algorithm 'auto' detects the compression type of each part from the magic
+bytes at the start of its contents, which allows decompressing a mix of
+algorithms. Only gzip, zlib, lz4, zstd and framed snappy can be detected, since
+flate and snappy blocks have no header.
 
 Part indexes can be negative, and if so the part will be selected from the end
 counting backwards starting from -1. E.g. if index = -1 then the selected part
 will be the last part of the message, if index = -2 then the part before the
 last element with be selected, and so on.
 
-Parts that fail to decompress (invalid format) will be removed from the message.
-If the message results in zero parts it is skipped entirely.`,
+Parts that fail to decompress (invalid format) are left unchanged and flagged as
+having failed, failed parts can be handled using the methods outlined in the
+[error handling docs](../error_handling.md).`,
 	}
 }
 
@@ -86,10 +102,124 @@ func gzipDecompress(b []byte) ([]byte, error) {
 	return outBuf.Bytes(), nil
 }
 
+func zlibDecompress(b []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(b)
+	zr, err := zlib.NewReader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	outBuf := bytes.Buffer{}
+	if _, err = outBuf.ReadFrom(zr); err != nil && err != io.EOF {
+		return nil, err
+	}
+	zr.Close()
+	return outBuf.Bytes(), nil
+}
+
+func flateDecompress(b []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(b)
+	zr := flate.NewReader(buf)
+
+	outBuf := bytes.Buffer{}
+	if _, err := outBuf.ReadFrom(zr); err != nil && err != io.EOF {
+		return nil, err
+	}
+	zr.Close()
+	return outBuf.Bytes(), nil
+}
+
+var snappyFrameMagic = []byte("\xff\x06\x00\x00sNaPpY")
+
+func snappyDecompress(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, snappyFrameMagic) {
+		return snappy.Decode(nil, b)
+	}
+
+	outBuf := bytes.Buffer{}
+	if _, err := outBuf.ReadFrom(snappy.NewReader(bytes.NewReader(b))); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return outBuf.Bytes(), nil
+}
+
+func lz4Decompress(b []byte) ([]byte, error) {
+	outBuf := bytes.Buffer{}
+	if _, err := outBuf.ReadFrom(lz4.NewReader(bytes.NewReader(b))); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return outBuf.Bytes(), nil
+}
+
+// newZstdDecompressor returns a decompressFunc that reuses a single zstd
+// decoder, which is safe for concurrent calls to DecodeAll, for all parts. A
+// decoder with a concurrency of one runs synchronously and therefore holds no
+// goroutines that need closing.
+func newZstdDecompressor() (decompressFunc, error) {
+	zr, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return func(b []byte) ([]byte, error) {
+		return zr.DecodeAll(b, nil)
+	}, nil
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	lz4Magic  = []byte{0x04, 0x22, 0x4d, 0x18}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// isZlibHeader returns true if the first two bytes of b are a valid zlib
+// header, which is a deflate compression method followed by a check value.
+func isZlibHeader(b []byte) bool {
+	if len(b) < 2 {
+		return false
+	}
+	return b[0]&0x0f == 8 && b[0]>>4 <= 7 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+var errUnknownCompression = errors.New("unable to detect compression algorithm")
+
+func newAutoDecompressor() (decompressFunc, error) {
+	zstdDecompress, err := newZstdDecompressor()
+	if err != nil {
+		return nil, err
+	}
+	return func(b []byte) ([]byte, error) {
+		switch {
+		case bytes.HasPrefix(b, gzipMagic):
+			return gzipDecompress(b)
+		case bytes.HasPrefix(b, zstdMagic):
+			return zstdDecompress(b)
+		case bytes.HasPrefix(b, lz4Magic):
+			return lz4Decompress(b)
+		case bytes.HasPrefix(b, snappyFrameMagic):
+			return snappyDecompress(b)
+		case isZlibHeader(b):
+			return zlibDecompress(b)
+		}
+		return nil, errUnknownCompression
+	}, nil
+}
+
 func strToDecompressor(str string) (decompressFunc, error) {
 	switch str {
 	case "gzip":
 		return gzipDecompress, nil
+	case "zlib":
+		return zlibDecompress, nil
+	case "flate":
+		return flateDecompress, nil
+	case "snappy":
+		return snappyDecompress, nil
+	case "lz4":
+		return lz4Decompress, nil
+	case "zstd":
+		return newZstdDecompressor()
+	case "auto":
+		return newAutoDecompressor()
 	}
 	return nil, fmt.Errorf("decompression type not recognised: %v", str)
 }
@@ -157,15 +287,19 @@ func (d *Decompress) ProcessMessage(msg types.Message) ([]types.Message, types.R
 			}
 		}
 		if !isTarget {
-			newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(part))
 			continue
 		}
 		newPart, err := d.decomp(part)
 		if err == nil {
 			d.mSucc.Incr(1)
-			newMsg.Append(newPart)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(newPart))
 		} else {
+			d.log.Debugf("Failed to decompress message part: %v\n", err)
 			d.mErr.Incr(1)
+			index := newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), index)
+			FlagErr(newMsg, index, err)
 		}
 	}
 
@@ -23,13 +23,17 @@ package processor
 import (
 	"bytes"
 	"compress/gzip"
+	"compress/zlib"
+	"fmt"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/Jeffail/benthos/lib/metrics"
 	"github.com/Jeffail/benthos/lib/types"
 	"github.com/Jeffail/benthos/lib/log"
+	"github.com/golang/snappy"
 )
 
 func TestDecompressBadAlgo(t *testing.T) {
@@ -92,6 +96,185 @@ func TestDecompressGZIP(t *testing.T) {
 	}
 }
 
+func TestDecompressRoundTrip(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	input := [][]byte{
+		[]byte("hello world first part"),
+		[]byte("hello world second part"),
+		[]byte("third part"),
+		[]byte("fourth"),
+		[]byte("5"),
+	}
+
+	tests := map[string]int{
+		"gzip":   -1,
+		"zlib":   9,
+		"flate":  1,
+		"snappy": 0,
+		"lz4":    1,
+		"zstd":   3,
+	}
+
+	for algo, level := range tests {
+		compConf := NewConfig()
+		compConf.Compress.Algorithm = algo
+		compConf.Compress.Level = level
+
+		comp, err := NewCompress(compConf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatalf("%v: %v", algo, err)
+		}
+
+		decompConf := NewConfig()
+		decompConf.Decompress.Algorithm = algo
+
+		decomp, err := NewDecompress(decompConf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatalf("%v: %v", algo, err)
+		}
+
+		msgs, res := comp.ProcessMessage(types.NewMessage(input))
+		if len(msgs) != 1 {
+			t.Fatalf("%v: Compress failed", algo)
+		} else if res != nil {
+			t.Fatalf("%v: Expected nil response: %v", algo, res)
+		}
+		if reflect.DeepEqual(input, msgs[0].GetAll()) {
+			t.Errorf("%v: Input and compressed output are the same", algo)
+		}
+		for i := 0; i < msgs[0].Len(); i++ {
+			if HasFailed(msgs[0], i) {
+				t.Errorf("%v: Part %v failed to compress", algo, i)
+			}
+		}
+
+		if msgs, res = decomp.ProcessMessage(msgs[0]); len(msgs) != 1 {
+			t.Fatalf("%v: Decompress failed", algo)
+		} else if res != nil {
+			t.Fatalf("%v: Expected nil response: %v", algo, res)
+		}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(input, act) {
+			t.Errorf("%v: Unexpected output: %s != %s", algo, act, input)
+		}
+	}
+}
+
+func TestDecompressZstdConcurrent(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	compConf := NewConfig()
+	compConf.Compress.Algorithm = "zstd"
+	comp, err := NewCompress(compConf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decompConf := NewConfig()
+	decompConf.Decompress.Algorithm = "zstd"
+	decomp, err := NewDecompress(decompConf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := [][]byte{
+				[]byte(fmt.Sprintf("first part %v", i)),
+				[]byte(fmt.Sprintf("second part %v", i)),
+			}
+			msgs, _ := comp.ProcessMessage(types.NewMessage(input))
+			if len(msgs) != 1 {
+				t.Errorf("Compress failed")
+				return
+			}
+			if msgs, _ = decomp.ProcessMessage(msgs[0]); len(msgs) != 1 {
+				t.Errorf("Decompress failed")
+				return
+			}
+			if act := msgs[0].GetAll(); !reflect.DeepEqual(input, act) {
+				t.Errorf("Unexpected output: %s != %s", act, input)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDecompressAuto(t *testing.T) {
+	conf := NewConfig()
+	conf.Decompress.Algorithm = "auto"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	exp := [][]byte{
+		[]byte("gzip part"),
+		[]byte("zlib part"),
+		[]byte("snappy part"),
+		[]byte("lz4 part"),
+		[]byte("zstd part"),
+		[]byte("not compressed"),
+	}
+
+	input := make([][]byte, len(exp))
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(exp[0])
+	gw.Close()
+	input[0] = buf.Bytes()
+
+	buf = bytes.Buffer{}
+	zw := zlib.NewWriter(&buf)
+	zw.Write(exp[1])
+	zw.Close()
+	input[1] = buf.Bytes()
+
+	buf = bytes.Buffer{}
+	sw := snappy.NewBufferedWriter(&buf)
+	sw.Write(exp[2])
+	sw.Close()
+	input[2] = buf.Bytes()
+
+	var err error
+	if input[3], err = lz4Compress(0, exp[3]); err != nil {
+		t.Fatal(err)
+	}
+	zstdCompress, err := newZstdCompressor(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input[4], err = zstdCompress(0, exp[4]); err != nil {
+		t.Fatal(err)
+	}
+	input[5] = exp[5]
+
+	proc, err := NewDecompress(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage(input))
+	if len(msgs) != 1 {
+		t.Fatal("Decompress failed")
+	} else if res != nil {
+		t.Fatalf("Expected nil response: %v", res)
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+	for i := 0; i < 5; i++ {
+		if HasFailed(msgs[0], i) {
+			t.Errorf("Part %v was flagged as failed", i)
+		}
+	}
+	if !HasFailed(msgs[0], 5) {
+		t.Error("Expected uncompressed part to be flagged as failed")
+	}
+}
+
 func TestDecompressIndexBounds(t *testing.T) {
 	conf := NewConfig()
 
@@ -201,7 +384,15 @@ func TestDecompressEmpty(t *testing.T) {
 	msgs, _ = proc.ProcessMessage(types.NewMessage(
 		[][]byte{[]byte("first"), []byte("second")},
 	))
-	if len(msgs) > 0 {
-		t.Error("Expected failure with bad data")
+	if len(msgs) != 1 {
+		t.Fatal("Expected bad data to be passed through")
+	}
+	if exp, act := [][]byte{[]byte("first"), []byte("second")}, msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong output from bad data: %s != %s", act, exp)
+	}
+	for i := 0; i < 2; i++ {
+		if !HasFailed(msgs[0], i) {
+			t.Errorf("Expected part %v to be flagged as failed", i)
+		}
 	}
 }
@@ -43,7 +43,9 @@ func init() {
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 type fakeMgr struct {
-	caches map[string]types.Cache
+	caches     map[string]types.Cache
+	procs      map[string]types.Processor
+	ratelimits map[string]types.RateLimit
 }
 
 func (f *fakeMgr) RegisterEndpoint(path, desc string, h http.HandlerFunc) {
@@ -57,6 +59,24 @@ func (f *fakeMgr) GetCache(name string) (types.Cache, error) {
 func (f *fakeMgr) GetCondition(name string) (types.Condition, error) {
 	return nil, types.ErrConditionNotFound
 }
+func (f *fakeMgr) GetInput(name string) (types.Input, error) {
+	return nil, types.ErrInputNotFound
+}
+func (f *fakeMgr) GetOutput(name string) (types.OutputWriter, error) {
+	return nil, types.ErrOutputNotFound
+}
+func (f *fakeMgr) GetProcessor(name string) (types.Processor, error) {
+	if p, exists := f.procs[name]; exists {
+		return p, nil
+	}
+	return nil, types.ErrProcessorNotFound
+}
+func (f *fakeMgr) GetRateLimit(name string) (types.RateLimit, error) {
+	if r, exists := f.ratelimits[name]; exists {
+		return r, nil
+	}
+	return nil, types.ErrRateLimitNotFound
+}
 
 func TestDedupe(t *testing.T) {
 	rndText1 := randStringRunes(20)
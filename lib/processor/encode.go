@@ -0,0 +1,233 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/ascii85"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime/quotedprintable"
+	"strings"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["encode"] = TypeSpec{
+		constructor: NewEncode,
+		description: `
+Encodes parts of a message according to the selected scheme. Supported schemes
+are: base64, base64url, base64raw, base64rawurl, hex, ascii85 and
+quoted_printable. The raw base64 schemes omit padding characters. If the list
+of target parts is empty the encoding will be applied to all message parts.
+
+If a path is set then the part is parsed as a JSON document and only the string
+value found at the path is encoded, where the path is a dot separated sequence
+of object keys such as 'foo.bar'. Otherwise the entire contents of the part is
+encoded.
+
+Part indexes can be negative, and if so the part will be selected from the end
+counting backwards starting from -1. E.g. if index = -1 then the selected part
+will be the last part of the message, if index = -2 then the part before the
+last element with be selected, and so on.
+
+Parts that fail to encode are left unchanged and flagged as having failed,
+failed parts can be handled using the methods outlined in the
+[error handling docs](../error_handling.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// EncodeConfig contains any configuration for the Encode processor.
+type EncodeConfig struct {
+	Scheme string `json:"scheme" yaml:"scheme"`
+	Path   string `json:"path" yaml:"path"`
+	Parts  []int  `json:"parts" yaml:"parts"`
+}
+
+// NewEncodeConfig returns a EncodeConfig with default values.
+func NewEncodeConfig() EncodeConfig {
+	return EncodeConfig{
+		Scheme: "base64",
+		Path:   "",
+		Parts:  []int{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type encodeFunc func(bytes []byte) ([]byte, error)
+
+func base64Encoder(enc *base64.Encoding) encodeFunc {
+	return func(b []byte) ([]byte, error) {
+		e := make([]byte, enc.EncodedLen(len(b)))
+		enc.Encode(e, b)
+		return e, nil
+	}
+}
+
+func hexEncode(b []byte) ([]byte, error) {
+	e := make([]byte, hex.EncodedLen(len(b)))
+	hex.Encode(e, b)
+	return e, nil
+}
+
+func ascii85Encode(b []byte) ([]byte, error) {
+	e := make([]byte, ascii85.MaxEncodedLen(len(b)))
+	n := ascii85.Encode(e, b)
+	return e[:n], nil
+}
+
+func quotedPrintableEncode(b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	qw := quotedprintable.NewWriter(buf)
+	if _, err := qw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := qw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func strToEncoder(str string) (encodeFunc, error) {
+	switch str {
+	case "base64":
+		return base64Encoder(base64.StdEncoding), nil
+	case "base64url":
+		return base64Encoder(base64.URLEncoding), nil
+	case "base64raw":
+		return base64Encoder(base64.RawStdEncoding), nil
+	case "base64rawurl":
+		return base64Encoder(base64.RawURLEncoding), nil
+	case "hex":
+		return hexEncode, nil
+	case "ascii85":
+		return ascii85Encode, nil
+	case "quoted_printable":
+		return quotedPrintableEncode, nil
+	}
+	return nil, fmt.Errorf("encode scheme not recognised: %v", str)
+}
+
+//------------------------------------------------------------------------------
+
+// Encode is a processor that can selectively encode parts of a message with a
+// chosen scheme.
+type Encode struct {
+	conf EncodeConfig
+	fn   encodeFunc
+	path []string
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount   metrics.StatCounter
+	mSucc    metrics.StatCounter
+	mErr     metrics.StatCounter
+	mSkipped metrics.StatCounter
+	mSent    metrics.StatCounter
+}
+
+// NewEncode returns a Encode processor.
+func NewEncode(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	enc, err := strToEncoder(conf.Encode.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	var path []string
+	if len(conf.Encode.Path) > 0 {
+		path = strings.Split(conf.Encode.Path, ".")
+	}
+	return &Encode{
+		conf:  conf.Encode,
+		fn:    enc,
+		path:  path,
+		log:   log.NewModule(".processor.encode"),
+		stats: stats,
+
+		mCount:   stats.GetCounter("processor.encode.count"),
+		mSucc:    stats.GetCounter("processor.encode.success"),
+		mErr:     stats.GetCounter("processor.encode.error"),
+		mSkipped: stats.GetCounter("processor.encode.skipped"),
+		mSent:    stats.GetCounter("processor.encode.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage takes a message, attempts to encode parts of the message and
+// returns the result.
+func (e *Encode) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	e.mCount.Incr(1)
+
+	newMsg := types.NewMessage(nil)
+	lParts := msg.Len()
+
+	noParts := len(e.conf.Parts) == 0
+	for i, part := range msg.GetAll() {
+		isTarget := noParts
+		if !isTarget {
+			nI := i - lParts
+			for _, t := range e.conf.Parts {
+				if t == nI || t == i {
+					isTarget = true
+					break
+				}
+			}
+		}
+		if !isTarget {
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(part))
+			continue
+		}
+		newPart, err := applyAtJSONPath(e.path, part, e.fn)
+		if err == nil {
+			e.mSucc.Incr(1)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(newPart))
+		} else {
+			e.log.Debugf("Failed to encode message part: %v\n", err)
+			e.mErr.Incr(1)
+			index := newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), index)
+			FlagErr(newMsg, index, err)
+		}
+	}
+
+	if newMsg.Len() == 0 {
+		e.mSkipped.Incr(1)
+		return nil, types.NewSimpleResponse(nil)
+	}
+
+	e.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestEncodeBadAlgo(t *testing.T) {
+	conf := NewConfig()
+	conf.Encode.Scheme = "does not exist"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	_, err := NewEncode(conf, nil, testLog, metrics.DudType{})
+	if err == nil {
+		t.Error("Expected error from bad algo")
+	}
+}
+
+func TestEncodeSchemes(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	input := [][]byte{
+		[]byte("hello world?"),
+		[]byte("caf\xc3\xa9 = 1"),
+	}
+
+	tests := map[string][][]byte{
+		"base64": {
+			[]byte("aGVsbG8gd29ybGQ/"),
+			[]byte("Y2Fmw6kgPSAx"),
+		},
+		"base64url": {
+			[]byte("aGVsbG8gd29ybGQ_"),
+			[]byte("Y2Fmw6kgPSAx"),
+		},
+		"base64raw": {
+			[]byte("aGVsbG8gd29ybGQ/"),
+			[]byte("Y2Fmw6kgPSAx"),
+		},
+		"hex": {
+			[]byte("68656c6c6f20776f726c643f"),
+			[]byte("636166c3a9203d2031"),
+		},
+		"ascii85": {
+			[]byte("BOu!rD]j7BEbo8N"),
+			[]byte("@prueW?=>H0`"),
+		},
+		"quoted_printable": {
+			[]byte("hello world?"),
+			[]byte("caf=C3=A9 =3D 1"),
+		},
+	}
+
+	for scheme, exp := range tests {
+		conf := NewConfig()
+		conf.Encode.Scheme = scheme
+
+		proc, err := NewEncode(conf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatalf("%v: %v", scheme, err)
+		}
+
+		msgs, res := proc.ProcessMessage(types.NewMessage(input))
+		if len(msgs) != 1 {
+			t.Fatalf("%v: Encode failed", scheme)
+		} else if res != nil {
+			t.Errorf("%v: Expected nil response: %v", scheme, res)
+		}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("%v: Unexpected output: %s != %s", scheme, act, exp)
+		}
+	}
+}
+
+func TestEncodeJSONPath(t *testing.T) {
+	conf := NewConfig()
+	conf.Encode.Scheme = "base64"
+	conf.Encode.Path = "foo.bar"
+	conf.Encode.Parts = []int{-1}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	proc, err := NewEncode(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"foo":{"bar":"hello world"}}`),
+		[]byte(`{"foo":{"bar":"hello world"},"baz":5}`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Encode failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	exp := [][]byte{
+		[]byte(`{"foo":{"bar":"hello world"}}`),
+		[]byte(`{"baz":5,"foo":{"bar":"aGVsbG8gd29ybGQ="}}`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+
+	input := [][]byte{
+		[]byte(`not json`),
+		[]byte(`{"foo":{"bar":5}}`),
+		[]byte(`{"foo":{}}`),
+	}
+	conf.Encode.Parts = []int{}
+	if proc, err = NewEncode(conf, nil, testLog, metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgs, _ = proc.ProcessMessage(types.NewMessage(input)); len(msgs) != 1 {
+		t.Fatal("Encode failed")
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(input, act) {
+		t.Errorf("Unexpected output: %s != %s", act, input)
+	}
+	for i := range input {
+		if !HasFailed(msgs[0], i) {
+			t.Errorf("Expected part %v to be flagged as failed", i)
+		}
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	conf := NewConfig()
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewEncode(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{}))
+	if len(msgs) > 0 {
+		t.Error("Expected failure with zero part message")
+	}
+	if res == nil {
+		t.Error("Expected non-nil response")
+	}
+}
@@ -21,6 +21,7 @@
 package processor
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Jeffail/benthos/lib/metrics"
@@ -50,7 +51,11 @@ parts.
 Part indexes can be negative, and if so the part will be selected from the end
 counting backwards starting from -1. E.g. if part = -1 then the selected part
 will be the last part of the message, if part = -2 then the part before the
-last element with be selected, and so on.`,
+last element with be selected, and so on.
+
+If a part fails to be processed it is left unchanged and flagged as having
+failed, failed parts can be handled using the methods outlined in the
+[error handling docs](../error_handling.md).`,
 	}
 }
 
@@ -137,6 +142,9 @@ func NewGrok(
 
 //------------------------------------------------------------------------------
 
+// errNoGrokMatches is flagged on message parts that did not match any pattern.
+var errNoGrokMatches = errors.New("no grok pattern matches found")
+
 // ProcessMessage parses message parts as grok patterns.
 func (g *Grok) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
 	g.mCount.Incr(1)
@@ -169,12 +177,14 @@ func (g *Grok) ProcessMessage(msg types.Message) ([]types.Message, types.Respons
 		if len(values) == 0 {
 			g.mErrGrok.Incr(1)
 			g.log.Debugf("No matches found for payload: %s\n", body)
+			FlagErr(newMsg, index, errNoGrokMatches)
 			continue
 		}
 
 		if err := newMsg.SetJSON(index, values); err != nil {
 			g.mErrJSONS.Incr(1)
 			g.log.Debugf("Failed to convert grok result into json: %v\n", err)
+			FlagErr(newMsg, index, err)
 		} else {
 			g.mSucc.Incr(1)
 		}
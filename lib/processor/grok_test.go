@@ -68,6 +68,48 @@ func TestGrokAllParts(t *testing.T) {
 	}
 }
 
+func TestGrokFailedParts(t *testing.T) {
+	conf := NewConfig()
+	conf.Grok.Parts = []int{}
+	conf.Grok.Patterns = []string{
+		"%{WORD:first},%{INT:second:int}",
+	}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	gSet, err := NewGrok(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := types.NewMessage([][]byte{
+		[]byte(`foo,0`),
+		[]byte(`nope`),
+	})
+	msgs, res := gSet.ProcessMessage(msgIn)
+	if len(msgs) != 1 {
+		t.Fatal("Wrong count of messages")
+	}
+	if res != nil {
+		t.Fatal("Non-nil result")
+	}
+
+	exp := [][]byte{
+		[]byte(`{"first":"foo","second":0}`),
+		[]byte(`nope`),
+	}
+	act := msgs[0].GetAll()
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong output from grok: %s != %s", act, exp)
+	}
+	if HasFailed(msgs[0], 0) {
+		t.Error("Expected part 0 not to be flagged as failed")
+	}
+	if !HasFailed(msgs[0], 1) {
+		t.Error("Expected part 1 to be flagged as failed")
+	}
+}
+
 func TestGrok(t *testing.T) {
 	tLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
 	tStats := metrics.DudType{}
@@ -0,0 +1,316 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/http/client"
+	"github.com/Jeffail/benthos/lib/util/text"
+	"github.com/Jeffail/gabs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["http"] = TypeSpec{
+		constructor: NewHTTP,
+		description: `
+Sends each message part as an HTTP request to a URL and uses the response to
+either replace the contents of the part or, when ` + "`result_path`" + ` is
+set, to enrich it. The fields of ` + "`request`" + ` are the same as those of
+the [` + "`http_client`" + ` output](../outputs/README.md#http_client),
+including authentication and retries with backoff.
+
+The body of each request is the contents of the part, unless
+` + "`payload`" + ` is set, in which case the body is the result of
+` + "`payload`" + ` with any
+[function interpolations](../config_interpolation.md#functions) resolved against
+the part. Function interpolations within the URL are resolved against the
+request body.
+
+When ` + "`result_path`" + ` is set the response is parsed as JSON and is set
+at that JSON dot path within the part, which must also be JSON. For example,
+with the following config:
+
+` + "``` yaml" + `
+http:
+  request:
+    url: http://geo.example.com/lookup
+    verb: POST
+  payload: ${!json_field:ip_address}
+  result_path: location
+` + "```" + `
+
+A part ` + "`{\"ip_address\":\"1.2.3.4\"}`" + ` is enriched with the
+response of the lookup service, resulting in a part such as
+` + "`{\"ip_address\":\"1.2.3.4\",\"location\":{\"country\":\"US\"}}`" + `.
+
+Requests for the parts of a message are sent in parallel, with at most
+` + "`max_parallel`" + ` requests in flight at a time. If a request fails
+after all retries, or the response cannot be used, then the part is left
+unchanged and is flagged as having failed. The field ` + "`parts`" + ` selects
+the indexes of parts that are sent, where an empty array selects all parts.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// HTTPConfig contains configuration for the HTTP processor.
+type HTTPConfig struct {
+	Client      client.Config `json:"request" yaml:"request"`
+	Payload     string        `json:"payload" yaml:"payload"`
+	ResultPath  string        `json:"result_path" yaml:"result_path"`
+	Parts       []int         `json:"parts" yaml:"parts"`
+	MaxParallel int           `json:"max_parallel" yaml:"max_parallel"`
+}
+
+// NewHTTPConfig returns a HTTPConfig with default values.
+func NewHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		Client:      client.NewConfig(),
+		Payload:     "",
+		ResultPath:  "",
+		Parts:       []int{},
+		MaxParallel: 10,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// HTTP is a processor that sends message parts as HTTP requests and uses the
+// responses to replace or enrich the parts.
+type HTTP struct {
+	log   log.Modular
+	stats metrics.Type
+
+	client      *client.Type
+	payload     []byte
+	interpolate bool
+	resultPath  []string
+	parts       []int
+	maxParallel int
+
+	running   int32
+	closeChan chan struct{}
+
+	mCount    metrics.StatCounter
+	mErr      metrics.StatCounter
+	mErrHTTP  metrics.StatCounter
+	mErrJSONP metrics.StatCounter
+	mErrJSONS metrics.StatCounter
+	mSucc     metrics.StatCounter
+	mSent     metrics.StatCounter
+}
+
+// NewHTTP returns a HTTP processor.
+func NewHTTP(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	if conf.HTTP.MaxParallel <= 0 {
+		return nil, errors.New("max_parallel must be greater than zero")
+	}
+	closeChan := make(chan struct{})
+	c, err := client.New(
+		conf.HTTP.Client,
+		client.OptSetLogger(log.NewModule(".processor.http")),
+		client.OptSetManager(mgr),
+		client.OptSetCloseChan(closeChan),
+	)
+	if err != nil {
+		return nil, err
+	}
+	h := &HTTP{
+		log:   log.NewModule(".processor.http"),
+		stats: stats,
+
+		client:      c,
+		payload:     []byte(conf.HTTP.Payload),
+		parts:       conf.HTTP.Parts,
+		maxParallel: conf.HTTP.MaxParallel,
+
+		running:   1,
+		closeChan: closeChan,
+
+		mCount:    stats.GetCounter("processor.http.count"),
+		mErr:      stats.GetCounter("processor.http.error"),
+		mErrHTTP:  stats.GetCounter("processor.http.error.request"),
+		mErrJSONP: stats.GetCounter("processor.http.error.json_parse"),
+		mErrJSONS: stats.GetCounter("processor.http.error.json_set"),
+		mSucc:     stats.GetCounter("processor.http.success"),
+		mSent:     stats.GetCounter("processor.http.sent"),
+	}
+	h.interpolate = text.ContainsFunctionVariables(h.payload)
+	if len(conf.HTTP.ResultPath) > 0 && conf.HTTP.ResultPath != "." {
+		h.resultPath = strings.Split(conf.HTTP.ResultPath, ".")
+	}
+	return h, nil
+}
+
+//------------------------------------------------------------------------------
+
+// request sends a single part message and returns the resulting part.
+func (h *HTTP) request(part types.Message) (types.Message, error) {
+	reqMsg := isolatePart(part, 0)
+	if len(h.payload) > 0 {
+		payload := h.payload
+		if h.interpolate {
+			payload = text.ReplaceFunctionVariablesFor(part, payload)
+		}
+		reqMsg.Set(0, payload)
+	}
+
+	res, err := h.client.Send(reqMsg)
+	if err != nil {
+		h.mErrHTTP.Incr(1)
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		h.mErrHTTP.Incr(1)
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		h.mErrHTTP.Incr(1)
+		return nil, types.ErrUnexpectedHTTPRes{Code: res.StatusCode, S: res.Status}
+	}
+
+	resultMsg := isolatePart(part, 0)
+	if h.resultPath == nil {
+		resultMsg.Set(0, body)
+		return resultMsg, nil
+	}
+
+	var resObj interface{}
+	if err = json.Unmarshal(body, &resObj); err != nil {
+		h.mErrJSONP.Incr(1)
+		return nil, fmt.Errorf("failed to parse response as JSON: %v", err)
+	}
+	jObj, err := resultMsg.GetJSON(0)
+	if err != nil {
+		h.mErrJSONP.Incr(1)
+		return nil, fmt.Errorf("failed to parse part as JSON: %v", err)
+	}
+	gObj, _ := gabs.Consume(jObj)
+	if _, err = gObj.Set(resObj, h.resultPath...); err != nil {
+		h.mErrJSONS.Incr(1)
+		return nil, err
+	}
+	if err = resultMsg.SetJSON(0, gObj.Data()); err != nil {
+		h.mErrJSONS.Incr(1)
+		return nil, err
+	}
+	return resultMsg, nil
+}
+
+// ProcessMessage sends each targeted part of a message as an HTTP request in
+// parallel and replaces or enriches the parts with the responses.
+func (h *HTTP) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	h.mCount.Incr(1)
+
+	// Parts are copied before requests are sent in parallel as reading the
+	// metadata of a message is not thread safe.
+	results := make([]types.Message, msg.Len())
+	for i := range results {
+		results[i] = isolatePart(msg, i)
+	}
+
+	targets := map[int]struct{}{}
+	for _, index := range h.parts {
+		if index < 0 {
+			index = msg.Len() + index
+		}
+		if index >= 0 && index < msg.Len() {
+			targets[index] = struct{}{}
+		}
+	}
+	if len(h.parts) == 0 {
+		for i := range results {
+			targets[i] = struct{}{}
+		}
+	}
+
+	indexChan := make(chan int, len(targets))
+	for index := range targets {
+		indexChan <- index
+	}
+	close(indexChan)
+
+	workers := h.maxParallel
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				result, err := h.request(results[i])
+				if err != nil {
+					h.mErr.Incr(1)
+					h.log.Debugf("Failed to enrich part: %v\n", err)
+					FlagErr(results[i], 0, err)
+					continue
+				}
+				h.mSucc.Incr(1)
+				results[i] = result
+			}
+		}()
+	}
+	wg.Wait()
+
+	newMsg := types.NewMessage(nil)
+	appendParts(newMsg, results)
+
+	h.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down the processor, interrupting any requests that are
+// waiting to be retried or for a rate limit.
+func (h *HTTP) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&h.running, 1, 0) {
+		close(h.closeChan)
+	}
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (h *HTTP) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,225 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestHTTPReplace(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(b) == "fail" {
+			http.Error(w, "nope", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(strings.ToUpper(string(b)) + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.HTTP.Client.URL = ts.URL + "/${!metadata:path}"
+	conf.HTTP.Client.NumRetries = 0
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewHTTP(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := types.NewMessage([][]byte{[]byte("foo"), []byte("fail"), []byte("bar")})
+	input.GetMetadata(0).Set("path", "first")
+	input.GetMetadata(2).Set("path", "third")
+
+	msgs, res := proc.ProcessMessage(input)
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+
+	exp := [][]byte{[]byte("FOO/first"), []byte("fail"), []byte("BAR/third")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []bool{false, true, false} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+	if exp, act := "third", msgs[0].GetMetadata(2).Get("path"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+}
+
+func TestHTTPEnrich(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"country":"` + string(b) + `"}`))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.HTTP.Client.URL = ts.URL
+	conf.HTTP.Payload = "${!json_field:ip}"
+	conf.HTTP.ResultPath = "geo.location"
+	conf.HTTP.Parts = []int{0, -1}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewHTTP(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"ip":"a"}`),
+		[]byte(`{"ip":"b"}`),
+		[]byte(`not json`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+
+	exp := [][]byte{
+		[]byte(`{"geo":{"location":{"country":"a"}},"ip":"a"}`),
+		[]byte(`{"ip":"b"}`),
+		[]byte(`not json`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []bool{false, false, true} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestHTTPMaxParallel(t *testing.T) {
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		<-time.After(time.Millisecond * 10)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.HTTP.Client.URL = ts.URL
+	conf.HTTP.Client.NumRetries = 0
+	conf.HTTP.MaxParallel = 3
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewHTTP(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make([][]byte, 20)
+	for i := range parts {
+		parts[i] = []byte("foo")
+	}
+	msgs, _ := proc.ProcessMessage(types.NewMessage(parts))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	for i, part := range msgs[0].GetAll() {
+		if string(part) != "ok" || HasFailed(msgs[0], i) {
+			t.Errorf("Wrong result at index %v: %s", i, part)
+		}
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 3 {
+		t.Errorf("Too many requests in flight: %v", max)
+	}
+
+	conf.HTTP.MaxParallel = 0
+	if _, err = NewHTTP(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from zero max_parallel")
+	}
+}
+
+func TestHTTPCloseDuringRetries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.HTTP.Client.URL = ts.URL
+	conf.HTTP.Client.NumRetries = 3
+	conf.HTTP.Client.RetryMS = 10000
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewHTTP(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resChan := make(chan []types.Message)
+	go func() {
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+		resChan <- msgs
+	}()
+
+	<-time.After(time.Millisecond * 100)
+	proc.(types.Closable).CloseAsync()
+
+	select {
+	case msgs := <-resChan:
+		if len(msgs) != 1 {
+			t.Fatalf("Wrong count of messages: %v", len(msgs))
+		}
+		if !HasFailed(msgs[0], 0) {
+			t.Error("Expected interrupted part to be flagged as failed")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for retries to be interrupted")
+	}
+	if err = proc.(types.Closable).WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -75,7 +75,11 @@ messages with boolean queries please instead use the
 Part indexes can be negative, and if so the part will be selected from the end
 counting backwards starting from -1. E.g. if part = -1 then the selected part
 will be the last part of the message, if part = -2 then the part before the
-last element with be selected, and so on.`,
+last element with be selected, and so on.
+
+If a part fails to be processed it is left unchanged and flagged as having
+failed, failed parts can be handled using the methods outlined in the
+[error handling docs](../error_handling.md).`,
 	}
 }
 
@@ -161,6 +165,7 @@ func (p *JMESPath) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 		if err != nil {
 			p.mErrJSONP.Incr(1)
 			p.log.Debugf("Failed to parse part into json: %v\n", err)
+			FlagErr(newMsg, index, err)
 			continue
 		}
 
@@ -168,12 +173,14 @@ func (p *JMESPath) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 		if result, err = p.query.Search(jsonPart); err != nil {
 			p.mErrJMES.Incr(1)
 			p.log.Debugf("Failed to search json: %v\n", err)
+			FlagErr(newMsg, index, err)
 			continue
 		}
 
 		if err = newMsg.SetJSON(index, result); err != nil {
 			p.mErrJSONS.Incr(1)
 			p.log.Debugf("Failed to convert jmespath result into part: %v\n", err)
+			FlagErr(newMsg, index, err)
 		} else {
 			p.mSucc.Incr(1)
 		}
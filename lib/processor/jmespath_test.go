@@ -84,6 +84,12 @@ func TestJMESPathValidation(t *testing.T) {
 	if exp, act := "this is bad json", string(msgs[0].GetAll()[0]); exp != act {
 		t.Errorf("Wrong output from bad json: %v != %v", act, exp)
 	}
+	if !HasFailed(msgs[0], 0) {
+		t.Error("Expected bad json to be flagged as failed")
+	}
+	if HasFailed(msgIn, 0) {
+		t.Error("Original message was flagged as failed")
+	}
 
 	conf.JMESPath.Parts = []int{5}
 
@@ -52,6 +52,10 @@ before the last element with be selected, and so on.
 This processor will interpolate functions within the 'value' field, you can find
 a list of functions [here](../config_interpolation.md#functions).
 
+If a part cannot be parsed or the operation fails the part is left unchanged and
+flagged as having failed, failed parts can be handled using the methods outlined
+in the [error handling docs](../error_handling.md).
+
 ### Operations
 
 #### ` + "`set`" + `
@@ -357,7 +361,7 @@ func (p *JSON) ProcessMessage(msg types.Message) ([]types.Message, types.Respons
 
 	valueBytes := p.valueBytes
 	if p.interpolate {
-		valueBytes = text.ReplaceFunctionVariables(valueBytes)
+		valueBytes = text.ReplaceFunctionVariablesFor(msg, valueBytes)
 	}
 
 	targetParts := p.parts
@@ -375,12 +379,14 @@ func (p *JSON) ProcessMessage(msg types.Message) ([]types.Message, types.Respons
 		if err != nil {
 			p.mErrJSONP.Incr(1)
 			p.log.Debugf("Failed to parse part into json: %v\n", err)
+			FlagErr(newMsg, index, err)
 			continue
 		}
 
 		if data, err = p.operator(jsonPart, valueBytes); err != nil {
 			p.mErr.Incr(1)
 			p.log.Debugf("Failed to apply operator: %v\n", err)
+			FlagErr(newMsg, index, err)
 			continue
 		}
 
@@ -393,6 +399,7 @@ func (p *JSON) ProcessMessage(msg types.Message) ([]types.Message, types.Respons
 			if err = newMsg.SetJSON(index, data); err != nil {
 				p.mErrJSONS.Incr(1)
 				p.log.Debugf("Failed to convert json into part: %v\n", err)
+				FlagErr(newMsg, index, err)
 			}
 		}
 
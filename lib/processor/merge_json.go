@@ -36,7 +36,7 @@ func init() {
 Parses selected message parts as JSON blobs, attempts to merge them into one
 single JSON value and then writes it to a new message part at the end of the
 message. Merged parts are removed unless ` + "`retain_parts`" + ` is set to
-true.
+true. The metadata of the merged parts is also merged into the new part.
 
 If the list of target parts is empty the processor will be applied to all
 message parts. Part indexes can be negative, and if so the part will be selected
@@ -107,6 +107,7 @@ func (p *MergeJSON) ProcessMessage(msg types.Message) ([]types.Message, types.Re
 	p.mCount.Incr(1)
 
 	newPart := gabs.New()
+	newMeta := types.NewMetadata(nil)
 	mergeFunc := func(index int) {
 		jsonPart, err := msg.GetJSON(index)
 		if err != nil {
@@ -123,6 +124,10 @@ func (p *MergeJSON) ProcessMessage(msg types.Message) ([]types.Message, types.Re
 		}
 
 		newPart.Merge(gPart)
+		msg.GetMetadata(index).Iter(func(k, v string) error {
+			newMeta.Set(k, v)
+			return nil
+		})
 	}
 
 	var newMsg types.Message
@@ -151,13 +156,14 @@ func (p *MergeJSON) ProcessMessage(msg types.Message) ([]types.Message, types.Re
 			if _, isTarget := targetParts[i]; isTarget {
 				mergeFunc(i)
 			} else if !p.retain {
-				msg.Append(b)
+				newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(b))
 			}
 			return nil
 		})
 	}
 
 	i := newMsg.Append([]byte{})
+	newMsg.SetMetadata(newMeta, i)
 	if err := newMsg.SetJSON(i, newPart.Data()); err != nil {
 		p.mErrJSONS.Incr(1)
 		p.log.Debugf("Failed to marshal merged part into json: %v\n", err)
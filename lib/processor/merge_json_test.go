@@ -186,3 +186,50 @@ func TestMergeJSONRetention(t *testing.T) {
 		t.Errorf("Wrong result: %s != %s", act, exp)
 	}
 }
+
+func TestMergeJSONMetadata(t *testing.T) {
+	tLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	tStats := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.MergeJSON.Parts = []int{0, 1}
+	conf.MergeJSON.RetainParts = false
+
+	jMrg, err := NewMergeJSON(conf, nil, tLog, tStats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inMsg := types.NewMessage([][]byte{
+		[]byte(`{"foo":1}`),
+		[]byte(`{"bar":2}`),
+		[]byte(`not merged`),
+	})
+	inMsg.GetMetadata(0).Set("foo", "1")
+	inMsg.GetMetadata(1).Set("bar", "2")
+	inMsg.GetMetadata(2).Set("baz", "3")
+
+	msgs, _ := jMrg.ProcessMessage(inMsg)
+	if len(msgs) != 1 {
+		t.Fatal("Test message did not process")
+	}
+
+	if exp, act := [][]byte{
+		[]byte(`not merged`),
+		[]byte(`{"bar":2,"foo":1}`),
+	}, msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := "3", msgs[0].GetMetadata(0).Get("baz"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if exp, act := "1", msgs[0].GetMetadata(1).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if exp, act := "2", msgs[0].GetMetadata(1).Get("bar"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if exp, act := 3, inMsg.Len(); exp != act {
+		t.Errorf("Input message was modified: %v != %v", act, exp)
+	}
+}
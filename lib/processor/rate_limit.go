@@ -0,0 +1,151 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/ratelimit"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["rate_limit"] = TypeSpec{
+		constructor: NewRateLimit,
+		description: `
+Throttles the throughput of a pipeline according to a rate limit resource.
+Each message passing through the processor blocks until the rate limit allows
+it, and since the rate limit is a resource the limit is shared by all
+processors, and all streams, that refer to it.
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - type: rate_limit
+    rate_limit:
+      resource: foobar
+resources:
+  rate_limits:
+    foobar:
+      type: local
+      local:
+        count: 100
+        interval: 1s
+` + "```" + `
+
+Rate limits should be configured as a resource, for more information check out
+the [documentation here](../rate_limits).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RateLimitConfig contains configuration fields for the RateLimit processor.
+type RateLimitConfig struct {
+	Resource string `json:"resource" yaml:"resource"`
+}
+
+// NewRateLimitConfig returns a RateLimitConfig with default values.
+func NewRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Resource: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RateLimit is a processor that blocks each message until a rate limit
+// resource allows it to continue.
+type RateLimit struct {
+	rl  types.RateLimit
+	log log.Modular
+
+	running   int32
+	closeChan chan struct{}
+
+	mCount   metrics.StatCounter
+	mLimited metrics.StatCounter
+	mErr     metrics.StatCounter
+	mSent    metrics.StatCounter
+}
+
+// NewRateLimit returns a RateLimit processor.
+func NewRateLimit(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	rl, err := mgr.GetRateLimit(conf.RateLimit.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.RateLimit.Resource, err)
+	}
+	return &RateLimit{
+		rl:  rl,
+		log: log.NewModule(".processor.rate_limit"),
+
+		running:   1,
+		closeChan: make(chan struct{}),
+
+		mCount:   stats.GetCounter("processor.rate_limit.count"),
+		mLimited: stats.GetCounter("processor.rate_limit.limited"),
+		mErr:     stats.GetCounter("processor.rate_limit.error"),
+		mSent:    stats.GetCounter("processor.rate_limit.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage blocks until the rate limit allows the message to continue.
+func (r *RateLimit) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	r.mCount.Incr(1)
+
+	limited, err := ratelimit.Wait(r.rl, r.closeChan, r.log)
+	if limited {
+		r.mLimited.Incr(1)
+	}
+	if err != nil {
+		r.mErr.Incr(1)
+		return nil, types.NewSimpleResponse(err)
+	}
+
+	r.mSent.Incr(1)
+	msgs := [1]types.Message{msg}
+	return msgs[:], nil
+}
+
+// CloseAsync shuts down the processor, interrupting any messages waiting on the
+// rate limit.
+func (r *RateLimit) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&r.running, 1, 0) {
+		close(r.closeChan)
+	}
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (r *RateLimit) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
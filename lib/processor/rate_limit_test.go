@@ -0,0 +1,190 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+type fakeRateLimit struct {
+	sync.Mutex
+
+	accesses int
+	limited  int
+	period   time.Duration
+	errs     []error
+}
+
+func (r *fakeRateLimit) Access() (time.Duration, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.accesses++
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return 0, err
+	}
+	if r.limited > 0 {
+		r.limited--
+		return r.period, nil
+	}
+	return 0, nil
+}
+
+func TestRateLimitBadResource(t *testing.T) {
+	mgr := &fakeMgr{
+		ratelimits: map[string]types.RateLimit{},
+	}
+
+	conf := NewConfig()
+	conf.Type = "rate_limit"
+	conf.RateLimit.Resource = "foo"
+
+	if _, err := New(conf, mgr, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing resource")
+	}
+}
+
+func TestRateLimitBasic(t *testing.T) {
+	rl := &fakeRateLimit{
+		limited: 2,
+		period:  time.Millisecond * 10,
+	}
+	mgr := &fakeMgr{
+		ratelimits: map[string]types.RateLimit{
+			"foo": rl,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = "rate_limit"
+	conf.RateLimit.Resource = "foo"
+
+	proc, err := New(conf, mgr, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := types.NewMessage([][]byte{[]byte("foo")})
+
+	tStarted := time.Now()
+	msgs, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if since := time.Since(tStarted); since < time.Millisecond*20 {
+		t.Errorf("Message was not limited: %v", since)
+	}
+	if exp, act := 3, rl.accesses; exp != act {
+		t.Errorf("Wrong count of accesses: %v != %v", act, exp)
+	}
+	if exp, act := input.GetAll(), msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestRateLimitErroredOut(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode")
+	}
+
+	rl := &fakeRateLimit{
+		errs: []error{errors.New("foo")},
+	}
+	mgr := &fakeMgr{
+		ratelimits: map[string]types.RateLimit{
+			"foo": rl,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = "rate_limit"
+	conf.RateLimit.Resource = "foo"
+
+	proc, err := New(conf, mgr, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Errorf("Wrong count of messages: %v", len(msgs))
+	}
+	if exp, act := 2, rl.accesses; exp != act {
+		t.Errorf("Wrong count of accesses: %v != %v", act, exp)
+	}
+}
+
+func TestRateLimitClosed(t *testing.T) {
+	rl := &fakeRateLimit{
+		limited: 1,
+		period:  time.Hour,
+	}
+	mgr := &fakeMgr{
+		ratelimits: map[string]types.RateLimit{
+			"foo": rl,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = "rate_limit"
+	conf.RateLimit.Resource = "foo"
+
+	proc, err := New(conf, mgr, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resChan := make(chan types.Response)
+	go func() {
+		_, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+		resChan <- res
+	}()
+
+	<-time.After(time.Millisecond * 50)
+	closable := proc.(types.Closable)
+	closable.CloseAsync()
+	if err = closable.WaitForClose(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-resChan:
+		if res == nil || res.Error() != types.ErrTypeClosed {
+			t.Errorf("Expected closed error: %v", res)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for interrupted message")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["resource"] = TypeSpec{
+		constructor: NewResource,
+		description: `
+Resource is a processor type that runs a processor resource by its name. This
+processor allows you to run the same configured processor resource in multiple
+places.
+
+Resource processors also have the advantage of name based metrics and logging,
+as the metrics and logs of both the resource processor and the processor
+resource it runs are prefixed with ` + "`processor.resource.<name>`" + `. For
+example, the config:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - type: resource
+    resource: foobar
+resources:
+  processors:
+    foobar:
+      type: jmespath
+      jmespath:
+        query: foo
+` + "```" + `
+
+Is equivalent to:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - type: jmespath
+    jmespath:
+      query: foo
+` + "```" + `
+
+Since a processor resource is shared it can be executed by many pipelines at
+the same time, processors that hold state across messages such as
+` + "`batch`" + ` should therefore be used with care.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Resource is a processor that returns the result of a processor resource.
+type Resource struct {
+	mgr  types.Manager
+	name string
+	log  log.Modular
+
+	mCount metrics.StatCounter
+	mErr   metrics.StatCounter
+}
+
+// NewResource returns a resource processor.
+func NewResource(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	if _, err := mgr.GetProcessor(conf.Resource); err != nil {
+		return nil, fmt.Errorf("failed to obtain processor resource '%v': %v", conf.Resource, err)
+	}
+	return &Resource{
+		mgr:  mgr,
+		name: conf.Resource,
+		log:  log.NewModule(".processor.resource." + conf.Resource),
+
+		mCount: stats.GetCounter("processor.resource." + conf.Resource + ".count"),
+		mErr:   stats.GetCounter("processor.resource." + conf.Resource + ".error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies a processor resource to a message.
+func (r *Resource) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	r.mCount.Incr(1)
+
+	p, err := r.mgr.GetProcessor(r.name)
+	if err != nil {
+		r.mErr.Incr(1)
+		r.log.Debugf("Failed to obtain processor resource '%v': %v", r.name, err)
+		return nil, types.NewSimpleResponse(err)
+	}
+	return p.ProcessMessage(msg)
+}
+
+// FlushPeriod returns the flush period of the processor resource, or zero if
+// the resource does not buffer messages.
+func (r *Resource) FlushPeriod() time.Duration {
+	p, err := r.mgr.GetProcessor(r.name)
+	if err != nil {
+		return 0
+	}
+	if f, ok := p.(Flusher); ok {
+		return f.FlushPeriod()
+	}
+	return 0
+}
+
+// Flush returns any expired messages buffered by the processor resource.
+func (r *Resource) Flush() []types.Message {
+	p, err := r.mgr.GetProcessor(r.name)
+	if err != nil {
+		r.mErr.Incr(1)
+		r.log.Debugf("Failed to obtain processor resource '%v': %v", r.name, err)
+		return nil
+	}
+	if f, ok := p.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,157 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestResourceProc(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "insert_part"
+	conf.InsertPart.Content = "foo"
+
+	resProc, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &fakeMgr{
+		procs: map[string]types.Processor{
+			"foo": resProc,
+		},
+	}
+
+	nConf := NewConfig()
+	nConf.Type = "resource"
+	nConf.Resource = "foo"
+
+	p, err := New(nConf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := p.ProcessMessage(types.NewMessage([][]byte{[]byte("bar")}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if exp, act := [][]byte{[]byte("bar"), []byte("foo")}, msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestResourceMetricsNamed(t *testing.T) {
+	mgr := &fakeMgr{
+		procs: map[string]types.Processor{
+			"foo": nil,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = "resource"
+	conf.Resource = "foo"
+
+	stats := &fakeMetricPaths{}
+	if _, err := New(conf, mgr, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), stats); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"processor.resource.foo.count", "processor.resource.foo.error"}
+	if !reflect.DeepEqual(exp, stats.paths) {
+		t.Errorf("Wrong metric paths: %v != %v", stats.paths, exp)
+	}
+}
+
+func TestResourceFlush(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "batch"
+	conf.Batch.ByteSize = 1000
+	conf.Batch.PeriodMS = 10
+
+	resProc, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &fakeMgr{
+		procs: map[string]types.Processor{
+			"foo": resProc,
+		},
+	}
+
+	nConf := NewConfig()
+	nConf.Type = "resource"
+	nConf.Resource = "foo"
+
+	p, err := New(nConf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flusher, ok := p.(Flusher)
+	if !ok {
+		t.Fatal("Resource does not implement Flusher")
+	}
+	if exp, act := time.Millisecond*10, flusher.FlushPeriod(); exp != act {
+		t.Errorf("Wrong flush period: %v != %v", act, exp)
+	}
+
+	if msgs, _ := p.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")})); len(msgs) != 0 {
+		t.Fatalf("Expected no messages: %v", len(msgs))
+	}
+
+	<-time.After(time.Millisecond * 20)
+	msgs := flusher.Flush()
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of flushed messages: %v", len(msgs))
+	}
+	if exp, act := [][]byte{[]byte("foo")}, msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestResourceBadName(t *testing.T) {
+	mgr := &fakeMgr{
+		procs: map[string]types.Processor{},
+	}
+
+	conf := NewConfig()
+	conf.Type = "resource"
+	conf.Resource = "foo"
+
+	if _, err := NewResource(conf, mgr, log.New(os.Stdout, log.Config{LogLevel: "NONE"}), metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad resource")
+	}
+}
@@ -0,0 +1,506 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["script"] = TypeSpec{
+		constructor: NewScript,
+		description: `
+Executes a [Lua](https://www.lua.org/manual/5.1/) script against each message
+part. The script is either provided inline with the field ` + "`script`" + `
+or loaded from the path ` + "`file`" + `. Only the base, table, string and
+math standard libraries are available to scripts, and the base functions
+` + "`dofile`, `loadfile`, `print`, `getfenv`, `setfenv`, `module`" + ` and
+` + "`require`" + ` are removed.
+
+Scripts access the current part through the functions of the global table
+` + "`benthos`" + `:
+
+- ` + "`benthos.content()`" + ` returns the raw contents of the part.
+- ` + "`benthos.set_content(str)`" + ` replaces the raw contents of the part.
+- ` + "`benthos.json()`" + ` returns the contents of the part parsed as JSON.
+- ` + "`benthos.set_json(value)`" + ` replaces the contents of the part with a
+  value serialised as JSON.
+- ` + "`benthos.metadata(key)`" + ` returns the value of a metadata key of the
+  part.
+- ` + "`benthos.set_metadata(key, value)`" + ` sets a metadata key of the part.
+- ` + "`benthos.drop()`" + ` removes the part from the message.
+- ` + "`benthos.new_part(str)`" + ` adds a new part to the message after the
+  current part. New parts are given the metadata of the current part.
+- ` + "`benthos.part_index()`" + ` returns the index of the part.
+- ` + "`benthos.batch_size()`" + ` returns the number of parts in the message.
+
+Lua tables are serialised as JSON arrays when they have a sequence of integer
+keys starting at 1, and as JSON objects otherwise.
+
+For example, the following script sets the field ` + "`total`" + ` of each
+document to the sum of the prices of its items, and drops documents without
+items:
+
+` + "``` yaml" + `
+script:
+  script: |
+    local doc = benthos.json()
+    if doc.items == nil or #doc.items == 0 then
+      benthos.drop()
+      return
+    end
+    local total = 0
+    for _, item in ipairs(doc.items) do
+      total = total + item.price
+    end
+    doc.total = total
+    benthos.set_json(doc)
+` + "```" + `
+
+The field ` + "`parts`" + ` selects the indexes of parts that the script is
+executed against, where an empty array selects all parts. Other parts are left
+unchanged.
+
+If a script fails, or does not finish within ` + "`timeout_ms`" + `
+milliseconds, then any changes it made are discarded and the part is flagged as
+having failed.
+
+Each pipeline thread maintains its own script VM, which is reused for each part.
+The script is executed against each part with a fresh copy of the global
+variables and standard library tables, and therefore globals set while
+processing one part are not visible when processing the next.
+
+When ` + "`name`" + ` is set the metrics of the processor are prefixed with
+` + "`processor.script.<name>`" + ` rather than ` + "`processor.script`" + `,
+which allows the metrics of different scripts to be told apart.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ScriptConfig contains configuration for the Script processor.
+type ScriptConfig struct {
+	Name      string `json:"name" yaml:"name"`
+	Script    string `json:"script" yaml:"script"`
+	File      string `json:"file" yaml:"file"`
+	Parts     []int  `json:"parts" yaml:"parts"`
+	TimeoutMS int    `json:"timeout_ms" yaml:"timeout_ms"`
+}
+
+// NewScriptConfig returns a ScriptConfig with default values.
+func NewScriptConfig() ScriptConfig {
+	return ScriptConfig{
+		Name:      "",
+		Script:    "",
+		File:      "",
+		Parts:     []int{},
+		TimeoutMS: 1000,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// errScriptTimeout is the error used to flag parts where the script did not
+// finish within the timeout.
+var errScriptTimeout = errors.New("script execution timed out")
+
+// scriptBaseRemoved lists functions of the base library that are removed
+// from script VMs, as they either access the host or allow scripts to reach
+// the globals shared between parts.
+var scriptBaseRemoved = []string{
+	"dofile", "loadfile", "print", "_printregs",
+	"getfenv", "setfenv", "module", "require",
+}
+
+// scriptVM is a Lua state with the benthos API loaded and a compiled script.
+type scriptVM struct {
+	state   *lua.LState
+	globals *lua.LTable
+	proto   *lua.FunctionProto
+
+	part      types.Message
+	index     int
+	batchSize int
+	dropped   bool
+}
+
+func newScriptVM(proto *lua.FunctionProto) *scriptVM {
+	vm := &scriptVM{
+		state: lua.NewState(lua.Options{SkipOpenLibs: true}),
+	}
+	L := vm.state
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range scriptBaseRemoved {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// Prevent scripts from reaching the shared string library through the
+	// metatable of strings.
+	if mt, ok := L.GetMetatable(lua.LString("")).(*lua.LTable); ok {
+		mt.RawSetString("__metatable", lua.LFalse)
+	}
+
+	api := L.NewTable()
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		"content": func(L *lua.LState) int {
+			L.Push(lua.LString(vm.part.Get(0)))
+			return 1
+		},
+		"set_content": func(L *lua.LState) int {
+			vm.part.Set(0, []byte(L.CheckString(1)))
+			return 0
+		},
+		"json": func(L *lua.LState) int {
+			jObj, err := vm.part.GetJSON(0)
+			if err != nil {
+				L.RaiseError("failed to parse part as JSON: %v", err)
+				return 0
+			}
+			L.Push(jsonToLua(L, jObj))
+			return 1
+		},
+		"set_json": func(L *lua.LState) int {
+			if err := vm.part.SetJSON(0, luaToJSON(L.CheckAny(1))); err != nil {
+				L.RaiseError("failed to serialise JSON: %v", err)
+			}
+			return 0
+		},
+		"metadata": func(L *lua.LState) int {
+			L.Push(lua.LString(vm.part.GetMetadata(0).Get(L.CheckString(1))))
+			return 1
+		},
+		"set_metadata": func(L *lua.LState) int {
+			vm.part.GetMetadata(0).Set(L.CheckString(1), L.CheckString(2))
+			return 0
+		},
+		"drop": func(L *lua.LState) int {
+			vm.dropped = true
+			return 0
+		},
+		"new_part": func(L *lua.LState) int {
+			vm.part.Append([]byte(L.CheckString(1)))
+			return 0
+		},
+		"part_index": func(L *lua.LState) int {
+			L.Push(lua.LNumber(vm.index))
+			return 1
+		},
+		"batch_size": func(L *lua.LState) int {
+			L.Push(lua.LNumber(vm.batchSize))
+			return 1
+		},
+	})
+	L.SetGlobal("benthos", api)
+
+	vm.globals = L.G.Global
+	vm.proto = proto
+	return vm
+}
+
+// newEnv returns a fresh global environment for a single execution of the
+// script, where the library tables are copied so that changes made to them do
+// not outlive the execution.
+func (vm *scriptVM) newEnv() *lua.LTable {
+	L := vm.state
+	env := L.NewTable()
+	vm.globals.ForEach(func(k, v lua.LValue) {
+		if tbl, ok := v.(*lua.LTable); ok && tbl != vm.globals {
+			tblCopy := L.NewTable()
+			tbl.ForEach(func(tk, tv lua.LValue) {
+				tblCopy.RawSet(tk, tv)
+			})
+			v = tblCopy
+		}
+		env.RawSet(k, v)
+	})
+	env.RawSetString("_G", env)
+	return env
+}
+
+// run executes the script against a single part message, returning the parts
+// that result from the script and whether the original part was dropped.
+func (vm *scriptVM) run(
+	timeout time.Duration, part types.Message, index, batchSize int,
+) (types.Message, bool, error) {
+	vm.part, vm.index, vm.batchSize, vm.dropped = part, index, batchSize, false
+	defer func() {
+		vm.part = nil
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Functions loaded by the script with load or loadstring are given the
+	// environment of the state, which is therefore set to the fresh
+	// environment for the duration of the execution.
+	env := vm.newEnv()
+	vm.state.Env = env
+	fn := vm.state.NewFunctionFromProto(vm.proto)
+
+	vm.state.SetContext(ctx)
+	vm.state.Push(fn)
+	err := vm.state.PCall(0, 0, nil)
+	vm.state.RemoveContext()
+	vm.state.SetTop(0)
+	vm.state.Env = vm.globals
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, false, errScriptTimeout
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	md := vm.part.GetMetadata(0)
+	if vm.dropped {
+		result := types.NewMessage(vm.part.GetAll()[1:])
+		if result.Len() > 0 {
+			result.SetMetadata(md)
+		}
+		return result, true, nil
+	}
+	for i := 1; i < vm.part.Len(); i++ {
+		vm.part.SetMetadata(md, i)
+	}
+	return vm.part, false, nil
+}
+
+//------------------------------------------------------------------------------
+
+// jsonToLua converts a parsed JSON value into a Lua value.
+func jsonToLua(L *lua.LState, v interface{}) lua.LValue {
+	switch t := v.(type) {
+	case bool:
+		return lua.LBool(t)
+	case float64:
+		return lua.LNumber(t)
+	case string:
+		return lua.LString(t)
+	case []interface{}:
+		tbl := L.NewTable()
+		for _, e := range t {
+			tbl.Append(jsonToLua(L, e))
+		}
+		return tbl
+	case map[string]interface{}:
+		tbl := L.NewTable()
+		for k, e := range t {
+			tbl.RawSetString(k, jsonToLua(L, e))
+		}
+		return tbl
+	}
+	return lua.LNil
+}
+
+// luaToJSON converts a Lua value into a value that can be serialised as JSON.
+func luaToJSON(v lua.LValue) interface{} {
+	switch t := v.(type) {
+	case lua.LBool:
+		return bool(t)
+	case lua.LNumber:
+		return float64(t)
+	case lua.LString:
+		return string(t)
+	case *lua.LTable:
+		if n := t.MaxN(); n > 0 {
+			arr := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				arr = append(arr, luaToJSON(t.RawGetInt(i)))
+			}
+			return arr
+		}
+		obj := map[string]interface{}{}
+		t.ForEach(func(k, e lua.LValue) {
+			obj[k.String()] = luaToJSON(e)
+		})
+		return obj
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Script is a processor that executes a Lua script against message parts.
+type Script struct {
+	log   log.Modular
+	stats metrics.Type
+
+	parts   []int
+	timeout time.Duration
+	proto   *lua.FunctionProto
+
+	mut sync.Mutex
+	vm  *scriptVM
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mErrTime   metrics.StatCounter
+	mDropped   metrics.StatCounter
+	mPartsDrop metrics.StatCounter
+	mPartsNew  metrics.StatCounter
+	mSent      metrics.StatCounter
+	mLatency   metrics.StatTimer
+}
+
+// NewScript returns a Script processor.
+func NewScript(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	src, name := conf.Script.Script, "script"
+	if len(conf.Script.File) > 0 {
+		if len(src) > 0 {
+			return nil, errors.New("script and file cannot both be set")
+		}
+		srcBytes, err := ioutil.ReadFile(conf.Script.File)
+		if err != nil {
+			return nil, err
+		}
+		src, name = string(srcBytes), conf.Script.File
+	}
+	if len(src) == 0 {
+		return nil, errors.New("either script or file must be set")
+	}
+	if conf.Script.TimeoutMS <= 0 {
+		return nil, errors.New("timeout_ms must be greater than zero")
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(src), name)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "processor.script"
+	if len(conf.Script.Name) > 0 {
+		prefix = prefix + "." + conf.Script.Name
+	}
+
+	return &Script{
+		log:   log.NewModule(".processor.script"),
+		stats: stats,
+
+		parts:   conf.Script.Parts,
+		timeout: time.Duration(conf.Script.TimeoutMS) * time.Millisecond,
+		proto:   proto,
+
+		mCount:     stats.GetCounter(prefix + ".count"),
+		mErr:       stats.GetCounter(prefix + ".error"),
+		mErrTime:   stats.GetCounter(prefix + ".error.timeout"),
+		mDropped:   stats.GetCounter(prefix + ".dropped"),
+		mPartsDrop: stats.GetCounter(prefix + ".parts.dropped"),
+		mPartsNew:  stats.GetCounter(prefix + ".parts.created"),
+		mSent:      stats.GetCounter(prefix + ".sent"),
+		mLatency:   stats.GetTimer(prefix + ".latency"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage executes the script against each targeted part of a message.
+func (s *Script) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	s.mCount.Incr(1)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	targets := map[int]struct{}{}
+	for _, index := range s.parts {
+		if index < 0 {
+			index = msg.Len() + index
+		}
+		targets[index] = struct{}{}
+	}
+
+	newMsg := types.NewMessage(nil)
+	for i := 0; i < msg.Len(); i++ {
+		if _, exists := targets[i]; len(targets) > 0 && !exists {
+			appendParts(newMsg, []types.Message{isolatePart(msg, i)})
+			continue
+		}
+
+		if s.vm == nil {
+			s.vm = newScriptVM(s.proto)
+		}
+		tStarted := time.Now()
+		result, dropped, err := s.vm.run(s.timeout, isolatePart(msg, i), i, msg.Len())
+		s.mLatency.Timing(time.Since(tStarted).Nanoseconds())
+
+		if err != nil {
+			s.mErr.Incr(1)
+			if err == errScriptTimeout {
+				// The state of a VM that was interrupted cannot be trusted.
+				s.mErrTime.Incr(1)
+				s.vm.state.Close()
+				s.vm = nil
+			}
+			s.log.Debugf("Failed to execute script: %v\n", err)
+			result = isolatePart(msg, i)
+			FlagErr(result, 0, err)
+		} else {
+			if dropped {
+				s.mPartsDrop.Incr(1)
+				s.mPartsNew.Incr(int64(result.Len()))
+			} else {
+				s.mPartsNew.Incr(int64(result.Len() - 1))
+			}
+		}
+		appendParts(newMsg, []types.Message{result})
+	}
+
+	if newMsg.Len() == 0 {
+		s.mDropped.Incr(1)
+		return nil, types.NewSimpleResponse(nil)
+	}
+
+	s.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
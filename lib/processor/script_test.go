@@ -0,0 +1,361 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestScriptBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	if _, err := NewScript(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from empty script")
+	}
+
+	conf.Script.Script = "this is not lua"
+	if _, err := NewScript(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad script")
+	}
+
+	conf.Script.Script = "benthos.drop()"
+	conf.Script.File = "/does/not/exist.lua"
+	if _, err := NewScript(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from both script and file")
+	}
+}
+
+func TestScriptContent(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Script = `
+local c = benthos.content()
+benthos.set_content(string.upper(c) .. " " .. benthos.part_index() .. "/" .. benthos.batch_size())
+benthos.set_metadata("original", c)
+`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := types.NewMessage([][]byte{[]byte("foo"), []byte("bar")})
+	msgs, res := proc.ProcessMessage(input)
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+
+	exp := [][]byte{[]byte("FOO 0/2"), []byte("BAR 1/2")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := "bar", msgs[0].GetMetadata(1).Get("original"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if exp, act := [][]byte{[]byte("foo"), []byte("bar")}, input.GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Input message was modified: %s != %s", act, exp)
+	}
+}
+
+func TestScriptNewPartMetadata(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Script = `
+if benthos.content() == "drop" then
+  benthos.drop()
+end
+benthos.new_part("new " .. benthos.content())
+`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := types.NewMessage([][]byte{[]byte("keep"), []byte("drop")})
+	input.GetMetadata(0).Set("source", "first")
+	input.GetMetadata(1).Set("source", "second")
+
+	msgs, _ := proc.ProcessMessage(input)
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("keep"), []byte("new keep"), []byte("new drop")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []string{"first", "first", "second"} {
+		if act := msgs[0].GetMetadata(i).Get("source"); exp != act {
+			t.Errorf("Wrong metadata at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestScriptJSON(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Script = `
+local doc = benthos.json()
+if doc.items == nil then
+  benthos.drop()
+  return
+end
+local total = 0
+for _, item in ipairs(doc.items) do
+  total = total + item.price
+  benthos.new_part(item.name)
+end
+doc.total = total
+benthos.set_json(doc)
+`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b","items":[{"name":"foo","price":5},{"name":"bar","price":10}]}`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+
+	exp := [][]byte{
+		[]byte(`{"id":"b","items":[{"name":"foo","price":5},{"name":"bar","price":10}],"total":15}`),
+		[]byte("foo"),
+		[]byte("bar"),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte(`{"id":"c"}`)}))
+	if len(msgs) != 0 {
+		t.Errorf("Expected message to be dropped: %s", msgs[0].GetAll())
+	}
+	if res == nil || res.Error() != nil {
+		t.Errorf("Expected nil error response: %v", res)
+	}
+}
+
+func TestScriptParts(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Script = `benthos.set_content("changed")`
+	conf.Script.Parts = []int{-1}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo"), []byte("bar")}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("foo"), []byte("changed")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestScriptFailures(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.TimeoutMS = 50
+	conf.Script.Script = `
+local c = benthos.content()
+benthos.set_content("changed")
+if c == "loop" then
+  while true do end
+elseif c == "error" then
+  error("nope")
+end
+`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("loop"), []byte("error"), []byte("foo"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("loop"), []byte("error"), []byte("changed")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := errScriptTimeout.Error(), msgs[0].GetMetadata(0).Get(types.FailFlagKey); exp != act {
+		t.Errorf("Wrong fail flag: %v != %v", act, exp)
+	}
+	for i, exp := range []bool{true, true, false} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestScriptFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_script_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "script.lua")
+	if err = ioutil.WriteFile(path, []byte(`benthos.set_content("from file")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.Script.File = path
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("from file")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestScriptSandbox(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Script = `
+local removed = {}
+for _, name in ipairs({"dofile", "loadfile", "print", "getfenv", "setfenv", "require"}) do
+  if _G[name] ~= nil then
+    table.insert(removed, name)
+  end
+end
+benthos.set_content(table.concat(removed, ",") .. ";" .. tostring(getmetatable("")))
+`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte(";false")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestScriptFreshGlobals(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Script = `
+local seen = {}
+if previous ~= nil then table.insert(seen, "global") end
+if string.previous ~= nil then table.insert(seen, "library") end
+if loaded ~= nil then table.insert(seen, "loadstring") end
+benthos.set_content(table.concat(seen, ","))
+previous = true
+string.previous = true
+rawset(table, "previous", true)
+loadstring("loaded = true")()
+`
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewScript(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+			[]byte("foo"), []byte("bar"),
+		}))
+		if len(msgs) != 1 {
+			t.Fatalf("Wrong count of messages: %v", len(msgs))
+		}
+		exp := [][]byte{[]byte(""), []byte("")}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong result: %s != %s", act, exp)
+		}
+		for j := 0; j < 2; j++ {
+			if HasFailed(msgs[0], j) {
+				t.Errorf("Unexpected failure: %v", msgs[0].GetMetadata(j).Get(types.FailFlagKey))
+			}
+		}
+	}
+}
+
+type fakeMetricPaths struct {
+	metrics.DudType
+	paths []string
+}
+
+func (f *fakeMetricPaths) GetCounter(path ...string) metrics.StatCounter {
+	f.paths = append(f.paths, strings.Join(path, "."))
+	return metrics.DudStat{}
+}
+
+func TestScriptMetricsName(t *testing.T) {
+	conf := NewConfig()
+	conf.Script.Name = "foo"
+	conf.Script.Script = `benthos.drop()`
+
+	stats := &fakeMetricPaths{}
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	if _, err := NewScript(conf, nil, testLog, stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats.paths) == 0 {
+		t.Fatal("No metrics registered")
+	}
+	for _, path := range stats.paths {
+		if !strings.HasPrefix(path, "processor.script.foo.") {
+			t.Errorf("Metric path not prefixed by name: %v", path)
+		}
+	}
+}
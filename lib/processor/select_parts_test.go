@@ -160,3 +160,32 @@ func TestSelectPartsEmpty(t *testing.T) {
 		t.Error("Expected failure with zero parts selected")
 	}
 }
+
+func TestSelectPartsMetadata(t *testing.T) {
+	conf := NewConfig()
+	conf.SelectParts.Parts = []int{2, 0}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSelectParts(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{
+		[]byte("0"),
+		[]byte("1"),
+		[]byte("2"),
+	})
+	msg.GetMetadata(0).Set("index", "0")
+	msg.GetMetadata(2).Set("index", "2")
+
+	msgs, res := proc.ProcessMessage(msg)
+	if len(msgs) != 1 {
+		t.Fatalf("Select Parts failed: %v", res)
+	}
+	for i, exp := range []string{"2", "0"} {
+		if act := msgs[0].GetMetadata(i).Get("index"); exp != act {
+			t.Errorf("Wrong metadata: %v != %v", act, exp)
+		}
+	}
+}
@@ -91,7 +91,9 @@ func (s *Split) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 
 	msgs := make([]types.Message, msg.Len())
 	for i, part := range msg.GetAll() {
-		msgs[i] = types.NewMessage([][]byte{part})
+		newMsg := types.NewMessage([][]byte{part})
+		newMsg.SetMetadata(msg.GetMetadata(i), 0)
+		msgs[i] = newMsg
 	}
 
 	s.mSent.Incr(int64(len(msgs)))
@@ -69,3 +69,30 @@ func TestSplitParts(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitMetadata(t *testing.T) {
+	conf := NewConfig()
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSplit(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := types.NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	})
+	msg.GetMetadata(0).Set("foo", "1")
+	msg.GetMetadata(1).Set("foo", "2")
+
+	msgs, _ := proc.ProcessMessage(msg)
+	if exp, act := 2, len(msgs); exp != act {
+		t.Fatalf("Wrong count of messages: %v != %v", act, exp)
+	}
+	for i, exp := range []string{"1", "2"} {
+		if act := msgs[i].GetMetadata(0).Get("foo"); exp != act {
+			t.Errorf("Wrong metadata: %v != %v", act, exp)
+		}
+	}
+}
@@ -0,0 +1,517 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["subprocess"] = TypeSpec{
+		constructor: NewSubprocess,
+		description: `
+Runs a command as a long running subprocess and, for each message part, writes
+the part to the stdin of the subprocess and replaces the part with the response
+read from its stdout. The subprocess must write exactly one response for each
+part it receives, in order, and must flush its stdout after each response.
+
+The format of parts written to and read from the subprocess is set with
+` + "`framing`" + `, which can be one of:
+
+- ` + "`lines`" + `: Each part is followed by a newline. Parts that contain a
+  newline cannot be sent and are flagged as having failed.
+- ` + "`length_prefixed`" + `: Each part is preceded by its length in bytes as a
+  four byte big endian unsigned integer.
+- ` + "`message`" + `: Each part is sent as a single part message in the binary
+  format of Benthos messages, which is a four byte big endian count of parts
+  followed by each part preceded by its length. Responses in this format can
+  contain any number of parts, which replace the original part. Zero parts
+  removes the original part.
+
+Lines written by the subprocess to stderr are logged as warnings. If the
+subprocess exits, writing to or reading from it fails, or a part is not written
+and its response received within ` + "`timeout_ms`" + ` milliseconds, then the
+part being processed is flagged as having failed and the subprocess is
+restarted for the next part. The subprocess is killed when the pipeline is
+closed.
+
+Responses larger than ` + "`max_part_size`" + ` bytes, or ` + "`message`" + `
+responses with more than ` + "`max_parts`" + ` parts, are rejected before
+being read and are treated as a failure to read from the subprocess.
+
+The field ` + "`parts`" + ` selects the indexes of parts that are sent to the
+subprocess, where an empty array selects all parts. Other parts are left
+unchanged.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SubprocessConfig contains configuration for the Subprocess processor.
+type SubprocessConfig struct {
+	Name        string   `json:"name" yaml:"name"`
+	Args        []string `json:"args" yaml:"args"`
+	Framing     string   `json:"framing" yaml:"framing"`
+	TimeoutMS   int      `json:"timeout_ms" yaml:"timeout_ms"`
+	MaxPartSize int      `json:"max_part_size" yaml:"max_part_size"`
+	MaxParts    int      `json:"max_parts" yaml:"max_parts"`
+	Parts       []int    `json:"parts" yaml:"parts"`
+}
+
+// NewSubprocessConfig returns a SubprocessConfig with default values.
+func NewSubprocessConfig() SubprocessConfig {
+	return SubprocessConfig{
+		Name:        "cat",
+		Args:        []string{},
+		Framing:     "lines",
+		TimeoutMS:   5000,
+		MaxPartSize: 1048576,
+		MaxParts:    1024,
+		Parts:       []int{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// errPartContainsNewline is returned when a part containing a newline is sent
+// with the lines framing.
+var errPartContainsNewline = errors.New("message part contains a newline")
+
+// errSubprocTimeout is returned when the subprocess does not respond to a part
+// within the configured timeout.
+var errSubprocTimeout = errors.New("timed out waiting for subprocess response")
+
+// errFrameTooLarge is returned when a response from the subprocess exceeds the
+// configured size or part count limits.
+var errFrameTooLarge = errors.New("subprocess response exceeds size limits")
+
+type subprocWriter func(w io.Writer, part []byte) error
+type subprocReader func(r *bufio.Reader) ([][]byte, error)
+
+func writeLine(w io.Writer, part []byte) error {
+	if bytes.IndexByte(part, '\n') >= 0 {
+		return errPartContainsNewline
+	}
+	_, err := w.Write(append(append([]byte{}, part...), '\n'))
+	return err
+}
+
+func newLineReader(maxSize int) subprocReader {
+	return func(r *bufio.Reader) ([][]byte, error) {
+		var line []byte
+		for {
+			chunk, err := r.ReadSlice('\n')
+			if len(line)+len(chunk) > maxSize+1 {
+				return nil, errFrameTooLarge
+			}
+			line = append(line, chunk...)
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return [][]byte{line[:len(line)-1]}, nil
+		}
+	}
+}
+
+func writeLengthPrefixed(w io.Writer, part []byte) error {
+	b := make([]byte, 4+len(part))
+	binary.BigEndian.PutUint32(b, uint32(len(part)))
+	copy(b[4:], part)
+	_, err := w.Write(b)
+	return err
+}
+
+func readLengthPrefixed(r *bufio.Reader, maxSize int) ([]byte, error) {
+	lBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lBytes); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(lBytes)
+	if uint64(l) > uint64(maxSize) {
+		return nil, errFrameTooLarge
+	}
+	part := make([]byte, l)
+	if _, err := io.ReadFull(r, part); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+func newLengthPrefixedReader(maxSize int) subprocReader {
+	return func(r *bufio.Reader) ([][]byte, error) {
+		part, err := readLengthPrefixed(r, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{part}, nil
+	}
+}
+
+func writeMessage(w io.Writer, part []byte) error {
+	_, err := w.Write(types.NewMessage([][]byte{part}).Bytes())
+	return err
+}
+
+func newMessageReader(maxSize, maxParts int) subprocReader {
+	return func(r *bufio.Reader) ([][]byte, error) {
+		nBytes := make([]byte, 4)
+		if _, err := io.ReadFull(r, nBytes); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(nBytes)
+		if uint64(n) > uint64(maxParts) {
+			return nil, errFrameTooLarge
+		}
+		parts := make([][]byte, 0, n)
+		for i := uint32(0); i < n; i++ {
+			p, err := readLengthPrefixed(r, maxSize)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, p)
+		}
+		return parts, nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// subprocFrame is a response read from the stdout of a subprocess.
+type subprocFrame struct {
+	parts [][]byte
+	err   error
+}
+
+// subprocess manages a running command, restarting it when it fails.
+type subprocess struct {
+	name      string
+	args      []string
+	log       log.Modular
+	write     subprocWriter
+	read      subprocReader
+	timeout   time.Duration
+	closeChan <-chan struct{}
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	stderr  io.ReadCloser
+	frames  chan subprocFrame
+	done    chan struct{}
+	readers *sync.WaitGroup
+}
+
+func (s *subprocess) start() error {
+	cmd := exec.Command(s.name, s.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	frames, done := make(chan subprocFrame), make(chan struct{})
+	readers := &sync.WaitGroup{}
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		r := bufio.NewReader(stdout)
+		for {
+			parts, err := s.read(r)
+			select {
+			case frames <- subprocFrame{parts: parts, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			s.log.Warnf("%s\n", scanner.Text())
+		}
+	}()
+
+	s.cmd, s.stdin, s.stdout, s.stderr = cmd, stdin, stdout, stderr
+	s.frames, s.done, s.readers = frames, done, readers
+	return nil
+}
+
+// stop kills the subprocess and waits for it to exit. The stdout and stderr
+// readers must finish before the command is waited on, and the pipes are closed
+// in case a descendant of the killed process still holds them open.
+func (s *subprocess) stop() {
+	if s.cmd == nil {
+		return
+	}
+	close(s.done)
+	s.stdin.Close()
+	s.cmd.Process.Kill()
+	s.stdout.Close()
+	s.stderr.Close()
+	s.readers.Wait()
+	s.cmd.Wait()
+	s.cmd = nil
+}
+
+// send writes a part to the subprocess and returns the response.
+func (s *subprocess) send(part []byte) ([][]byte, error) {
+	select {
+	case <-s.closeChan:
+		return nil, types.ErrTypeClosed
+	default:
+	}
+	if s.cmd == nil {
+		s.log.Infof("Restarting subprocess: %v\n", s.name)
+		if err := s.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The write is made in a goroutine so that a subprocess that stops reading
+	// its stdin cannot block us beyond the timeout. Stopping the subprocess
+	// closes its stdin, which releases the goroutine.
+	timeout := time.After(s.timeout)
+	writeErrChan := make(chan error, 1)
+	go func(stdin io.Writer) {
+		writeErrChan <- s.write(stdin, part)
+	}(s.stdin)
+
+	select {
+	case err := <-writeErrChan:
+		if err == errPartContainsNewline {
+			return nil, err
+		}
+		if err != nil {
+			s.stop()
+			return nil, fmt.Errorf("failed to write to subprocess: %v", err)
+		}
+	case <-timeout:
+		s.stop()
+		return nil, errSubprocTimeout
+	case <-s.closeChan:
+		s.stop()
+		return nil, types.ErrTypeClosed
+	}
+
+	var frame subprocFrame
+	select {
+	case frame = <-s.frames:
+	case <-timeout:
+		s.stop()
+		return nil, errSubprocTimeout
+	case <-s.closeChan:
+		s.stop()
+		return nil, types.ErrTypeClosed
+	}
+	if frame.err != nil {
+		s.stop()
+		return nil, fmt.Errorf("failed to read from subprocess: %v", frame.err)
+	}
+	return frame.parts, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Subprocess is a processor that sends message parts through a long running
+// subprocess.
+type Subprocess struct {
+	log   log.Modular
+	stats metrics.Type
+
+	parts []int
+
+	mut     sync.Mutex
+	subproc *subprocess
+
+	running    int32
+	closeChan  chan struct{}
+	closedChan chan struct{}
+
+	mCount   metrics.StatCounter
+	mErr     metrics.StatCounter
+	mDropped metrics.StatCounter
+	mSent    metrics.StatCounter
+}
+
+// NewSubprocess returns a Subprocess processor.
+func NewSubprocess(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	s := &Subprocess{
+		log:   log.NewModule(".processor.subprocess"),
+		stats: stats,
+		parts: conf.Subprocess.Parts,
+
+		running:    1,
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+
+		mCount:   stats.GetCounter("processor.subprocess.count"),
+		mErr:     stats.GetCounter("processor.subprocess.error"),
+		mDropped: stats.GetCounter("processor.subprocess.dropped"),
+		mSent:    stats.GetCounter("processor.subprocess.sent"),
+	}
+	if conf.Subprocess.TimeoutMS <= 0 {
+		return nil, errors.New("timeout_ms must be greater than zero")
+	}
+	if conf.Subprocess.MaxPartSize <= 0 {
+		return nil, errors.New("max_part_size must be greater than zero")
+	}
+	if conf.Subprocess.MaxParts <= 0 {
+		return nil, errors.New("max_parts must be greater than zero")
+	}
+	s.subproc = &subprocess{
+		name:      conf.Subprocess.Name,
+		args:      conf.Subprocess.Args,
+		log:       s.log,
+		timeout:   time.Duration(conf.Subprocess.TimeoutMS) * time.Millisecond,
+		closeChan: s.closeChan,
+	}
+	switch conf.Subprocess.Framing {
+	case "lines":
+		s.subproc.write = writeLine
+		s.subproc.read = newLineReader(conf.Subprocess.MaxPartSize)
+	case "length_prefixed":
+		s.subproc.write = writeLengthPrefixed
+		s.subproc.read = newLengthPrefixedReader(conf.Subprocess.MaxPartSize)
+	case "message":
+		s.subproc.write = writeMessage
+		s.subproc.read = newMessageReader(conf.Subprocess.MaxPartSize, conf.Subprocess.MaxParts)
+	default:
+		return nil, fmt.Errorf("framing not recognised: %v", conf.Subprocess.Framing)
+	}
+	if err := s.subproc.start(); err != nil {
+		return nil, fmt.Errorf("failed to start subprocess: %v", err)
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage sends each targeted part of a message through the subprocess.
+func (s *Subprocess) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	s.mCount.Incr(1)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	targets := map[int]struct{}{}
+	for _, index := range s.parts {
+		if index < 0 {
+			index = msg.Len() + index
+		}
+		targets[index] = struct{}{}
+	}
+
+	newMsg := types.NewMessage(nil)
+	for i := 0; i < msg.Len(); i++ {
+		if _, exists := targets[i]; len(targets) > 0 && !exists {
+			appendParts(newMsg, []types.Message{isolatePart(msg, i)})
+			continue
+		}
+
+		resParts, err := s.subproc.send(msg.Get(i))
+		if err != nil {
+			s.mErr.Incr(1)
+			s.log.Debugf("Failed to process part: %v\n", err)
+			failedPart := isolatePart(msg, i)
+			FlagErr(failedPart, 0, err)
+			appendParts(newMsg, []types.Message{failedPart})
+			continue
+		}
+
+		resMsg := types.NewMessage(resParts)
+		if len(resParts) > 0 {
+			resMsg.SetMetadata(msg.GetMetadata(i))
+		}
+		appendParts(newMsg, []types.Message{resMsg})
+	}
+
+	if newMsg.Len() == 0 {
+		s.mDropped.Incr(1)
+		return nil, types.NewSimpleResponse(nil)
+	}
+
+	s.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down the processor, interrupting any part waiting for a
+// response and killing the subprocess.
+func (s *Subprocess) CloseAsync() {
+	if !atomic.CompareAndSwapInt32(&s.running, 1, 0) {
+		return
+	}
+	close(s.closeChan)
+	go func() {
+		s.mut.Lock()
+		s.subproc.stop()
+		s.mut.Unlock()
+		close(s.closedChan)
+	}()
+}
+
+// WaitForClose blocks until the subprocess has been killed.
+func (s *Subprocess) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-s.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,344 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestSubprocessBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Subprocess.Framing = "nope"
+	if _, err := NewSubprocess(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad framing")
+	}
+
+	conf = NewConfig()
+	conf.Subprocess.Name = "/does/not/exist"
+	if _, err := NewSubprocess(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad command")
+	}
+}
+
+func TestSubprocessFramings(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	for _, framing := range []string{"lines", "length_prefixed", "message"} {
+		conf := NewConfig()
+		conf.Subprocess.Name = "cat"
+		conf.Subprocess.Framing = framing
+
+		proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		input := types.NewMessage([][]byte{[]byte("foo"), []byte("bar baz")})
+		input.GetMetadata(1).Set("foo", "bar")
+
+		msgs, res := proc.ProcessMessage(input)
+		if len(msgs) != 1 {
+			t.Fatalf("Wrong count of messages for %v: %v", framing, len(msgs))
+		}
+		if res != nil {
+			t.Errorf("Expected nil res for %v", framing)
+		}
+		exp := [][]byte{[]byte("foo"), []byte("bar baz")}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong result for %v: %s != %s", framing, act, exp)
+		}
+		if exp, act := "bar", msgs[0].GetMetadata(1).Get("foo"); exp != act {
+			t.Errorf("Wrong metadata for %v: %v != %v", framing, act, exp)
+		}
+	}
+}
+
+func TestSubprocessTransform(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", `while read l; do echo "prefix: $l"; done`}
+	conf.Subprocess.Parts = []int{0, 2}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz\nqux"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("prefix: foo"), []byte("bar"), []byte("baz\nqux")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []bool{false, false, true} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestSubprocessRestart(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", `read l; echo "$l"; exit 1`}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []bool{false, true, false} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestSubprocessTimeout(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", `while read l; do if [ "$l" != "hang" ]; then echo "$l"; fi; done`}
+	conf.Subprocess.TimeoutMS = 100
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("foo"), []byte("hang"), []byte("bar"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	exp := [][]byte{[]byte("foo"), []byte("hang"), []byte("bar")}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, exp := range []bool{false, true, false} {
+		if act := HasFailed(msgs[0], i); exp != act {
+			t.Errorf("Wrong fail flag at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestSubprocessClose(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", `while read l; do :; done`}
+	conf.Subprocess.TimeoutMS = 60000
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resChan := make(chan []types.Message)
+	go func() {
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+		resChan <- msgs
+	}()
+
+	<-time.After(time.Millisecond * 50)
+	closable := proc.(types.Closable)
+	closable.CloseAsync()
+	if err = closable.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msgs := <-resChan:
+		if len(msgs) != 1 || !HasFailed(msgs[0], 0) {
+			t.Errorf("Expected failed part after close: %v", msgs)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for interrupted part")
+	}
+}
+
+func TestSubprocessWriteTimeout(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sleep"
+	conf.Subprocess.Args = []string{"60"}
+	conf.Subprocess.TimeoutMS = 100
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		proc.(types.Closable).CloseAsync()
+		proc.(types.Closable).WaitForClose(time.Second * 5)
+	}()
+
+	// Larger than a pipe buffer, so the write blocks as stdin is never read.
+	part := bytes.Repeat([]byte("a"), 1<<20)
+
+	resChan := make(chan []types.Message)
+	go func() {
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{part}))
+		resChan <- msgs
+	}()
+
+	select {
+	case msgs := <-resChan:
+		if len(msgs) != 1 || !HasFailed(msgs[0], 0) {
+			t.Errorf("Expected failed part after write timeout: %v", msgs)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for blocked write")
+	}
+}
+
+func TestSubprocessCloseDuringWrite(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sleep"
+	conf.Subprocess.Args = []string{"60"}
+	conf.Subprocess.TimeoutMS = 60000
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := bytes.Repeat([]byte("a"), 1<<20)
+
+	resChan := make(chan []types.Message)
+	go func() {
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{part}))
+		resChan <- msgs
+	}()
+
+	<-time.After(time.Millisecond * 50)
+	closable := proc.(types.Closable)
+	closable.CloseAsync()
+	if err = closable.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msgs := <-resChan:
+		if len(msgs) != 1 || !HasFailed(msgs[0], 0) {
+			t.Errorf("Expected failed part after close: %v", msgs)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for interrupted part")
+	}
+}
+
+func TestSubprocessFrameLimits(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	for _, framing := range []string{"lines", "length_prefixed", "message"} {
+		conf := NewConfig()
+		conf.Subprocess.Name = "cat"
+		conf.Subprocess.Framing = framing
+		conf.Subprocess.MaxPartSize = 5
+
+		proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{
+			[]byte("foo"), []byte("too large"), []byte("bar"),
+		}))
+		if len(msgs) != 1 {
+			t.Fatalf("Wrong count of messages for %v: %v", framing, len(msgs))
+		}
+		exp := [][]byte{[]byte("foo"), []byte("too large"), []byte("bar")}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong result for %v: %s != %s", framing, act, exp)
+		}
+		for i, exp := range []bool{false, true, false} {
+			if act := HasFailed(msgs[0], i); exp != act {
+				t.Errorf("Wrong fail flag for %v at index %v: %v != %v", framing, i, act, exp)
+			}
+		}
+	}
+}
+
+func TestSubprocessMessageLimits(t *testing.T) {
+	if _, err := newMessageReader(10, 2)(bufio.NewReader(bytes.NewReader(
+		[]byte{0xff, 0xff, 0xff, 0xff},
+	))); err != errFrameTooLarge {
+		t.Errorf("Wrong error for large part count: %v", err)
+	}
+	if _, err := newLengthPrefixedReader(10)(bufio.NewReader(bytes.NewReader(
+		[]byte{0xff, 0xff, 0xff, 0xff},
+	))); err != errFrameTooLarge {
+		t.Errorf("Wrong error for large part size: %v", err)
+	}
+}
+
+func TestSubprocessCloseWithDescendant(t *testing.T) {
+	conf := NewConfig()
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", `sleep 60 & while read l; do echo "$l"; done`}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewSubprocess(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(types.NewMessage([][]byte{[]byte("foo")}))
+	if len(msgs) != 1 || HasFailed(msgs[0], 0) {
+		t.Fatalf("Unexpected result: %v", msgs)
+	}
+
+	closable := proc.(types.Closable)
+	closable.CloseAsync()
+	if err = closable.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/processor/condition"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["switch"] = TypeSpec{
+		constructor: NewSwitch,
+		description: `
+Switch is a processor that has an ordered list of cases, each containing a
+condition and a list of child processors. The conditions of each case are
+checked in order, and the processors of the first case that passes are applied.
+If no case passes then the message is passed on unchanged. A case without a
+condition always passes, and can therefore be used as a default case when placed
+last.
+
+When a case has ` + "`fallthrough`" + ` set to ` + "`true`" + ` the processors
+of the next case are also applied, without checking its condition. For example,
+with the following config:
+
+` + "``` yaml" + `
+switch:
+  mode: message
+  cases:
+  - condition:
+      type: check
+      check: 'json("type") == "user"'
+    processors:
+    - type: jmespath
+      jmespath:
+        query: "{name: user.name}"
+    fallthrough: true
+  - condition:
+      type: check
+      check: 'json("type") == "order"'
+    processors:
+    - type: dedupe
+      dedupe:
+        cache: orders
+` + "```" + `
+
+Messages of type ` + "`user`" + ` are transformed and deduplicated, messages of
+type ` + "`order`" + ` are only deduplicated, and all other messages are
+untouched.
+
+When ` + "`mode`" + ` is ` + "`message`" + ` the conditions are checked against
+the whole message and the processors are applied to the whole message. When
+` + "`mode`" + ` is ` + "`part`" + ` each message part is checked and processed
+individually as a single part message, and the resulting parts are placed back
+into the message in the position of the original part.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SwitchCaseConfig contains a condition, processors and other fields for an
+// individual case in the Switch processor.
+type SwitchCaseConfig struct {
+	Condition   condition.Config `json:"condition" yaml:"condition"`
+	Processors  []Config         `json:"processors" yaml:"processors"`
+	Fallthrough bool             `json:"fallthrough" yaml:"fallthrough"`
+}
+
+// NewSwitchCaseConfig returns a new SwitchCaseConfig with default values.
+func NewSwitchCaseConfig() SwitchCaseConfig {
+	cond := condition.NewConfig()
+	cond.Type = "static"
+	cond.Static = true
+	return SwitchCaseConfig{
+		Condition:   cond,
+		Processors:  []Config{},
+		Fallthrough: false,
+	}
+}
+
+// UnmarshalJSON ensures that when parsing configs that are in a slice the
+// default values are still applied.
+func (s *SwitchCaseConfig) UnmarshalJSON(bytes []byte) error {
+	type confAlias SwitchCaseConfig
+	aliased := confAlias(NewSwitchCaseConfig())
+
+	if err := json.Unmarshal(bytes, &aliased); err != nil {
+		return err
+	}
+
+	*s = SwitchCaseConfig(aliased)
+	return nil
+}
+
+// UnmarshalYAML ensures that when parsing configs that are in a slice the
+// default values are still applied.
+func (s *SwitchCaseConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type confAlias SwitchCaseConfig
+	aliased := confAlias(NewSwitchCaseConfig())
+
+	if err := unmarshal(&aliased); err != nil {
+		return err
+	}
+
+	*s = SwitchCaseConfig(aliased)
+	return nil
+}
+
+// SwitchConfig is a config struct containing fields for the Switch processor.
+type SwitchConfig struct {
+	Mode  string             `json:"mode" yaml:"mode"`
+	Cases []SwitchCaseConfig `json:"cases" yaml:"cases"`
+}
+
+// NewSwitchConfig returns a default SwitchConfig.
+func NewSwitchConfig() SwitchConfig {
+	return SwitchConfig{
+		Mode:  "message",
+		Cases: []SwitchCaseConfig{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type switchCase struct {
+	cond        condition.Type
+	procs       []Type
+	fallThrough bool
+
+	mHit metrics.StatCounter
+}
+
+// Switch is a processor that applies the child processors of the first case
+// with a condition that passes.
+type Switch struct {
+	byPart bool
+	cases  []switchCase
+
+	mCount     metrics.StatCounter
+	mUnmatched metrics.StatCounter
+	mSent      metrics.StatCounter
+	mDropped   metrics.StatCounter
+}
+
+// NewSwitch returns a Switch processor.
+func NewSwitch(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	var byPart bool
+	switch conf.Switch.Mode {
+	case "message":
+	case "part":
+		byPart = true
+	default:
+		return nil, fmt.Errorf("mode not recognised: %v", conf.Switch.Mode)
+	}
+
+	var cases []switchCase
+	for i, caseConf := range conf.Switch.Cases {
+		cond, err := condition.New(caseConf.Condition, mgr, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create case %v condition: %v", i, err)
+		}
+		var procs []Type
+		for j, pconf := range caseConf.Processors {
+			var proc Type
+			if proc, err = New(pconf, mgr, log, stats); err != nil {
+				return nil, fmt.Errorf("failed to create case %v processor %v: %v", i, j, err)
+			}
+			procs = append(procs, proc)
+		}
+		cases = append(cases, switchCase{
+			cond:        cond,
+			procs:       procs,
+			fallThrough: caseConf.Fallthrough,
+			mHit:        stats.GetCounter(fmt.Sprintf("processor.switch.case.%v.hit", i)),
+		})
+	}
+
+	return &Switch{
+		byPart: byPart,
+		cases:  cases,
+
+		mCount:     stats.GetCounter("processor.switch.count"),
+		mUnmatched: stats.GetCounter("processor.switch.unmatched"),
+		mSent:      stats.GetCounter("processor.switch.sent"),
+		mDropped:   stats.GetCounter("processor.switch.dropped"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// selectProcs returns the processors to apply to a message, which are those of
+// the first passing case followed by those of any cases it falls through to.
+// Every case that contributes processors is counted as a hit.
+func (s *Switch) selectProcs(msg types.Message) []Type {
+	for i, c := range s.cases {
+		if !c.cond.Check(msg) {
+			continue
+		}
+		c.mHit.Incr(1)
+		procs := c.procs
+		for ; s.cases[i].fallThrough && i+1 < len(s.cases); i++ {
+			s.cases[i+1].mHit.Incr(1)
+			procs = append(procs[:len(procs):len(procs)], s.cases[i+1].procs...)
+		}
+		return procs
+	}
+	s.mUnmatched.Incr(1)
+	return nil
+}
+
+// ProcessMessage applies the processors of matching cases to a message and
+// returns the result.
+func (s *Switch) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	s.mCount.Incr(1)
+
+	if !s.byPart {
+		resultMsgs, res := executeAll(s.selectProcs(msg), msg)
+		if len(resultMsgs) == 0 {
+			s.mDropped.Incr(1)
+			if res == nil {
+				res = types.NewSimpleResponse(nil)
+			}
+			return nil, res
+		}
+		s.mSent.Incr(int64(len(resultMsgs)))
+		return resultMsgs, nil
+	}
+
+	newMsg := types.NewMessage(nil)
+	var res types.Response
+
+	msg.Iter(func(i int, part []byte) error {
+		partMsg := isolatePart(msg, i)
+		var resultMsgs []types.Message
+		resultMsgs, res = executeAll(s.selectProcs(partMsg), partMsg)
+		appendParts(newMsg, resultMsgs)
+		return nil
+	})
+
+	if newMsg.Len() == 0 {
+		s.mDropped.Incr(1)
+		if res == nil {
+			res = types.NewSimpleResponse(nil)
+		}
+		return nil, res
+	}
+
+	s.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down any child processors that hold resources.
+func (s *Switch) CloseAsync() {
+	for _, c := range s.cases {
+		closeAll(c.procs)
+	}
+}
+
+// WaitForClose blocks until the child processors have closed down.
+func (s *Switch) WaitForClose(timeout time.Duration) error {
+	stopBy := time.Now().Add(timeout)
+	for _, c := range s.cases {
+		if err := waitForCloseAll(c.procs, time.Until(stopBy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,267 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func newSwitchFromYAML(t *testing.T, confStr string) Type {
+	t.Helper()
+
+	conf := NewConfig()
+	if err := yaml.Unmarshal([]byte(confStr), &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := New(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proc
+}
+
+func TestSwitchMessage(t *testing.T) {
+	proc := newSwitchFromYAML(t, `
+type: switch
+switch:
+  mode: message
+  cases:
+  - condition:
+      type: content
+      content:
+        operator: equals_cs
+        arg: foo
+    processors:
+    - type: insert_part
+      insert_part:
+        content: "from foo"
+    fallthrough: true
+  - condition:
+      type: content
+      content:
+        operator: equals_cs
+        arg: bar
+    processors:
+    - type: insert_part
+      insert_part:
+        content: "from bar"
+  - processors:
+    - type: insert_part
+      insert_part:
+        content: "from default"
+`)
+
+	tests := map[string][]string{
+		"foo": {"foo", "from foo", "from bar"},
+		"bar": {"bar", "from bar"},
+		"baz": {"baz", "from default"},
+	}
+
+	for input, expStrs := range tests {
+		exp := [][]byte{}
+		for _, s := range expStrs {
+			exp = append(exp, []byte(s))
+		}
+
+		msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{[]byte(input)}))
+		if res != nil {
+			t.Fatal(res.Error())
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("Wrong count of messages: %v", len(msgs))
+		}
+		if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+			t.Errorf("Wrong result for %v: %s != %s", input, act, exp)
+		}
+	}
+}
+
+type fakeSwitchCounter struct {
+	count int64
+}
+
+func (f *fakeSwitchCounter) Incr(count int64) error {
+	f.count += count
+	return nil
+}
+
+func (f *fakeSwitchCounter) Decr(count int64) error {
+	f.count -= count
+	return nil
+}
+
+func TestSwitchFallthroughHits(t *testing.T) {
+	proc := newSwitchFromYAML(t, `
+type: switch
+switch:
+  mode: message
+  cases:
+  - condition:
+      type: content
+      content:
+        operator: equals_cs
+        arg: foo
+    fallthrough: true
+  - condition:
+      type: content
+      content:
+        operator: equals_cs
+        arg: bar
+    fallthrough: true
+  - condition:
+      type: content
+      content:
+        operator: equals_cs
+        arg: baz
+`)
+
+	counters := []*fakeSwitchCounter{}
+	for i := range proc.(*Switch).cases {
+		counter := &fakeSwitchCounter{}
+		proc.(*Switch).cases[i].mHit = counter
+		counters = append(counters, counter)
+	}
+
+	for _, input := range []string{"foo", "bar", "baz", "qux"} {
+		proc.ProcessMessage(types.NewMessage([][]byte{[]byte(input)}))
+	}
+
+	// Cases reached by fallthrough are hits even when their condition fails.
+	for i, exp := range []int64{1, 2, 3} {
+		if act := counters[i].count; act != exp {
+			t.Errorf("Wrong hit count for case %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestSwitchParts(t *testing.T) {
+	proc := newSwitchFromYAML(t, `
+type: switch
+switch:
+  mode: part
+  cases:
+  - condition:
+      type: content
+      content:
+        operator: prefix_cs
+        arg: drop
+    processors:
+    - type: filter
+      filter:
+        type: static
+        static: false
+  - condition:
+      type: content
+      content:
+        operator: prefix_cs
+        arg: dupe
+    processors:
+    - type: insert_part
+      insert_part:
+        content: "${!content}"
+`)
+
+	input := [][]byte{
+		[]byte("foo"),
+		[]byte("drop me"),
+		[]byte("dupe me"),
+		[]byte("bar"),
+	}
+	exp := [][]byte{
+		[]byte("foo"),
+		[]byte("dupe me"),
+		[]byte("dupe me"),
+		[]byte("bar"),
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage(input))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	msgs, res = proc.ProcessMessage(types.NewMessage([][]byte{[]byte("drop this")}))
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages, received: %v", len(msgs))
+	}
+	if res == nil {
+		t.Error("Expected response from dropped message")
+	}
+}
+
+func TestSwitchNoMatch(t *testing.T) {
+	proc := newSwitchFromYAML(t, `
+type: switch
+switch:
+  cases:
+  - condition:
+      type: static
+      static: false
+    processors:
+    - type: filter
+      filter:
+        type: static
+        static: false
+`)
+
+	exp := [][]byte{[]byte("foo"), []byte("bar")}
+	msgs, res := proc.ProcessMessage(types.NewMessage(exp))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
+func TestSwitchBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.Type = "switch"
+	conf.Switch.Mode = "nope"
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad mode")
+	}
+
+	conf = NewConfig()
+	conf.Type = "switch"
+	caseConf := NewSwitchCaseConfig()
+	caseConf.Condition.Type = "nope"
+	conf.Switch.Cases = append(conf.Switch.Cases, caseConf)
+	if _, err := New(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad condition")
+	}
+}
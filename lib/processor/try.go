@@ -0,0 +1,175 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["try"] = TypeSpec{
+		constructor: NewTry,
+		description: `
+Applies a list of child processors to each part of a message in order. If a
+processor fails for a part then that part skips all of the following child
+processors, and is passed on with its failure flag intact. Parts that were
+already flagged as failed before reaching this processor skip all children.
+
+For example, with the following config:
+
+` + "``` yaml" + `
+try:
+- type: json
+  json:
+    operator: select
+    path: document
+- type: grok
+  grok:
+    patterns:
+    - "%{WORD:first},%{INT:second:int}"
+` + "```" + `
+
+If a part does not contain valid JSON then the grok processor is not applied to
+it.
+
+Each part is processed individually as a single part message, and the resulting
+parts are placed back into the message in the position of the original part.
+
+For more information about error handling read [this document](../error_handling.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Try is a processor that applies a list of child processors to each message
+// part, stopping for a part as soon as a child processor fails it.
+type Try struct {
+	children []Type
+	steps    []Type
+
+	mCount   metrics.StatCounter
+	mFailed  metrics.StatCounter
+	mSent    metrics.StatCounter
+	mDropped metrics.StatCounter
+}
+
+// NewTry returns a Try processor.
+func NewTry(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	var children, steps []Type
+	for _, pconf := range conf.Try {
+		proc, err := New(pconf, mgr, log, stats)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, proc)
+		steps = append(steps, tryStep{proc})
+	}
+	return &Try{
+		children: children,
+		steps:    steps,
+
+		mCount:   stats.GetCounter("processor.try.count"),
+		mFailed:  stats.GetCounter("processor.try.failed"),
+		mSent:    stats.GetCounter("processor.try.sent"),
+		mDropped: stats.GetCounter("processor.try.dropped"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// hasAnyFailed returns true if any part of a message has been flagged as
+// failed.
+func hasAnyFailed(msg types.Message) bool {
+	for i := 0; i < msg.Len(); i++ {
+		if HasFailed(msg, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// tryStep wraps a child processor so that messages with failed parts pass
+// through it unchanged.
+type tryStep struct {
+	Type
+}
+
+func (t tryStep) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	if hasAnyFailed(msg) {
+		return []types.Message{msg}, nil
+	}
+	return t.Type.ProcessMessage(msg)
+}
+
+// ProcessMessage applies the child processors to each part of a message and
+// returns the result.
+func (p *Try) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	p.mCount.Incr(1)
+
+	newMsg := types.NewMessage(nil)
+	var res types.Response
+
+	msg.Iter(func(i int, part []byte) error {
+		var resultMsgs []types.Message
+		resultMsgs, res = executeAll(p.steps, isolatePart(msg, i))
+		for _, m := range resultMsgs {
+			if hasAnyFailed(m) {
+				p.mFailed.Incr(1)
+			}
+		}
+		appendParts(newMsg, resultMsgs)
+		return nil
+	})
+
+	if newMsg.Len() == 0 {
+		p.mDropped.Incr(1)
+		if res == nil {
+			res = types.NewSimpleResponse(nil)
+		}
+		return nil, res
+	}
+
+	p.mSent.Incr(1)
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down any child processors that hold resources.
+func (p *Try) CloseAsync() {
+	closeAll(p.children)
+}
+
+// WaitForClose blocks until the child processors have closed down.
+func (p *Try) WaitForClose(timeout time.Duration) error {
+	return waitForCloseAll(p.children, timeout)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+func TestTryFailedParts(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "try"
+
+	grokConf := NewConfig()
+	grokConf.Type = "grok"
+	grokConf.Grok.Patterns = []string{"%{WORD:first},%{INT:second:int}"}
+
+	insertConf := NewConfig()
+	insertConf.Type = "insert_part"
+	insertConf.InsertPart.Content = "end"
+	insertConf.InsertPart.Index = -1
+
+	conf.Try = append(conf.Try, grokConf, insertConf)
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := types.NewMessage([][]byte{
+		[]byte("foo,1"),
+		[]byte("nope"),
+		[]byte("bar,2"),
+	})
+	FlagFail(msgIn, 2)
+
+	exp := [][]byte{
+		[]byte(`{"first":"foo","second":1}`),
+		[]byte("end"),
+		[]byte("nope"),
+		[]byte("bar,2"),
+	}
+
+	msgs, res := c.ProcessMessage(msgIn)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	expFailed := []bool{false, false, true, true}
+	for i, exp := range expFailed {
+		if act := HasFailed(msgs[0], i); act != exp {
+			t.Errorf("Wrong failed flag for part %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestTryDropped(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	testMet := metrics.DudType{}
+
+	conf := NewConfig()
+	conf.Type = "try"
+
+	procConf := NewConfig()
+	procConf.Type = "filter"
+	procConf.Filter.Type = "static"
+	procConf.Filter.Static = false
+
+	conf.Try = append(conf.Try, procConf)
+
+	c, err := New(conf, nil, testLog, testMet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := c.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	}))
+	if len(msgs) != 0 {
+		t.Errorf("Expected message to be dropped: %v", len(msgs))
+	}
+	if res == nil {
+		t.Error("Expected non-nil response")
+	}
+}
@@ -21,6 +21,8 @@
 package processor
 
 import (
+	"time"
+
 	"github.com/Jeffail/benthos/lib/types"
 )
 
@@ -29,6 +31,10 @@ import (
 // Type reads a message, performs a processing operation, and returns either a
 // slice of messages resulting from the process to be propagated through the,
 // pipeline, or a response that should be sent back to the source instead.
+//
+// Processors that hold resources such as goroutines, connections or child
+// processes may also implement types.Closable, in which case they are closed
+// along with the pipeline that owns them.
 type Type interface {
 	// ProcessMessage attempts to process a message. Since processing can fail
 	// this call returns both a slice of messages in case of success or a
@@ -38,3 +44,22 @@ type Type interface {
 }
 
 //------------------------------------------------------------------------------
+
+// Flusher is an optional interface implemented by processors that buffer
+// messages across calls to ProcessMessage. Since ProcessMessage is only called
+// when a new message arrives a pipeline calls Flush each flush period, which is
+// restarted when a processor begins buffering messages, in order to emit
+// buffered messages that have expired in the meantime.
+type Flusher interface {
+	// FlushPeriod returns the maximum duration that messages should remain
+	// buffered by the processor, or zero if messages do not expire.
+	FlushPeriod() time.Duration
+
+	// Flush returns any buffered messages that have expired, which are then
+	// propagated through the remainder of the pipeline. Once they reach their
+	// destination the deferred responses given to the source of these messages
+	// are resolved.
+	Flush() []types.Message
+}
+
+//------------------------------------------------------------------------------
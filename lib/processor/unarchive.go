@@ -22,7 +22,9 @@ package processor
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 
@@ -38,20 +40,29 @@ func init() {
 		constructor: NewUnarchive,
 		description: `
 Unarchives parts of a message according to the selected archive type into
-multiple parts. Supported archive types are: tar, binary. If the list of target
-parts is empty the unarchive will be applied to all message parts.
+multiple parts. Supported archive types are: tar, zip, binary, lines and
+json_array. If the list of target parts is empty the unarchive will be applied
+to all message parts.
 
 When a part is unarchived it is split into more message parts that replace the
-original part. If you wish to split the archive into one message per file then
-follow this with the 'split' processor.
+original part, each inheriting the metadata of the original. If you wish to
+split the archive into one message per file then follow this with the 'split'
+processor.
+
+For the file based formats (tar, zip) the name of each file is added to the
+metadata of its part with the key 'archive_filename'.
+
+The 'lines' format splits a part by newlines, where empty lines are skipped, and
+the 'json_array' format splits a JSON array into a part for each element.
 
 Part indexes can be negative, and if so the part will be selected from the end
 counting backwards starting from -1. E.g. if index = -1 then the selected part
 will be the last part of the message, if index = -2 then the part before the
 last element with be selected, and so on.
 
-Parts that are selected but fail to unarchive (invalid format) will be removed
-from the message. If the message results in zero parts it is skipped entirely.`,
+Parts that are selected but fail to unarchive (invalid format) are left
+unchanged and flagged as having failed, failed parts can be handled using the
+methods outlined in the [error handling docs](../error_handling.md).`,
 	}
 }
 
@@ -73,17 +84,17 @@ func NewUnarchiveConfig() UnarchiveConfig {
 
 //------------------------------------------------------------------------------
 
-type unarchiveFunc func(bytes []byte) ([][]byte, error)
+type unarchiveFunc func(bytes []byte) (types.Message, error)
 
-func tarUnarchive(b []byte) ([][]byte, error) {
+func tarUnarchive(b []byte) (types.Message, error) {
 	buf := bytes.NewBuffer(b)
 	tr := tar.NewReader(buf)
 
-	var newParts [][]byte
+	newMsg := types.NewMessage(nil)
 
 	// Iterate through the files in the archive.
 	for {
-		_, err := tr.Next()
+		h, err := tr.Next()
 		if err == io.EOF {
 			// end of tar archive
 			break
@@ -97,27 +108,85 @@ func tarUnarchive(b []byte) ([][]byte, error) {
 			return nil, err
 		}
 
-		newParts = append(newParts, newPartBuf.Bytes())
+		index := newMsg.Append(newPartBuf.Bytes())
+		newMsg.GetMetadata(index).Set("archive_filename", h.Name)
 	}
 
-	return newParts, nil
+	return newMsg, nil
 }
 
-func binaryUnarchive(b []byte) ([][]byte, error) {
-	msg, err := types.FromBytes(b)
+func zipUnarchive(b []byte) (types.Message, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
 	if err != nil {
 		return nil, err
 	}
 
-	return msg.GetAll(), nil
+	newMsg := types.NewMessage(nil)
+
+	// Iterate through the files in the archive.
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		fr, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		newPartBuf := bytes.Buffer{}
+		_, err = newPartBuf.ReadFrom(fr)
+		fr.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		index := newMsg.Append(newPartBuf.Bytes())
+		newMsg.GetMetadata(index).Set("archive_filename", f.Name)
+	}
+
+	return newMsg, nil
+}
+
+func binaryUnarchive(b []byte) (types.Message, error) {
+	return types.FromBytes(b)
+}
+
+func linesUnarchive(b []byte) (types.Message, error) {
+	newMsg := types.NewMessage(nil)
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(line) > 0 {
+			newMsg.Append(line)
+		}
+	}
+	return newMsg, nil
+}
+
+func jsonArrayUnarchive(b []byte) (types.Message, error) {
+	var array []json.RawMessage
+	if err := json.Unmarshal(b, &array); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON array: %v", err)
+	}
+
+	newMsg := types.NewMessage(nil)
+	for _, ele := range array {
+		newMsg.Append([]byte(ele))
+	}
+	return newMsg, nil
 }
 
 func strToUnarchiver(str string) (unarchiveFunc, error) {
 	switch str {
 	case "tar":
 		return tarUnarchive, nil
+	case "zip":
+		return zipUnarchive, nil
 	case "binary":
 		return binaryUnarchive, nil
+	case "lines":
+		return linesUnarchive, nil
+	case "json_array":
+		return jsonArrayUnarchive, nil
 	}
 	return nil, fmt.Errorf("archive format not recognised: %v", str)
 }
@@ -187,15 +256,28 @@ func (d *Unarchive) ProcessMessage(msg types.Message) ([]types.Message, types.Re
 			}
 		}
 		if !isTarget {
-			newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), newMsg.Append(part))
 			continue
 		}
 		newParts, err := d.unarchive(part)
 		if err == nil {
 			d.mSucc.Incr(1)
-			newMsg.Append(newParts...)
+			newParts.Iter(func(j int, newPart []byte) error {
+				index := newMsg.Append(newPart)
+				newMsg.SetMetadata(msg.GetMetadata(i), index)
+				newMeta := newMsg.GetMetadata(index)
+				newParts.GetMetadata(j).Iter(func(k, v string) error {
+					newMeta.Set(k, v)
+					return nil
+				})
+				return nil
+			})
 		} else {
+			d.log.Debugf("Failed to unarchive message part: %v\n", err)
 			d.mErr.Incr(1)
+			index := newMsg.Append(part)
+			newMsg.SetMetadata(msg.GetMetadata(i), index)
+			FlagErr(newMsg, index, err)
 		}
 	}
 
@@ -22,6 +22,7 @@ package processor
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"fmt"
 	"os"
@@ -104,6 +105,151 @@ func TestUnarchiveTar(t *testing.T) {
 	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
 		t.Errorf("Unexpected output: %s != %s", act, exp)
 	}
+	for i := range exp {
+		expName := fmt.Sprintf("testfile%v", i)
+		if act := msgs[0].GetMetadata(i).Get("archive_filename"); act != expName {
+			t.Errorf("Unexpected file name: %v != %v", act, expName)
+		}
+	}
+}
+
+func TestUnarchiveZip(t *testing.T) {
+	conf := NewConfig()
+	conf.Unarchive.Format = "zip"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	exp := [][]byte{
+		[]byte("hello world first part"),
+		[]byte("hello world second part"),
+		[]byte("third part"),
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for i, part := range exp {
+		w, err := zw.Create(fmt.Sprintf("testfile%v", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	input := types.NewMessage([][]byte{buf.Bytes()})
+	input.GetMetadata(0).Set("foo", "bar")
+
+	proc, err := NewUnarchive(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(input)
+	if len(msgs) != 1 {
+		t.Fatal("Unarchive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+	for i := range exp {
+		expName := fmt.Sprintf("testfile%v", i)
+		if act := msgs[0].GetMetadata(i).Get("archive_filename"); act != expName {
+			t.Errorf("Unexpected file name: %v != %v", act, expName)
+		}
+		if act := msgs[0].GetMetadata(i).Get("foo"); act != "bar" {
+			t.Errorf("Unexpected metadata value: %v != %v", act, "bar")
+		}
+	}
+	if act := input.GetMetadata(0).Get("archive_filename"); act != "" {
+		t.Errorf("Original metadata was modified: %v", act)
+	}
+
+	if msgs, _ = proc.ProcessMessage(
+		types.NewMessage([][]byte{[]byte("not a zip")}),
+	); len(msgs) != 1 {
+		t.Error("Expected bad message to be passed through")
+	} else if !HasFailed(msgs[0], 0) {
+		t.Error("Expected bad message to be flagged as failed")
+	}
+}
+
+func TestUnarchiveLines(t *testing.T) {
+	conf := NewConfig()
+	conf.Unarchive.Format = "lines"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewUnarchive(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte("hello world first part\nhello world second part\n\nthird part\n"),
+		[]byte("fourth"),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Unarchive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	exp := [][]byte{
+		[]byte("hello world first part"),
+		[]byte("hello world second part"),
+		[]byte("third part"),
+		[]byte("fourth"),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+}
+
+func TestUnarchiveJSONArray(t *testing.T) {
+	conf := NewConfig()
+	conf.Unarchive.Format = "json_array"
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewUnarchive(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(types.NewMessage([][]byte{
+		[]byte(`[{"foo":"bar"},5,"baz",["qux"]]`),
+		[]byte(`{"not":"an array"}`),
+	}))
+	if len(msgs) != 1 {
+		t.Fatal("Unarchive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+
+	exp := [][]byte{
+		[]byte(`{"foo":"bar"}`),
+		[]byte(`5`),
+		[]byte(`"baz"`),
+		[]byte(`["qux"]`),
+		[]byte(`{"not":"an array"}`),
+	}
+	if act := msgs[0].GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+	for i := 0; i < 4; i++ {
+		if HasFailed(msgs[0], i) {
+			t.Errorf("Part %v was flagged as failed", i)
+		}
+	}
+	if !HasFailed(msgs[0], 4) {
+		t.Error("Expected non-array part to be flagged as failed")
+	}
 }
 
 func TestUnarchiveBinary(t *testing.T) {
@@ -124,8 +270,10 @@ func TestUnarchiveBinary(t *testing.T) {
 	}
 	if msgs, _ := proc.ProcessMessage(
 		types.NewMessage([][]byte{[]byte("wat this isnt good")}),
-	); len(msgs) > 0 {
-		t.Error("Expected fail on bad message")
+	); len(msgs) != 1 {
+		t.Error("Expected bad message to be passed through")
+	} else if !HasFailed(msgs[0], 0) {
+		t.Error("Expected bad message to be flagged as failed")
 	}
 
 	testMsg := types.NewMessage([][]byte{[]byte("hello"), []byte("world")})
@@ -264,7 +412,12 @@ func TestUnarchiveEmpty(t *testing.T) {
 	msgs, _ = proc.ProcessMessage(types.NewMessage(
 		[][]byte{[]byte("first"), []byte("second")},
 	))
-	if len(msgs) != 0 {
-		t.Error("Expected failure with bad data")
+	if len(msgs) != 1 {
+		t.Fatal("Expected bad data to be passed through")
+	}
+	for i := 0; i < 2; i++ {
+		if !HasFailed(msgs[0], i) {
+			t.Errorf("Expected part %v to be flagged as failed", i)
+		}
 	}
 }
@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/gabs"
+)
+
+//------------------------------------------------------------------------------
+
+// errFailed is the error used to flag a failed message part when a more
+// specific reason is not available.
+var errFailed = errors.New("processing failed")
+
+// FlagFail marks a message part as having failed a processing step.
+func FlagFail(msg types.Message, part int) {
+	FlagErr(msg, part, errFailed)
+}
+
+// FlagErr marks a message part as having failed a processing step, the error
+// is stored within the metadata of the part and describes the failure.
+func FlagErr(msg types.Message, part int, err error) {
+	if err == nil {
+		err = errFailed
+	}
+	msg.GetMetadata(part).Set(types.FailFlagKey, err.Error())
+}
+
+// HasFailed checks whether a message part has been flagged as having failed a
+// processing step.
+func HasFailed(msg types.Message, part int) bool {
+	return len(msg.GetMetadata(part).Get(types.FailFlagKey)) > 0
+}
+
+// ClearFail removes any failure flag from a message part.
+func ClearFail(msg types.Message, part int) {
+	msg.GetMetadata(part).Delete(types.FailFlagKey)
+}
+
+//------------------------------------------------------------------------------
+
+// appendParts appends each part of a slice of messages to a target message,
+// including the metadata of each part.
+func appendParts(target types.Message, msgs []types.Message) {
+	for _, m := range msgs {
+		m.Iter(func(i int, part []byte) error {
+			target.SetMetadata(m.GetMetadata(i), target.Append(part))
+			return nil
+		})
+	}
+}
+
+// isolatePart returns a new single part message containing a copy of the
+// metadata of a part from another message.
+func isolatePart(msg types.Message, part int) types.Message {
+	newMsg := types.NewMessage([][]byte{msg.Get(part)})
+	newMsg.SetMetadata(msg.GetMetadata(part))
+	return newMsg
+}
+
+//------------------------------------------------------------------------------
+
+// executeAll applies a list of processors to a message in order, where each
+// processor is applied to every message returned by the previous one. Returns
+// the resulting messages, or the response of the last processor if all
+// messages were removed.
+func executeAll(procs []Type, msg types.Message) ([]types.Message, types.Response) {
+	resultMsgs := []types.Message{msg}
+	var resultRes types.Response
+
+	for i := 0; len(resultMsgs) > 0 && i < len(procs); i++ {
+		var nextResultMsgs []types.Message
+		for _, m := range resultMsgs {
+			var rMsgs []types.Message
+			rMsgs, resultRes = procs[i].ProcessMessage(m)
+			nextResultMsgs = append(nextResultMsgs, rMsgs...)
+		}
+		resultMsgs = nextResultMsgs
+	}
+	return resultMsgs, resultRes
+}
+
+//------------------------------------------------------------------------------
+
+// applyAtJSONPath applies a function to the contents of a message part. If the
+// path is not empty the part is parsed as JSON and the function is applied to
+// the string value at the path instead, which is replaced with the result. A
+// result that is not valid UTF-8 cannot be stored as a JSON string without
+// being altered and therefore returns an error.
+func applyAtJSONPath(
+	path []string, part []byte, fn func([]byte) ([]byte, error),
+) ([]byte, error) {
+	if len(path) == 0 {
+		return fn(part)
+	}
+
+	gPart, err := gabs.ParseJSON(part)
+	if err != nil {
+		return nil, err
+	}
+
+	str, ok := gPart.S(path...).Data().(string)
+	if !ok {
+		return nil, fmt.Errorf("value at path '%v' is not a string", strings.Join(path, "."))
+	}
+
+	newValue, err := fn([]byte(str))
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(newValue) {
+		return nil, fmt.Errorf("result at path '%v' is not valid UTF-8", strings.Join(path, "."))
+	}
+	if _, err = gPart.Set(string(newValue), path...); err != nil {
+		return nil, err
+	}
+	return gPart.Bytes(), nil
+}
+
+//------------------------------------------------------------------------------
+
+// closeAll triggers the closure of any processors that implement
+// types.Closable.
+func closeAll(procs []Type) {
+	for _, p := range procs {
+		if c, ok := p.(types.Closable); ok {
+			c.CloseAsync()
+		}
+	}
+}
+
+// waitForCloseAll blocks until any processors that implement types.Closable
+// have closed, or until the timeout is reached.
+func waitForCloseAll(procs []Type, timeout time.Duration) error {
+	stopBy := time.Now().Add(timeout)
+	for _, p := range procs {
+		if c, ok := p.(types.Closable); ok {
+			if err := c.WaitForClose(time.Until(stopBy)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,160 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors["local"] = TypeSpec{
+		constructor: NewLocal,
+		description: `
+The local rate limit is a token bucket held in memory, which is refilled with
+` + "`count`" + ` tokens every ` + "`interval`" + `. Each access consumes a
+token, and when the bucket is empty components wait until a token becomes
+available.
+
+The bucket holds at most ` + "`burst`" + ` tokens, which is the number of
+accesses that can be made at once after a period of inactivity. When
+` + "`burst`" + ` is zero it is the same as ` + "`count`" + `.
+
+The ` + "`interval`" + ` is a duration string such as ` + "`1s`" + `,
+` + "`100ms`" + ` or ` + "`1m`" + `.
+
+Since the bucket is held in memory the limit applies to a single Benthos
+instance only.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// LocalConfig is a config struct containing rate limit fields for a local rate
+// limit.
+type LocalConfig struct {
+	Count    int    `json:"count" yaml:"count"`
+	Interval string `json:"interval" yaml:"interval"`
+	Burst    int    `json:"burst" yaml:"burst"`
+}
+
+// NewLocalConfig returns a local rate limit configuration struct with default
+// values.
+func NewLocalConfig() LocalConfig {
+	return LocalConfig{
+		Count:    1000,
+		Interval: "1s",
+		Burst:    0,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Local is a token bucket rate limit that is held in memory.
+type Local struct {
+	mut sync.Mutex
+
+	// Tokens added per nanosecond.
+	rate     float64
+	capacity float64
+
+	tokens     float64
+	lastRefill time.Time
+
+	mAccess  metrics.StatCounter
+	mLimited metrics.StatCounter
+}
+
+// NewLocal creates a local rate limit from a configuration struct.
+func NewLocal(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (types.RateLimit, error) {
+	if conf.Local.Count <= 0 {
+		return nil, errors.New("count must be larger than zero")
+	}
+	if conf.Local.Burst < 0 {
+		return nil, errors.New("burst must not be negative")
+	}
+	interval, err := time.ParseDuration(conf.Local.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interval: %v", err)
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be larger than zero")
+	}
+
+	capacity := float64(conf.Local.Burst)
+	if capacity == 0 {
+		capacity = float64(conf.Local.Count)
+	}
+
+	return &Local{
+		rate:       float64(conf.Local.Count) / float64(interval),
+		capacity:   capacity,
+		tokens:     capacity,
+		lastRefill: time.Now(),
+
+		mAccess:  stats.GetCounter("rate_limit.local.access"),
+		mLimited: stats.GetCounter("rate_limit.local.limited"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Access consumes a token if one is available and returns zero, otherwise the
+// time until the next token becomes available is returned.
+func (l *Local) Access() (time.Duration, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.lastRefill)) * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.lastRefill = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		l.mAccess.Incr(1)
+		return 0, nil
+	}
+
+	l.mLimited.Incr(1)
+	wait := time.Duration((1 - l.tokens) / l.rate)
+	if wait <= 0 {
+		wait = 1
+	}
+	return wait, nil
+}
+
+//------------------------------------------------------------------------------
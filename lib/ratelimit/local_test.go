@@ -0,0 +1,169 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+)
+
+var testLog = log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+func TestLocalRateLimitConfErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Local.Count = -1
+	if _, err := NewLocal(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("expected error from bad count")
+	}
+
+	conf = NewConfig()
+	conf.Local.Interval = "nope"
+	if _, err := NewLocal(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("expected error from bad interval")
+	}
+
+	conf = NewConfig()
+	conf.Local.Interval = "-1s"
+	if _, err := NewLocal(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("expected error from negative interval")
+	}
+
+	conf = NewConfig()
+	conf.Local.Burst = -1
+	if _, err := NewLocal(conf, nil, testLog, metrics.DudType{}); err == nil {
+		t.Error("expected error from bad burst")
+	}
+}
+
+func TestLocalRateLimitBasic(t *testing.T) {
+	conf := NewConfig()
+	conf.Local.Count = 10
+	conf.Local.Interval = "1s"
+
+	rl, err := NewLocal(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < conf.Local.Count; i++ {
+		period, _ := rl.Access()
+		if period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+
+	if period, _ := rl.Access(); period == 0 {
+		t.Error("Expected limit on final request")
+	} else if period > time.Millisecond*100 {
+		t.Errorf("Period beyond refill of a single token: %v", period)
+	}
+}
+
+func TestLocalRateLimitRefill(t *testing.T) {
+	conf := NewConfig()
+	conf.Local.Count = 10
+	conf.Local.Interval = "10ms"
+
+	rl, err := NewLocal(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < conf.Local.Count; i++ {
+		period, _ := rl.Access()
+		if period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+
+	period, _ := rl.Access()
+	if period == 0 {
+		t.Fatal("Expected limit on final request")
+	}
+
+	<-time.After(period)
+
+	if period, _ = rl.Access(); period > 0 {
+		t.Errorf("Period above zero after waiting: %v", period)
+	}
+}
+
+func TestLocalRateLimitBurst(t *testing.T) {
+	conf := NewConfig()
+	conf.Local.Count = 100
+	conf.Local.Interval = "1s"
+	conf.Local.Burst = 5
+
+	rl, err := NewLocal(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < conf.Local.Burst; i++ {
+		period, _ := rl.Access()
+		if period > 0 {
+			t.Errorf("Period above zero: %v", period)
+		}
+	}
+
+	if period, _ := rl.Access(); period == 0 {
+		t.Error("Expected limit beyond burst")
+	}
+}
+
+func TestLocalRateLimitParallel(t *testing.T) {
+	conf := NewConfig()
+	conf.Local.Count = 100
+	conf.Local.Interval = "1h"
+
+	rl, err := NewLocal(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var allowed int
+	var allowedMut sync.Mutex
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if period, _ := rl.Access(); period == 0 {
+					allowedMut.Lock()
+					allowed++
+					allowedMut.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if exp, act := conf.Local.Count, allowed; exp != act {
+		t.Errorf("Wrong count of allowed accesses: %v != %v", act, exp)
+	}
+}
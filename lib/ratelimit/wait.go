@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// errorBackoff is the period to wait before retrying a rate limit that returned
+// an error.
+const errorBackoff = time.Second
+
+// Wait blocks until the rate limit allows access to its resource, retrying
+// after a backoff when the rate limit returns an error, which is logged.
+// Returns true if the caller was limited at any point during the wait, and
+// returns types.ErrTypeClosed if closeChan is closed before access is granted.
+func Wait(
+	rl types.RateLimit, closeChan <-chan struct{}, log log.Modular,
+) (limited bool, err error) {
+	for {
+		period, aerr := rl.Access()
+		if aerr != nil {
+			log.Errorf("Failed to access rate limit: %v\n", aerr)
+			period = errorBackoff
+		} else if period <= 0 {
+			return limited, nil
+		}
+		limited = true
+		select {
+		case <-time.After(period):
+		case <-closeChan:
+			return limited, types.ErrTypeClosed
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,141 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type fakeRateLimit struct {
+	sync.Mutex
+
+	accesses int
+	limited  int
+	period   time.Duration
+	errs     []error
+}
+
+func (r *fakeRateLimit) Access() (time.Duration, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.accesses++
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return 0, err
+	}
+	if r.limited > 0 {
+		r.limited--
+		return r.period, nil
+	}
+	return 0, nil
+}
+
+func TestWaitNotLimited(t *testing.T) {
+	rl := &fakeRateLimit{}
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	limited, err := Wait(rl, nil, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limited {
+		t.Error("Expected not limited")
+	}
+	if exp, act := 1, rl.accesses; exp != act {
+		t.Errorf("Wrong count of accesses: %v != %v", act, exp)
+	}
+}
+
+func TestWaitLimited(t *testing.T) {
+	rl := &fakeRateLimit{
+		limited: 2,
+		period:  time.Millisecond * 10,
+	}
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	tStarted := time.Now()
+	limited, err := Wait(rl, nil, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !limited {
+		t.Error("Expected limited")
+	}
+	if since := time.Since(tStarted); since < time.Millisecond*20 {
+		t.Errorf("Wait was not limited: %v", since)
+	}
+	if exp, act := 3, rl.accesses; exp != act {
+		t.Errorf("Wrong count of accesses: %v != %v", act, exp)
+	}
+}
+
+func TestWaitErrored(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode")
+	}
+
+	rl := &fakeRateLimit{
+		errs: []error{errors.New("foo")},
+	}
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	if _, err := Wait(rl, nil, testLog); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, rl.accesses; exp != act {
+		t.Errorf("Wrong count of accesses: %v != %v", act, exp)
+	}
+}
+
+func TestWaitClosed(t *testing.T) {
+	rl := &fakeRateLimit{
+		limited: 1,
+		period:  time.Hour,
+	}
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	closeChan := make(chan struct{})
+	go func() {
+		<-time.After(time.Millisecond * 10)
+		close(closeChan)
+	}()
+
+	limited, err := Wait(rl, closeChan, testLog)
+	if err != types.ErrTypeClosed {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrTypeClosed)
+	}
+	if !limited {
+		t.Error("Expected limited")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -108,6 +108,26 @@ func (n *nsMgr) GetCondition(name string) (types.Condition, error) {
 	return n.mgr.GetCondition(name)
 }
 
+// GetInput attempts to find a service wide input by its name.
+func (n *nsMgr) GetInput(name string) (types.Input, error) {
+	return n.mgr.GetInput(name)
+}
+
+// GetOutput attempts to find a service wide output by its name.
+func (n *nsMgr) GetOutput(name string) (types.OutputWriter, error) {
+	return n.mgr.GetOutput(name)
+}
+
+// GetProcessor attempts to find a service wide processor by its name.
+func (n *nsMgr) GetProcessor(name string) (types.Processor, error) {
+	return n.mgr.GetProcessor(name)
+}
+
+// GetRateLimit attempts to find a service wide rate limit by its name.
+func (n *nsMgr) GetRateLimit(name string) (types.RateLimit, error) {
+	return n.mgr.GetRateLimit(name)
+}
+
 //------------------------------------------------------------------------------
 
 // StreamProcConstructorFunc is a closure type that constructs a processor type
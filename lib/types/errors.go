@@ -38,6 +38,7 @@ var (
 	ErrInvalidProcessorType = errors.New("processor type was not recognised")
 	ErrInvalidCacheType     = errors.New("cache type was not recognised")
 	ErrInvalidConditionType = errors.New("condition type was not recognised")
+	ErrInvalidRateLimitType = errors.New("rate limit type was not recognised")
 	ErrInvalidBufferType    = errors.New("buffer type was not recognised")
 	ErrInvalidInputType     = errors.New("input type was not recognised")
 	ErrInvalidOutputType    = errors.New("output type was not recognised")
@@ -53,6 +54,9 @@ var (
 	ErrBadMessageBytes     = errors.New("serialised message bytes were in unexpected format")
 	ErrBlockCorrupted      = errors.New("serialised messages block was in unexpected format")
 
+	ErrUnsupportedMessageVersion = errors.New("serialised message version is not supported")
+	ErrMessageChecksum           = errors.New("serialised message failed checksum validation")
+
 	ErrNoAck = errors.New("failed to receive acknowledgement")
 )
 
@@ -62,6 +66,10 @@ var (
 var (
 	ErrCacheNotFound     = errors.New("cache not found")
 	ErrConditionNotFound = errors.New("condition not found")
+	ErrInputNotFound     = errors.New("input not found")
+	ErrOutputNotFound    = errors.New("output not found")
+	ErrProcessorNotFound = errors.New("processor not found")
+	ErrRateLimitNotFound = errors.New("rate limit not found")
 	ErrKeyAlreadyExists  = errors.New("key already exists")
 	ErrKeyNotFound       = errors.New("key does not exist")
 )
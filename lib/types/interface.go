@@ -57,6 +57,39 @@ type Condition interface {
 
 //------------------------------------------------------------------------------
 
+// RateLimit is a strategy for limiting access to a shared resource, this
+// strategy can be safely used by components in parallel.
+type RateLimit interface {
+	// Access the rate limited resource. Returns a duration or an error if the
+	// rate limit check fails. The returned duration is either zero (meaning the
+	// resource may be accessed) or a reasonable length of time to wait before
+	// requesting again.
+	Access() (time.Duration, error)
+}
+
+//------------------------------------------------------------------------------
+
+// Processor reads a message, performs some form of data processing to the
+// message, and returns either a slice of >= 1 resulting messages or a response
+// to return to the message origin.
+type Processor interface {
+	// ProcessMessage attempts to process a message.
+	ProcessMessage(msg Message) ([]Message, Response)
+}
+
+//------------------------------------------------------------------------------
+
+// OutputWriter is a shared output that accepts transactions from any number of
+// producers.
+type OutputWriter interface {
+	// WriteTransaction attempts to pass a transaction to the output, blocking
+	// until it is accepted, the output is closed or the timeout is reached. The
+	// response of the transaction is sent to its own response channel.
+	WriteTransaction(t Transaction, timeout time.Duration) error
+}
+
+//------------------------------------------------------------------------------
+
 // Manager is an interface expected by Benthos components that allows them to
 // register their service wide behaviours such as HTTP endpoints and event
 // listeners, and obtain service wide shared resources such as caches.
@@ -69,6 +102,18 @@ type Manager interface {
 
 	// GetCondition attempts to find a service wide condition by its name.
 	GetCondition(name string) (Condition, error)
+
+	// GetInput attempts to find a service wide input by its name.
+	GetInput(name string) (Input, error)
+
+	// GetOutput attempts to find a service wide output by its name.
+	GetOutput(name string) (OutputWriter, error)
+
+	// GetProcessor attempts to find a service wide processor by its name.
+	GetProcessor(name string) (Processor, error)
+
+	// GetRateLimit attempts to find a service wide rate limit by its name.
+	GetRateLimit(name string) (RateLimit, error)
 }
 
 //------------------------------------------------------------------------------
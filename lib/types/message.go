@@ -65,6 +65,22 @@ type Message interface {
 	// found by counting backwards from the last part starting at -1.
 	SetJSON(p int, jObj interface{}) error
 
+	// GetMetadata returns the metadata of a message part. The result can be
+	// edited in order to change the metadata of the part. If the index is
+	// negative then the part is found by counting backwards from the last part
+	// starting at -1. If the index is out of bounds then an empty metadata
+	// object is returned that is not attached to the message.
+	GetMetadata(p int) Metadata
+
+	// SetMetadata sets the metadata of message parts by their index. Multiple
+	// indexes can be specified in order to set the same metadata values to
+	// each part, in which case each part receives its own copy. If zero
+	// indexes are specified the metadata is set for all message parts. If an
+	// index is negative then the part is found by counting backwards from the
+	// last part starting at -1. If an index is out of bounds then nothing is
+	// done.
+	SetMetadata(m Metadata, p ...int)
+
 	// Append appends new message parts to the message and returns the index of
 	// last part to be added.
 	Append(b ...[]byte) int
@@ -81,17 +97,21 @@ type Message interface {
 	// call can itself be the part of a new message, which is a useful way of
 	// transporting multiple part messages across protocols that only support
 	// single parts.
+	//
+	// This format does not preserve the creation time or metadata of the
+	// message, use `Encode` in order to serialise the message with those
+	// included.
 	Bytes() []byte
 
 	// LazyCondition lazily evaluates conditions on the message by caching the
 	// results as per a label to identify the condition. The cache of results is
-	// cleared whenever the contents of the message is changed.
+	// cleared whenever the contents or metadata of the message is changed.
 	LazyCondition(label string, cond Condition) bool
 
 	// ShallowCopy creates a shallow copy of the message, where the list of
-	// message parts can be edited independently from the original version.
-	// However, editing the byte array contents of a message part will still
-	// alter the contents of the original.
+	// message parts and their metadata can be edited independently from the
+	// original version. However, editing the byte array contents of a message
+	// part will still alter the contents of the original.
 	ShallowCopy() Message
 
 	// DeepCopy creates a deep copy of the message, where the message part
@@ -160,6 +180,7 @@ type messageImpl struct {
 	createdAt   time.Time
 	parts       [][]byte
 	partCaches  []*partCache
+	metadata    []Metadata
 	resultCache map[string]bool
 }
 
@@ -235,6 +256,7 @@ func (m *messageImpl) ShallowCopy() Message {
 	return &messageImpl{
 		createdAt:   m.createdAt,
 		parts:       append([][]byte(nil), m.parts...),
+		metadata:    m.copyMetadata(),
 		resultCache: m.resultCache,
 	}
 }
@@ -251,9 +273,24 @@ func (m *messageImpl) DeepCopy() Message {
 	return &messageImpl{
 		createdAt: m.createdAt,
 		parts:     newParts,
+		metadata:  m.copyMetadata(),
 	}
 }
 
+// copyMetadata returns a copy of the metadata of each part.
+func (m *messageImpl) copyMetadata() []Metadata {
+	if len(m.metadata) == 0 {
+		return nil
+	}
+	newMetadata := make([]Metadata, len(m.metadata))
+	for i, md := range m.metadata {
+		if md != nil {
+			newMetadata[i] = md.Copy()
+		}
+	}
+	return newMetadata
+}
+
 //------------------------------------------------------------------------------
 
 func (m *messageImpl) Get(index int) []byte {
@@ -287,6 +324,7 @@ func (m *messageImpl) Set(index int, b []byte) {
 
 func (m *messageImpl) SetAll(p [][]byte) {
 	m.parts = p
+	m.metadata = nil
 	m.clearAllCaches()
 }
 
@@ -320,6 +358,15 @@ func (m *messageImpl) expandCache(index int) {
 	m.partCaches = cParts
 }
 
+func (m *messageImpl) expandMetadata(index int) {
+	if len(m.metadata) > index {
+		return
+	}
+	newMetadata := make([]Metadata, index+1)
+	copy(newMetadata, m.metadata)
+	m.metadata = newMetadata
+}
+
 func (m *messageImpl) clearGeneralCaches() {
 	m.resultCache = nil
 }
@@ -370,6 +417,66 @@ func (m *messageImpl) SetJSON(part int, jObj interface{}) error {
 	return nil
 }
 
+func (m *messageImpl) GetMetadata(part int) Metadata {
+	if part < 0 {
+		part = len(m.parts) + part
+	}
+	if part < 0 || part >= len(m.parts) {
+		return NewMetadata(nil)
+	}
+	m.expandMetadata(part)
+	md := m.metadata[part]
+	if md == nil {
+		md = NewMetadata(nil)
+		m.metadata[part] = md
+	}
+	return &partMetadata{Metadata: md, msg: m}
+}
+
+func (m *messageImpl) SetMetadata(md Metadata, parts ...int) {
+	if len(parts) == 0 {
+		for i := range m.parts {
+			m.setMetadata(md.Copy(), i)
+		}
+		return
+	}
+	for _, i := range parts {
+		m.setMetadata(md.Copy(), i)
+	}
+}
+
+func (m *messageImpl) setMetadata(md Metadata, part int) {
+	if part < 0 {
+		part = len(m.parts) + part
+	}
+	if part < 0 || part >= len(m.parts) {
+		return
+	}
+	m.expandMetadata(part)
+	m.metadata[part] = md
+	m.clearGeneralCaches()
+}
+
+// partMetadata is the metadata of a message part, which clears the cached
+// condition results of its message when edited since they might depend on the
+// metadata.
+type partMetadata struct {
+	Metadata
+	msg *messageImpl
+}
+
+func (p *partMetadata) Set(key, value string) Metadata {
+	p.msg.clearGeneralCaches()
+	p.Metadata.Set(key, value)
+	return p
+}
+
+func (p *partMetadata) Delete(key string) Metadata {
+	p.msg.clearGeneralCaches()
+	p.Metadata.Delete(key)
+	return p
+}
+
 func (m *messageImpl) LazyCondition(label string, cond Condition) bool {
 	if m.resultCache == nil {
 		m.resultCache = map[string]bool{}
@@ -0,0 +1,245 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"sort"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+/*
+Versioned message blob format:
+
+- Four bytes containing the magic header 0xFF 'B' 'M' 'F'
+- One byte containing the format version (currently 1)
+- One byte containing flags, bit 0 set means a checksum is appended
+- Eight bytes containing the creation time of the message in unix nanoseconds
+  (i64 big endian)
+- Four bytes containing number of message parts (u32 big endian)
+- For each message part:
+    + Four bytes containing the number of metadata pairs (u32 big endian)
+    + For each metadata pair:
+        * Four bytes containing the length of the key (u32 big endian)
+        * Content of the key
+        * Four bytes containing the length of the value (u32 big endian)
+        * Content of the value
+    + Four bytes containing length of message part (u32 big endian)
+    + Content of message part
+- If the checksum flag is set, four bytes containing a CRC-32 (IEEE) checksum
+  of all preceding bytes (u32 big endian)
+
+The magic header cannot be mistaken for the original blob format produced by
+Bytes, as it would describe a message of more parts than could fit within a
+blob of a realistic size.
+*/
+
+var encodingMagic = []byte{0xFF, 'B', 'M', 'F'}
+
+const (
+	encodingVersion      byte = 1
+	encodingFlagChecksum byte = 1 << 0
+)
+
+//------------------------------------------------------------------------------
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendBlob(b []byte, v []byte) []byte {
+	return append(appendUint32(b, uint32(len(v))), v...)
+}
+
+// Encode serialises a message into a versioned binary format that, unlike
+// Bytes, also preserves the creation time and metadata of the message. The
+// result can be parsed back into a message with Decode.
+func Encode(msg Message) []byte {
+	return encode(msg, false)
+}
+
+// EncodeWithChecksum serialises a message into the same format as Encode, and
+// appends a checksum that is verified by Decode.
+func EncodeWithChecksum(msg Message) []byte {
+	return encode(msg, true)
+}
+
+func encode(msg Message, checksum bool) []byte {
+	var flags byte
+	if checksum {
+		flags |= encodingFlagChecksum
+	}
+
+	b := make([]byte, 0, len(encodingMagic)+18)
+	b = append(b, encodingMagic...)
+	b = append(b, encodingVersion, flags)
+
+	createdAt := uint64(msg.CreatedAt().UnixNano())
+	b = appendUint32(b, uint32(createdAt>>32))
+	b = appendUint32(b, uint32(createdAt))
+
+	b = appendUint32(b, uint32(msg.Len()))
+	msg.Iter(func(i int, part []byte) error {
+		// Sort keys so that the same message always results in the same blob.
+		keys := []string{}
+		md := msg.GetMetadata(i)
+		md.Iter(func(k, v string) error {
+			keys = append(keys, k)
+			return nil
+		})
+		sort.Strings(keys)
+
+		b = appendUint32(b, uint32(len(keys)))
+		for _, k := range keys {
+			b = appendBlob(b, []byte(k))
+			b = appendBlob(b, []byte(md.Get(k)))
+		}
+		b = appendBlob(b, part)
+		return nil
+	})
+
+	if checksum {
+		b = appendUint32(b, crc32.ChecksumIEEE(b))
+	}
+	return b
+}
+
+//------------------------------------------------------------------------------
+
+// blobReader reads length prefixed values from a serialised message.
+type blobReader struct {
+	b []byte
+}
+
+func (r *blobReader) uint32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, ErrBadMessageBytes
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+func (r *blobReader) blob() ([]byte, error) {
+	l, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(r.b)) < l {
+		return nil, ErrBadMessageBytes
+	}
+	v := r.b[:l]
+	r.b = r.b[l:]
+	return v, nil
+}
+
+// Decode deserialises a message from a byte array in either the versioned
+// format produced by Encode or the original format produced by Bytes.
+func Decode(b []byte) (Message, error) {
+	if !bytes.HasPrefix(b, encodingMagic) {
+		return FromBytes(b)
+	}
+	b = b[len(encodingMagic):]
+	if len(b) < 2 {
+		return nil, ErrBadMessageBytes
+	}
+	if b[0] != encodingVersion {
+		return nil, ErrUnsupportedMessageVersion
+	}
+
+	if flags := b[1]; flags&encodingFlagChecksum != 0 {
+		if len(b) < 6 {
+			return nil, ErrBadMessageBytes
+		}
+		sumIndex := len(b) - 4
+		expSum := binary.BigEndian.Uint32(b[sumIndex:])
+		crc := crc32.NewIEEE()
+		crc.Write(encodingMagic)
+		crc.Write(b[:sumIndex])
+		if crc.Sum32() != expSum {
+			return nil, ErrMessageChecksum
+		}
+		b = b[:sumIndex]
+	}
+
+	r := &blobReader{b: b[2:]}
+
+	createdHigh, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	createdLow, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	createdAt := int64(uint64(createdHigh)<<32 | uint64(createdLow))
+
+	numParts, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if numParts > uint32(len(r.b)) {
+		return nil, ErrBadMessageBytes
+	}
+
+	m := &messageImpl{
+		createdAt: time.Unix(0, createdAt),
+		parts:     make([][]byte, 0, numParts),
+		metadata:  make([]Metadata, 0, numParts),
+	}
+	for i := uint32(0); i < numParts; i++ {
+		var numPairs uint32
+		if numPairs, err = r.uint32(); err != nil {
+			return nil, err
+		}
+		if numPairs > uint32(len(r.b)) {
+			return nil, ErrBadMessageBytes
+		}
+		kvs := make(map[string]string, numPairs)
+		for j := uint32(0); j < numPairs; j++ {
+			var k, v []byte
+			if k, err = r.blob(); err != nil {
+				return nil, err
+			}
+			if v, err = r.blob(); err != nil {
+				return nil, err
+			}
+			kvs[string(k)] = string(v)
+		}
+
+		var part []byte
+		if part, err = r.blob(); err != nil {
+			return nil, err
+		}
+		m.parts = append(m.parts, part)
+		m.metadata = append(m.metadata, NewMetadata(kvs))
+	}
+	if len(r.b) > 0 {
+		return nil, ErrBadMessageBytes
+	}
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := NewMessage([][]byte{
+		[]byte("hello"),
+		[]byte(""),
+		[]byte("world"),
+	})
+	m.GetMetadata(0).Set("foo", "bar").Set("baz", "qux")
+	m.GetMetadata(2).Set("foo", "bar2")
+
+	for _, b := range [][]byte{Encode(m), EncodeWithChecksum(m)} {
+		m2, err := Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := m.GetAll(), m2.GetAll(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong parts: %s != %s", act, exp)
+		}
+		if exp, act := m.CreatedAt(), m2.CreatedAt(); !exp.Equal(act) {
+			t.Errorf("Wrong created at: %v != %v", act, exp)
+		}
+		for i := 0; i < m.Len(); i++ {
+			exp, act := map[string]string{}, map[string]string{}
+			m.GetMetadata(i).Iter(func(k, v string) error {
+				exp[k] = v
+				return nil
+			})
+			m2.GetMetadata(i).Iter(func(k, v string) error {
+				act[k] = v
+				return nil
+			})
+			if !reflect.DeepEqual(exp, act) {
+				t.Errorf("Wrong metadata for part %v: %v != %v", i, act, exp)
+			}
+		}
+	}
+}
+
+func TestEncodeDeterministic(t *testing.T) {
+	m := NewMessage([][]byte{[]byte("hello")})
+	m.GetMetadata(0).Set("a", "1").Set("b", "2").Set("c", "3")
+
+	exp := Encode(m)
+	for i := 0; i < 10; i++ {
+		if act := Encode(m); !reflect.DeepEqual(exp, act) {
+			t.Fatalf("Encoding not deterministic: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	m := NewMessage([][]byte{
+		[]byte("hello"),
+		[]byte("world"),
+	})
+
+	m2, err := Decode(m.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := m.GetAll(), m2.GetAll(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong parts: %s != %s", act, exp)
+	}
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	m := NewMessage(nil)
+	m2, err := Decode(EncodeWithChecksum(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := m2.Len(); act != 0 {
+		t.Errorf("Wrong count of parts: %v != 0", act)
+	}
+}
+
+func TestDecodeChecksum(t *testing.T) {
+	m := NewMessage([][]byte{[]byte("hello world")})
+	m.GetMetadata(0).Set("foo", "bar")
+
+	b := EncodeWithChecksum(m)
+	for i := len(encodingMagic); i < len(b); i++ {
+		corrupted := make([]byte, len(b))
+		copy(corrupted, b)
+		corrupted[i] ^= 0x01
+		if _, err := Decode(corrupted); err == nil {
+			t.Errorf("Expected error from corrupted byte %v", i)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	m := NewMessage([][]byte{[]byte("hello world")})
+	m.GetMetadata(0).Set("foo", "bar")
+	b := Encode(m)
+
+	for i := len(encodingMagic); i < len(b); i++ {
+		if _, err := Decode(b[:i]); err == nil {
+			t.Errorf("Expected error from truncated blob of length %v", i)
+		}
+	}
+
+	badVersion := make([]byte, len(b))
+	copy(badVersion, b)
+	badVersion[len(encodingMagic)] = 0xFF
+	if _, err := Decode(badVersion); err != ErrUnsupportedMessageVersion {
+		t.Errorf("Wrong error from bad version: %v != %v", err, ErrUnsupportedMessageVersion)
+	}
+
+	if _, err := Decode(append(b, 0)); err != ErrBadMessageBytes {
+		t.Errorf("Wrong error from trailing bytes: %v != %v", err, ErrBadMessageBytes)
+	}
+}
+
+func TestDecodeCreatedAt(t *testing.T) {
+	tStamp := time.Unix(1530000000, 123456789)
+	m := &messageImpl{
+		createdAt: tStamp,
+		parts:     [][]byte{[]byte("hello")},
+	}
+
+	m2, err := Decode(Encode(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := m2.CreatedAt(); !act.Equal(tStamp) {
+		t.Errorf("Wrong created at: %v != %v", act, tStamp)
+	}
+}
@@ -378,6 +378,42 @@ func TestMessageConditionCaching(t *testing.T) {
 	}
 }
 
+func TestMessageConditionCachingMetadata(t *testing.T) {
+	msg := NewMessage([][]byte{[]byte(`foo`)})
+
+	cond := &dummyCond{
+		call: func(m Message) bool {
+			return m.GetMetadata(0).Get("foo") == "bar"
+		},
+	}
+
+	if msg.LazyCondition("1", cond) {
+		t.Error("Wrong result from cond")
+	}
+
+	msg.GetMetadata(0).Set("foo", "bar")
+	if !msg.LazyCondition("1", cond) {
+		t.Error("Wrong result from cond after metadata set")
+	}
+
+	msg.GetMetadata(0).Delete("foo")
+	if msg.LazyCondition("1", cond) {
+		t.Error("Wrong result from cond after metadata delete")
+	}
+
+	msg.SetMetadata(NewMetadata(map[string]string{"foo": "bar"}), 0)
+	if !msg.LazyCondition("1", cond) {
+		t.Error("Wrong result from cond after metadata replaced")
+	}
+	if !msg.LazyCondition("1", cond) {
+		t.Error("Wrong result from cached cond")
+	}
+
+	if exp, act := 4, cond.calls; exp != act {
+		t.Errorf("Wrong count of calls for cond: %v != %v", act, exp)
+	}
+}
+
 func TestMessageCrossContaminateJSON(t *testing.T) {
 	msg1 := messageImpl{
 		parts: [][]byte{
@@ -497,3 +533,84 @@ func BenchmarkJSONGet(b *testing.B) {
 		}
 	}
 }
+
+func TestMessageMetadata(t *testing.T) {
+	m := NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	})
+
+	m.GetMetadata(0).Set("foo", "bar")
+	if exp, act := "bar", m.GetMetadata(0).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+	if exp, act := "", m.GetMetadata(1).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+
+	m.GetMetadata(-1).Set("foo", "bar2")
+	if exp, act := "bar2", m.GetMetadata(1).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+
+	m.GetMetadata(5).Set("foo", "nope")
+	if exp, act := "", m.GetMetadata(5).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+
+	m.SetMetadata(NewMetadata(map[string]string{"baz": "qux"}))
+	for i := 0; i < m.Len(); i++ {
+		if exp, act := "qux", m.GetMetadata(i).Get("baz"); exp != act {
+			t.Errorf("Wrong metadata value: %v != %v", act, exp)
+		}
+		if exp, act := "", m.GetMetadata(i).Get("foo"); exp != act {
+			t.Errorf("Wrong metadata value: %v != %v", act, exp)
+		}
+	}
+
+	// Parts set in bulk should receive independent copies.
+	m.GetMetadata(0).Set("baz", "changed")
+	if exp, act := "qux", m.GetMetadata(1).Get("baz"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+
+	m.SetMetadata(NewMetadata(map[string]string{"foo": "bar3"}), -1)
+	if exp, act := "bar3", m.GetMetadata(1).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+	if exp, act := "", m.GetMetadata(0).Get("foo"); exp != act {
+		t.Errorf("Wrong metadata value: %v != %v", act, exp)
+	}
+
+	m.Set(0, []byte("new content"))
+	if exp, act := "changed", m.GetMetadata(0).Get("baz"); exp != act {
+		t.Errorf("Metadata lost after setting content: %v != %v", act, exp)
+	}
+
+	m.SetAll([][]byte{[]byte("replaced")})
+	if exp, act := "", m.GetMetadata(0).Get("baz"); exp != act {
+		t.Errorf("Metadata retained after replacing parts: %v != %v", act, exp)
+	}
+}
+
+func TestMessageMetadataCopies(t *testing.T) {
+	m := NewMessage([][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+	})
+	m.GetMetadata(0).Set("foo", "bar")
+
+	for _, mCopy := range []Message{m.ShallowCopy(), m.DeepCopy()} {
+		if exp, act := "bar", mCopy.GetMetadata(0).Get("foo"); exp != act {
+			t.Errorf("Wrong metadata value in copy: %v != %v", act, exp)
+		}
+		mCopy.GetMetadata(0).Set("foo", "changed")
+		mCopy.GetMetadata(1).Set("baz", "qux")
+		if exp, act := "bar", m.GetMetadata(0).Get("foo"); exp != act {
+			t.Errorf("Original metadata was modified: %v != %v", act, exp)
+		}
+		if exp, act := "", m.GetMetadata(1).Get("baz"); exp != act {
+			t.Errorf("Original metadata was modified: %v != %v", act, exp)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package types
+
+//------------------------------------------------------------------------------
+
+// FailFlagKey is a metadata key used for flagging message parts that have
+// failed a processing step. If a message part has any non-empty value for this
+// key then it is considered to have failed, and the value is a description of
+// the failure.
+const FailFlagKey = "benthos_processing_failed"
+
+//------------------------------------------------------------------------------
+
+// Metadata is an interface representing the metadata of a message part.
+// Metadata is a map of string keys to string values, and is useful for carrying
+// context about a message part that is not represented within its contents,
+// such as the Kafka topic it was consumed from.
+type Metadata interface {
+	// Get returns a metadata value if a key exists, otherwise an empty string.
+	Get(key string) string
+
+	// Set sets the value of a metadata key and returns the metadata object in
+	// order to allow chaining.
+	Set(key, value string) Metadata
+
+	// Delete removes the value of a metadata key and returns the metadata
+	// object in order to allow chaining.
+	Delete(key string) Metadata
+
+	// Iter iterates each metadata key/value pair, calling the closure argument
+	// with each pair. If the closure returns an error the iteration is stopped
+	// and the error is returned.
+	Iter(f func(k, v string) error) error
+
+	// Copy returns a copy of the metadata object that can be edited without
+	// changing the contents of the original.
+	Copy() Metadata
+}
+
+//------------------------------------------------------------------------------
+
+// metadataImpl is a simple map based implementation of Metadata.
+type metadataImpl struct {
+	m map[string]string
+}
+
+// NewMetadata creates a new Metadata object populated with an initial set of
+// key/value pairs. The map argument is not copied and therefore should not be
+// edited after this call.
+func NewMetadata(kvs map[string]string) Metadata {
+	return &metadataImpl{
+		m: kvs,
+	}
+}
+
+// Get returns a metadata value if a key exists, otherwise an empty string.
+func (m *metadataImpl) Get(key string) string {
+	return m.m[key]
+}
+
+// Set sets the value of a metadata key.
+func (m *metadataImpl) Set(key, value string) Metadata {
+	if m.m == nil {
+		m.m = map[string]string{}
+	}
+	m.m[key] = value
+	return m
+}
+
+// Delete removes the value of a metadata key.
+func (m *metadataImpl) Delete(key string) Metadata {
+	delete(m.m, key)
+	return m
+}
+
+// Iter iterates each metadata key/value pair.
+func (m *metadataImpl) Iter(f func(k, v string) error) error {
+	for k, v := range m.m {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy returns a copy of the metadata object that can be edited without
+// changing the contents of the original.
+func (m *metadataImpl) Copy() Metadata {
+	var newMap map[string]string
+	if len(m.m) > 0 {
+		newMap = make(map[string]string, len(m.m))
+		for k, v := range m.m {
+			newMap[k] = v
+		}
+	}
+	return &metadataImpl{
+		m: newMap,
+	}
+}
+
+//------------------------------------------------------------------------------
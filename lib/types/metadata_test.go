@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataBasic(t *testing.T) {
+	md := NewMetadata(map[string]string{
+		"foo": "bar",
+	})
+
+	if exp, act := "bar", md.Get("foo"); exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+	if exp, act := "", md.Get("baz"); exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+
+	md.Set("baz", "qux").Set("foo", "bar2").Delete("nope")
+
+	act := map[string]string{}
+	if err := md.Iter(func(k, v string) error {
+		act[k] = v
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]string{
+		"foo": "bar2",
+		"baz": "qux",
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+
+	md.Delete("foo")
+	if exp, act := "", md.Get("foo"); exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+}
+
+func TestMetadataEmpty(t *testing.T) {
+	md := NewMetadata(nil)
+	md.Delete("foo")
+	if err := md.Iter(func(k, v string) error {
+		t.Errorf("Unexpected key: %v", k)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	md.Set("foo", "bar")
+	if exp, act := "bar", md.Get("foo"); exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+}
+
+func TestMetadataCopy(t *testing.T) {
+	md := NewMetadata(map[string]string{
+		"foo": "bar",
+	})
+
+	mdCopy := md.Copy()
+	mdCopy.Set("foo", "changed").Set("baz", "qux")
+
+	if exp, act := "bar", md.Get("foo"); exp != act {
+		t.Errorf("Original was modified: %v != %v", act, exp)
+	}
+	if exp, act := "", md.Get("baz"); exp != act {
+		t.Errorf("Original was modified: %v != %v", act, exp)
+	}
+	if exp, act := "changed", mdCopy.Get("foo"); exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+}
@@ -42,3 +42,23 @@ func (f DudMgr) GetCache(name string) (Cache, error) {
 func (f DudMgr) GetCondition(name string) (Condition, error) {
 	return nil, ErrConditionNotFound
 }
+
+// GetInput always returns ErrInputNotFound.
+func (f DudMgr) GetInput(name string) (Input, error) {
+	return nil, ErrInputNotFound
+}
+
+// GetOutput always returns ErrOutputNotFound.
+func (f DudMgr) GetOutput(name string) (OutputWriter, error) {
+	return nil, ErrOutputNotFound
+}
+
+// GetProcessor always returns ErrProcessorNotFound.
+func (f DudMgr) GetProcessor(name string) (Processor, error) {
+	return nil, ErrProcessorNotFound
+}
+
+// GetRateLimit always returns ErrRateLimitNotFound.
+func (f DudMgr) GetRateLimit(name string) (RateLimit, error) {
+	return nil, ErrRateLimitNotFound
+}
@@ -20,6 +20,10 @@
 
 package types
 
+import (
+	"sync"
+)
+
 //------------------------------------------------------------------------------
 
 // Response is a response from an output, agent or broker that confirms the
@@ -82,3 +86,42 @@ func NewUnacknowledgedResponse() UnacknowledgedResponse {
 }
 
 //------------------------------------------------------------------------------
+
+// DeferredResponse is a response type that indicates the message has been
+// accepted but cannot be acknowledged until a later event, such as a buffered
+// batch being flushed. Once that event has occurred the response is resolved,
+// at which point the message, and all messages before it, can be acknowledged.
+type DeferredResponse struct {
+	once     sync.Once
+	resolved chan struct{}
+}
+
+// NewDeferredResponse returns an unresolved DeferredResponse.
+func NewDeferredResponse() *DeferredResponse {
+	return &DeferredResponse{
+		resolved: make(chan struct{}),
+	}
+}
+
+// Error returns the underlying error.
+func (d *DeferredResponse) Error() error { return nil }
+
+// SkipAck indicates whether a successful message should be acknowledged.
+func (d *DeferredResponse) SkipAck() bool {
+	return true
+}
+
+// Resolved returns a channel that is closed once the response is resolved.
+func (d *DeferredResponse) Resolved() <-chan struct{} {
+	return d.resolved
+}
+
+// Resolve marks the response as resolved. Calling Resolve more than once has
+// no effect.
+func (d *DeferredResponse) Resolve() {
+	d.once.Do(func() {
+		close(d.resolved)
+	})
+}
+
+//------------------------------------------------------------------------------
@@ -47,3 +47,29 @@ func TestUnackResponse(t *testing.T) {
 		t.Error("Should have received skip ack on unack response")
 	}
 }
+
+func TestDeferredResponse(t *testing.T) {
+	res := NewDeferredResponse()
+
+	if res.Error() != nil {
+		t.Error(res.Error())
+	}
+	if !res.SkipAck() {
+		t.Error("Should have received skip ack on deferred response")
+	}
+
+	select {
+	case <-res.Resolved():
+		t.Error("Deferred response resolved early")
+	default:
+	}
+
+	res.Resolve()
+	res.Resolve()
+
+	select {
+	case <-res.Resolved():
+	default:
+		t.Error("Deferred response not resolved")
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/ratelimit"
+	"github.com/Jeffail/benthos/lib/types"
+	"github.com/Jeffail/benthos/lib/util/http/auth"
+	"github.com/Jeffail/benthos/lib/util/text"
+	"github.com/Jeffail/benthos/lib/util/throttle"
+)
+
+//------------------------------------------------------------------------------
+
+// Config is configuration for the HTTP client.
+type Config struct {
+	URL            string `json:"url" yaml:"url"`
+	Verb           string `json:"verb" yaml:"verb"`
+	ContentType    string `json:"content_type" yaml:"content_type"`
+	TimeoutMS      int64  `json:"timeout_ms" yaml:"timeout_ms"`
+	RetryMS        int64  `json:"retry_period_ms" yaml:"retry_period_ms"`
+	MaxBackoffMS   int64  `json:"max_retry_backoff_ms" yaml:"max_retry_backoff_ms"`
+	NumRetries     int    `json:"retries" yaml:"retries"`
+	BackoffOn      []int  `json:"backoff_on" yaml:"backoff_on"`
+	DropOn         []int  `json:"drop_on" yaml:"drop_on"`
+	SkipCertVerify bool   `json:"skip_cert_verify" yaml:"skip_cert_verify"`
+	RateLimit      string `json:"rate_limit" yaml:"rate_limit"`
+	auth.Config    `json:",inline" yaml:",inline"`
+}
+
+// NewConfig creates a new Config with default values.
+func NewConfig() Config {
+	return Config{
+		URL:            "http://localhost:4195/post",
+		Verb:           "POST",
+		ContentType:    "application/octet-stream",
+		TimeoutMS:      5000,
+		RetryMS:        1000,
+		MaxBackoffMS:   300000,
+		NumRetries:     3,
+		BackoffOn:      []int{429},
+		DropOn:         []int{},
+		SkipCertVerify: false,
+		RateLimit:      "",
+		Config:         auth.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Type is an HTTP client that sends messages as requests to a configured URL,
+// retrying failed requests.
+type Type struct {
+	client http.Client
+
+	backoffOn map[int]struct{}
+	dropOn    map[int]struct{}
+
+	conf          Config
+	retryThrottle *throttle.Type
+
+	urlBytes       []byte
+	interpolateURL bool
+
+	log       log.Modular
+	mgr       types.Manager
+	rateLimit types.RateLimit
+
+	closeChan <-chan struct{}
+}
+
+// New creates a new HTTP client. If a rate limit is configured then a manager
+// must be provided with OptSetManager.
+func New(conf Config, opts ...func(*Type)) (*Type, error) {
+	h := Type{
+		conf:      conf,
+		backoffOn: map[int]struct{}{},
+		dropOn:    map[int]struct{}{},
+		log:       log.Noop(),
+	}
+
+	h.urlBytes = []byte(conf.URL)
+	h.interpolateURL = text.ContainsFunctionVariables(h.urlBytes)
+
+	h.client.Timeout = time.Duration(h.conf.TimeoutMS) * time.Millisecond
+	if h.conf.SkipCertVerify {
+		h.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	for _, c := range conf.BackoffOn {
+		h.backoffOn[c] = struct{}{}
+	}
+	for _, c := range conf.DropOn {
+		h.dropOn[c] = struct{}{}
+	}
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	if len(conf.RateLimit) > 0 {
+		if h.mgr == nil {
+			return nil, fmt.Errorf("no manager provided for rate limit '%v'", conf.RateLimit)
+		}
+		var err error
+		if h.rateLimit, err = h.mgr.GetRateLimit(conf.RateLimit); err != nil {
+			return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.RateLimit, err)
+		}
+	}
+
+	h.retryThrottle = throttle.New(
+		throttle.OptMaxUnthrottledRetries(0),
+		throttle.OptCloseChan(h.closeChan),
+		throttle.OptThrottlePeriod(time.Millisecond*time.Duration(conf.RetryMS)),
+		throttle.OptMaxExponentPeriod(time.Millisecond*time.Duration(conf.MaxBackoffMS)),
+	)
+
+	return &h, nil
+}
+
+//------------------------------------------------------------------------------
+
+// OptSetCloseChan sets a channel that when closed will interrupt any retries
+// of a request.
+func OptSetCloseChan(c <-chan struct{}) func(*Type) {
+	return func(t *Type) {
+		t.closeChan = c
+	}
+}
+
+// OptSetLogger sets the logger used for reporting errors that are not returned
+// to the caller, such as failed rate limit accesses.
+func OptSetLogger(l log.Modular) func(*Type) {
+	return func(t *Type) {
+		t.log = l
+	}
+}
+
+// OptSetManager sets the manager used for obtaining resources such as rate
+// limits.
+func OptSetManager(mgr types.Manager) func(*Type) {
+	return func(t *Type) {
+		t.mgr = mgr
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// createRequest creates an HTTP request out of a single message.
+func (h *Type) createRequest(msg types.Message) (req *http.Request, err error) {
+	url := h.conf.URL
+	if h.interpolateURL {
+		url = string(text.ReplaceFunctionVariablesFor(msg, h.urlBytes))
+	}
+
+	if len(msg.GetAll()) == 1 {
+		body := bytes.NewBuffer(msg.GetAll()[0])
+		if req, err = http.NewRequest(
+			h.conf.Verb,
+			url,
+			body,
+		); err == nil {
+			req.Header.Add("Content-Type", h.conf.ContentType)
+		}
+	} else {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+
+		for i := 0; i < msg.Len() && err == nil; i++ {
+			var part io.Writer
+			if part, err = writer.CreatePart(textproto.MIMEHeader{
+				"Content-Type": []string{h.conf.ContentType},
+			}); err == nil {
+				_, err = io.Copy(part, bytes.NewReader(msg.Get(i)))
+			}
+		}
+
+		writer.Close()
+		if req, err = http.NewRequest(
+			h.conf.Verb,
+			url,
+			body,
+		); err == nil {
+			req.Header.Add("Content-Type", writer.FormDataContentType())
+		}
+	}
+	if err != nil {
+		return
+	}
+	err = h.conf.Config.Sign(req)
+	return
+}
+
+// checkStatus compares a returned status code against configured logic
+// determining whether the send is resolved, and if not whether the retry should
+// be linear.
+func (h *Type) checkStatus(code int) (resolved bool, linearRetry bool) {
+	if _, exists := h.dropOn[code]; exists {
+		return true, false
+	}
+	if _, exists := h.backoffOn[code]; exists {
+		return false, false
+	}
+	if code < 200 || code > 299 {
+		return false, true
+	}
+	return true, false
+}
+
+// do sends a request and checks the status of the response, returning the
+// response if it is resolved.
+func (h *Type) do(req *http.Request) (res *http.Response, rateLimited bool, err error) {
+	if h.rateLimit != nil {
+		if _, err = ratelimit.Wait(h.rateLimit, h.closeChan, h.log); err != nil {
+			return nil, false, err
+		}
+	}
+	if res, err = h.client.Do(req); err == nil {
+		if resolved, linear := h.checkStatus(res.StatusCode); !resolved {
+			rateLimited = !linear
+			err = types.ErrUnexpectedHTTPRes{Code: res.StatusCode, S: res.Status}
+			res.Body.Close()
+			res = nil
+		}
+	}
+	return
+}
+
+// Send attempts to send a message to an HTTP server, this attempt may include
+// retries, and if all retries fail an error is returned. When successful the
+// response is returned and its body must be closed by the caller.
+func (h *Type) Send(msg types.Message) (*http.Response, error) {
+	var req *http.Request
+	var res *http.Response
+	var err error
+
+	if req, err = h.createRequest(msg); err != nil {
+		return nil, err
+	}
+
+	var rateLimited bool
+	res, rateLimited, err = h.do(req)
+
+	i, j := 0, h.conf.NumRetries
+	for i < j && err != nil {
+		if req, err = h.createRequest(msg); err != nil {
+			return nil, err
+		}
+		if rateLimited {
+			if !h.retryThrottle.ExponentialRetry() {
+				return nil, types.ErrTypeClosed
+			}
+		} else {
+			if !h.retryThrottle.Retry() {
+				return nil, types.ErrTypeClosed
+			}
+		}
+		res, rateLimited, err = h.do(req)
+		i++
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	h.retryThrottle.Reset()
+	return res, nil
+}
+
+//------------------------------------------------------------------------------
@@ -22,11 +22,15 @@ package text
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
 )
 
 //------------------------------------------------------------------------------
@@ -92,6 +96,79 @@ var functionVars = map[string]func(arg string) []byte{
 	},
 }
 
+//------------------------------------------------------------------------------
+
+// parsePartArg splits a function argument of the form `foo,N` into the string
+// `foo` and a message part index N. If the index is omitted then it defaults to
+// defaultIndex.
+func parsePartArg(arg string, defaultIndex int) (string, int) {
+	if i := strings.LastIndexByte(arg, ','); i >= 0 {
+		if index, err := strconv.Atoi(arg[i+1:]); err == nil {
+			return arg[:i], index
+		}
+	}
+	return arg, defaultIndex
+}
+
+// getJSONField walks a dot path through a parsed JSON structure and returns the
+// value found, or nil if the path does not exist.
+func getJSONField(jObj interface{}, path string) interface{} {
+	if len(path) == 0 || path == "." {
+		return jObj
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch t := jObj.(type) {
+		case map[string]interface{}:
+			jObj = t[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(t) {
+				return nil
+			}
+			jObj = t[index]
+		default:
+			return nil
+		}
+	}
+	return jObj
+}
+
+// messageFunctionVars are functions that extract data from a message, where
+// index is the part targeted when the argument does not specify one.
+var messageFunctionVars = map[string]func(msg types.Message, index int, arg string) []byte{
+	"content": func(msg types.Message, index int, arg string) []byte {
+		if len(arg) > 0 {
+			index, _ = strconv.Atoi(arg)
+		}
+		return msg.Get(index)
+	},
+	"json_field": func(msg types.Message, index int, arg string) []byte {
+		path, index := parsePartArg(arg, index)
+		jPart, err := msg.GetJSON(index)
+		if err != nil {
+			return []byte("null")
+		}
+		switch t := getJSONField(jPart, path).(type) {
+		case string:
+			return []byte(t)
+		case nil:
+			return []byte("null")
+		default:
+			jBytes, _ := json.Marshal(t)
+			return jBytes
+		}
+	},
+	"metadata": func(msg types.Message, index int, arg string) []byte {
+		key, index := parsePartArg(arg, index)
+		return []byte(msg.GetMetadata(index).Get(key))
+	},
+	"batch_size": func(msg types.Message, index int, arg string) []byte {
+		return []byte(strconv.Itoa(msg.Len()))
+	},
+}
+
+//------------------------------------------------------------------------------
+
 // ContainsFunctionVariables returns true if inBytes contains function variable
 // replace patterns.
 func ContainsFunctionVariables(inBytes []byte) bool {
@@ -103,20 +180,50 @@ func ContainsFunctionVariables(inBytes []byte) bool {
 //
 // For each aforementioned pattern found in the blob the contents of the
 // respective function will be run and will replace the pattern.
+//
+// Functions that extract data from a message are not resolved, use
+// ReplaceFunctionVariablesFor in order to resolve those functions.
 func ReplaceFunctionVariables(inBytes []byte) []byte {
+	return replaceFunctionVariables(nil, 0, inBytes)
+}
+
+// ReplaceFunctionVariablesFor will search a blob of data for the pattern
+// `${!foo}`, where `foo` is a function name, and replaces it with the result of
+// the respective function.
+//
+// Unlike ReplaceFunctionVariables this call also resolves functions that
+// extract data from the message provided, such as `${!json_field:foo.bar,0}`.
+func ReplaceFunctionVariablesFor(msg types.Message, inBytes []byte) []byte {
+	return replaceFunctionVariables(msg, 0, inBytes)
+}
+
+// ReplaceFunctionVariablesForPart is equivalent to ReplaceFunctionVariablesFor
+// except that functions extracting data from a message part target the part at
+// index unless another index is specified. Functions such as
+// `${!batch_size}` still resolve against the whole message, which makes this
+// suitable for fields that are resolved per part of a batch.
+func ReplaceFunctionVariablesForPart(msg types.Message, index int, inBytes []byte) []byte {
+	return replaceFunctionVariables(msg, index, inBytes)
+}
+
+func replaceFunctionVariables(msg types.Message, index int, inBytes []byte) []byte {
 	return functionRegex.ReplaceAllFunc(inBytes, func(content []byte) []byte {
-		if len(content) > 4 {
-			if colonIndex := bytes.IndexByte(content, ':'); colonIndex == -1 {
-				targetFunc := string(content[3 : len(content)-1])
-				if ftor, exists := functionVars[targetFunc]; exists {
-					return ftor("")
-				}
-			} else {
-				targetFunc := string(content[3:colonIndex])
-				argVal := string(content[colonIndex+1 : len(content)-1])
-				if ftor, exists := functionVars[targetFunc]; exists {
-					return ftor(argVal)
-				}
+		if len(content) <= 4 {
+			return content
+		}
+		var targetFunc, argVal string
+		if colonIndex := bytes.IndexByte(content, ':'); colonIndex == -1 {
+			targetFunc = string(content[3 : len(content)-1])
+		} else {
+			targetFunc = string(content[3:colonIndex])
+			argVal = string(content[colonIndex+1 : len(content)-1])
+		}
+		if ftor, exists := functionVars[targetFunc]; exists {
+			return ftor(argVal)
+		}
+		if msg != nil {
+			if ftor, exists := messageFunctionVars[targetFunc]; exists {
+				return ftor(msg, index, argVal)
 			}
 		}
 		return content
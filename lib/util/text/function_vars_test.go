@@ -26,6 +26,8 @@ import (
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/Jeffail/benthos/lib/types"
 )
 
 func TestFunctionVarDetection(t *testing.T) {
@@ -159,3 +161,82 @@ func TestCountersFunction(t *testing.T) {
 		}
 	}
 }
+
+func TestMessageFunctions(t *testing.T) {
+	msg := types.NewMessage([][]byte{
+		[]byte(`{"foo":{"bar":"baz","qux":[1,2]}}`),
+		[]byte(`second part`),
+		[]byte(`{"foo":{"bar":5}}`),
+	})
+	msg.GetMetadata(0).Set("key", "value1")
+	msg.GetMetadata(2).Set("key", "value3")
+
+	tests := map[string]string{
+		"foo ${!content} bar":                 `foo {"foo":{"bar":"baz","qux":[1,2]}} bar`,
+		"foo ${!content:1} bar":               "foo second part bar",
+		"foo ${!content:5} bar":               "foo  bar",
+		"foo ${!json_field:foo.bar} bar":      "foo baz bar",
+		"foo ${!json_field:foo.bar,0} bar":    "foo baz bar",
+		"foo ${!json_field:foo.bar,2} bar":    "foo 5 bar",
+		"foo ${!json_field:foo.qux} bar":      "foo [1,2] bar",
+		"foo ${!json_field:foo.qux.1} bar":    "foo 2 bar",
+		"foo ${!json_field:foo.nope} bar":     "foo null bar",
+		"foo ${!json_field:foo.bar,1} bar":    "foo null bar",
+		"foo ${!metadata:key} bar":            "foo value1 bar",
+		"foo ${!metadata:key,2} bar":          "foo value3 bar",
+		"foo ${!metadata:key,1} bar":          "foo  bar",
+		"foo ${!batch_size} bar":              "foo 3 bar",
+		"foo ${!echo:baz} ${!batch_size} bar": "foo baz 3 bar",
+	}
+
+	for input, exp := range tests {
+		act := string(ReplaceFunctionVariablesFor(msg, []byte(input)))
+		if exp != act {
+			t.Errorf("Wrong results for input (%v): %v != %v", input, act, exp)
+		}
+	}
+}
+
+func TestMessageFunctionsForPart(t *testing.T) {
+	msg := types.NewMessage([][]byte{
+		[]byte(`{"foo":"first"}`),
+		[]byte(`{"foo":"second"}`),
+		[]byte(`third`),
+	})
+	msg.GetMetadata(0).Set("key", "value1")
+	msg.GetMetadata(1).Set("key", "value2")
+
+	tests := map[string]string{
+		"foo ${!content} bar":              `foo {"foo":"second"} bar`,
+		"foo ${!content:2} bar":            "foo third bar",
+		"foo ${!json_field:foo} bar":       "foo second bar",
+		"foo ${!json_field:foo,0} bar":     "foo first bar",
+		"foo ${!metadata:key} bar":         "foo value2 bar",
+		"foo ${!metadata:key,0} bar":       "foo value1 bar",
+		"foo ${!batch_size} bar":           "foo 3 bar",
+		"foo ${!echo:baz} ${!content} bar": `foo baz {"foo":"second"} bar`,
+	}
+
+	for input, exp := range tests {
+		act := string(ReplaceFunctionVariablesForPart(msg, 1, []byte(input)))
+		if exp != act {
+			t.Errorf("Wrong results for input (%v): %v != %v", input, act, exp)
+		}
+	}
+}
+
+func TestMessageFunctionsWithoutMessage(t *testing.T) {
+	tests := []string{
+		"foo ${!content} bar",
+		"foo ${!json_field:foo.bar,0} bar",
+		"foo ${!metadata:key} bar",
+		"foo ${!batch_size} bar",
+	}
+
+	for _, input := range tests {
+		act := string(ReplaceFunctionVariables([]byte(input)))
+		if input != act {
+			t.Errorf("Wrong results for input (%v): %v != %v", input, act, input)
+		}
+	}
+}
@@ -95,7 +95,7 @@ func (t *Type) Retry() bool {
 		return true
 	}
 	select {
-	case <-time.After(time.Duration(t.throttlePeriod)):
+	case <-time.After(time.Duration(atomic.LoadInt64(&t.throttlePeriod))):
 	case <-t.closeChan:
 		return false
 	}
@@ -120,8 +120,8 @@ func (t *Type) ExponentialRetry() bool {
 // Reset clears the count of consecutive retries and resets the exponential
 // backoff.
 func (t *Type) Reset() {
-	t.consecutiveRetries = 0
-	t.throttlePeriod = t.baseThrottlePeriod
+	atomic.StoreInt64(&t.consecutiveRetries, 0)
+	atomic.StoreInt64(&t.throttlePeriod, t.baseThrottlePeriod)
 }
 
 //------------------------------------------------------------------------------